@@ -0,0 +1,87 @@
+// Package auth provides middlewares.AuthorizeFunc implementations obtaining access tokens from common identity providers.
+// The returned functions are meant to be used with RequestExecutor.WithAuthorization and the "Bearer" schema.
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// defaultClient is used to call the identity providers when no http.Client is configured.
+var defaultClient = &http.Client{Timeout: 30 * time.Second}
+
+// tokenResponse is the OAuth2 token endpoint response shared by the identity providers.
+type tokenResponse struct {
+	AccessToken string          `json:"access_token"`
+	TokenType   string          `json:"token_type"`
+	ExpiresIn   json.RawMessage `json:"expires_in"`
+}
+
+// lifeSpan returns the token lifespan. Some providers send expires_in as a string, others as a number.
+func (tr tokenResponse) lifeSpan() (time.Duration, error) {
+	raw := string(tr.ExpiresIn)
+	if unquoted, err := strconv.Unquote(raw); err == nil {
+		raw = unquoted
+	}
+
+	seconds, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid expires_in %q: %w", tr.ExpiresIn, err)
+	}
+
+	return time.Duration(seconds) * time.Second, nil
+}
+
+// fetchToken sends the token request and parses the OAuth2 token response.
+func fetchToken(client *http.Client, req *http.Request) (string, time.Duration, error) {
+	body, err := send(client, req)
+	if err != nil {
+		return "", 0, err
+	}
+
+	var tr tokenResponse
+	if err := json.Unmarshal(body, &tr); err != nil {
+		return "", 0, fmt.Errorf("could not parse token response: %w", err)
+	}
+
+	lifeSpan, err := tr.lifeSpan()
+	if err != nil {
+		return "", 0, err
+	}
+
+	return tr.AccessToken, lifeSpan, nil
+}
+
+// send executes the request against the identity provider and returns the body of a successful response.
+func send(client *http.Client, req *http.Request) ([]byte, error) {
+	if client == nil {
+		client = defaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return nil, fmt.Errorf("token request to %s failed with status %d: %s", req.URL.Redacted(), resp.StatusCode, body)
+	}
+
+	return body, nil
+}
+
+// newRequest creates a token request bound to a background context, since AuthorizeFunc is called outside of any request.
+func newRequest(method, url string, body io.Reader) (*http.Request, error) {
+	return http.NewRequestWithContext(context.Background(), method, url, body)
+}
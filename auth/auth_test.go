@@ -0,0 +1,153 @@
+package auth_test
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/liviudnicoara/swiftreq/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Azure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/tenant/oauth2/v2.0/token":
+			_ = r.ParseForm()
+			if r.PostForm.Get("client_secret") != "secret" || r.PostForm.Get("scope") != "api://app/.default" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]any{"access_token": "aad-token", "token_type": "Bearer", "expires_in": 3600})
+		case "/metadata/identity/oauth2/token":
+			if r.Header.Get("Metadata") != "true" || r.URL.Query().Get("resource") != "https://vault.azure.net" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]any{"access_token": "msi-token", "token_type": "Bearer", "expires_in": "600"})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	t.Run("ClientSecret", func(t *testing.T) {
+		// arrange
+		authorize := auth.AzureClientSecretAuthorizer(auth.AzureClientSecret{
+			TenantID:     "tenant",
+			ClientID:     "client",
+			ClientSecret: "secret",
+			Scope:        "api://app/.default",
+			Authority:    server.URL,
+		})
+
+		// act
+		token, lifeSpan, err := authorize()
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, "aad-token", token)
+		assert.Equal(t, time.Hour, lifeSpan)
+	})
+
+	t.Run("ManagedIdentity", func(t *testing.T) {
+		// arrange
+		authorize := auth.AzureManagedIdentityAuthorizer(auth.AzureManagedIdentity{
+			Resource: "https://vault.azure.net",
+			Endpoint: server.URL + "/metadata/identity/oauth2/token",
+		})
+
+		// act
+		token, lifeSpan, err := authorize()
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, "msi-token", token)
+		assert.Equal(t, 10*time.Minute, lifeSpan)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		// arrange
+		authorize := auth.AzureClientSecretAuthorizer(auth.AzureClientSecret{TenantID: "tenant", Authority: server.URL})
+
+		// act
+		_, _, err := authorize()
+
+		// assert
+		assert.Contains(t, err.Error(), "status 401")
+	})
+}
+
+func Test_Google(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			_ = r.ParseForm()
+			parts := strings.Split(r.PostForm.Get("assertion"), ".")
+			signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+			digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+			if rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], signature) != nil {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]any{"access_token": "sa-token", "expires_in": 3599})
+		case "/default/token":
+			if r.Header.Get("Metadata-Flavor") != "Google" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]any{"access_token": "gce-token", "expires_in": 1800})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	t.Run("ServiceAccount", func(t *testing.T) {
+		// arrange
+		keyJSON, _ := json.Marshal(map[string]string{
+			"client_email": "sa@project.iam.gserviceaccount.com",
+			"private_key":  string(keyPEM),
+			"token_uri":    server.URL + "/token",
+		})
+		authorize, err := auth.GoogleServiceAccountAuthorizer(auth.GoogleServiceAccount{
+			KeyJSON: keyJSON,
+			Scopes:  []string{"https://www.googleapis.com/auth/cloud-platform"},
+		})
+		assert.Nil(t, err)
+
+		// act
+		token, lifeSpan, err := authorize()
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, "sa-token", token)
+		assert.Equal(t, 3599*time.Second, lifeSpan)
+	})
+
+	t.Run("Metadata", func(t *testing.T) {
+		// arrange
+		authorize := auth.GoogleMetadataAuthorizer(auth.GoogleMetadata{Endpoint: server.URL + "/default"})
+
+		// act
+		token, lifeSpan, err := authorize()
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, "gce-token", token)
+		assert.Equal(t, 30*time.Minute, lifeSpan)
+	})
+}
@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/liviudnicoara/swiftreq/middlewares"
+)
+
+const (
+	// defaultAzureAuthority is the Azure AD endpoint of the public cloud.
+	defaultAzureAuthority = "https://login.microsoftonline.com"
+	// defaultAzureIMDSEndpoint is the managed identity endpoint of the Azure Instance Metadata Service.
+	defaultAzureIMDSEndpoint = "http://169.254.169.254/metadata/identity/oauth2/token"
+)
+
+// AzureClientSecret configures the acquisition of Azure AD tokens with the client credentials flow.
+type AzureClientSecret struct {
+	TenantID     string
+	ClientID     string
+	ClientSecret string
+	// Scope is the requested scope, such as "https://graph.microsoft.com/.default".
+	Scope string
+	// Authority is the Azure AD endpoint. Defaults to https://login.microsoftonline.com.
+	Authority string
+	// HTTPClient is used to call Azure AD. Defaults to a client with a 30s timeout.
+	HTTPClient *http.Client
+}
+
+// AzureManagedIdentity configures the acquisition of Azure AD tokens for the managed identity of the host.
+type AzureManagedIdentity struct {
+	// Resource is the resource the token is requested for, such as "https://management.azure.com/".
+	Resource string
+	// ClientID selects a user assigned identity. The system assigned identity is used when empty.
+	ClientID string
+	// Endpoint is the metadata endpoint. Defaults to the Azure Instance Metadata Service.
+	Endpoint string
+	// HTTPClient is used to call the metadata endpoint. Defaults to a client with a 30s timeout.
+	HTTPClient *http.Client
+}
+
+// AzureClientSecretAuthorizer returns an AuthorizeFunc obtaining Azure AD tokens with the client credentials flow.
+func AzureClientSecretAuthorizer(cfg AzureClientSecret) middlewares.AuthorizeFunc {
+	authority := cfg.Authority
+	if authority == "" {
+		authority = defaultAzureAuthority
+	}
+
+	tokenURL := strings.TrimSuffix(authority, "/") + "/" + url.PathEscape(cfg.TenantID) + "/oauth2/v2.0/token"
+
+	return func() (string, time.Duration, error) {
+		form := url.Values{
+			"grant_type":    {"client_credentials"},
+			"client_id":     {cfg.ClientID},
+			"client_secret": {cfg.ClientSecret},
+			"scope":         {cfg.Scope},
+		}
+
+		req, err := newRequest(http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+		if err != nil {
+			return "", 0, err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		return fetchToken(cfg.HTTPClient, req)
+	}
+}
+
+// AzureManagedIdentityAuthorizer returns an AuthorizeFunc obtaining Azure AD tokens from the managed identity endpoint.
+func AzureManagedIdentityAuthorizer(cfg AzureManagedIdentity) middlewares.AuthorizeFunc {
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = defaultAzureIMDSEndpoint
+	}
+
+	return func() (string, time.Duration, error) {
+		query := url.Values{
+			"api-version": {"2018-02-01"},
+			"resource":    {cfg.Resource},
+		}
+		if cfg.ClientID != "" {
+			query.Set("client_id", cfg.ClientID)
+		}
+
+		req, err := newRequest(http.MethodGet, endpoint+"?"+query.Encode(), nil)
+		if err != nil {
+			return "", 0, err
+		}
+		req.Header.Set("Metadata", "true")
+
+		return fetchToken(cfg.HTTPClient, req)
+	}
+}
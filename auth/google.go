@@ -0,0 +1,232 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/liviudnicoara/swiftreq/middlewares"
+)
+
+const (
+	// defaultGoogleTokenURL is the Google OAuth2 token endpoint.
+	defaultGoogleTokenURL = "https://oauth2.googleapis.com/token"
+	// defaultGoogleMetadataURL is the service account endpoint of the GCE metadata server.
+	defaultGoogleMetadataURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default"
+	// googleAssertionLifeSpan is the validity of the signed JWT assertion exchanged for a token.
+	googleAssertionLifeSpan = time.Hour
+)
+
+// GoogleServiceAccount configures the acquisition of Google tokens from a service account key.
+type GoogleServiceAccount struct {
+	// KeyJSON is the content of the service account key file.
+	KeyJSON []byte
+	// Scopes are the requested OAuth2 scopes, used to obtain an access token.
+	Scopes []string
+	// Audience requests an ID token for the audience instead of an access token, as needed by IAP protected services.
+	Audience string
+	// HTTPClient is used to call the token endpoint. Defaults to a client with a 30s timeout.
+	HTTPClient *http.Client
+}
+
+// GoogleMetadata configures the acquisition of Google tokens for the default service account of the GCE metadata server.
+type GoogleMetadata struct {
+	// Scopes optionally restricts the scopes of the access token.
+	Scopes []string
+	// Audience requests an ID token for the audience instead of an access token.
+	Audience string
+	// Endpoint is the service account endpoint. Defaults to the GCE metadata server.
+	Endpoint string
+	// HTTPClient is used to call the metadata server. Defaults to a client with a 30s timeout.
+	HTTPClient *http.Client
+}
+
+// serviceAccountKey holds the fields of a service account key file used to sign assertions.
+type serviceAccountKey struct {
+	ClientEmail  string `json:"client_email"`
+	PrivateKeyID string `json:"private_key_id"`
+	PrivateKey   string `json:"private_key"`
+	TokenURI     string `json:"token_uri"`
+}
+
+// GoogleServiceAccountAuthorizer returns an AuthorizeFunc exchanging assertions signed with the service account key for Google tokens.
+func GoogleServiceAccountAuthorizer(cfg GoogleServiceAccount) (middlewares.AuthorizeFunc, error) {
+	var key serviceAccountKey
+	if err := json.Unmarshal(cfg.KeyJSON, &key); err != nil {
+		return nil, fmt.Errorf("could not parse service account key: %w", err)
+	}
+
+	signer, err := parseRSAKey(key.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	tokenURL := key.TokenURI
+	if tokenURL == "" {
+		tokenURL = defaultGoogleTokenURL
+	}
+
+	return func() (string, time.Duration, error) {
+		now := time.Now()
+		claims := map[string]any{
+			"iss": key.ClientEmail,
+			"aud": tokenURL,
+			"iat": now.Unix(),
+			"exp": now.Add(googleAssertionLifeSpan).Unix(),
+		}
+		if cfg.Audience != "" {
+			claims["target_audience"] = cfg.Audience
+		} else {
+			claims["scope"] = strings.Join(cfg.Scopes, " ")
+		}
+
+		assertion, err := signRS256(signer, key.PrivateKeyID, claims)
+		if err != nil {
+			return "", 0, err
+		}
+
+		form := url.Values{
+			"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+			"assertion":  {assertion},
+		}
+
+		req, err := newRequest(http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+		if err != nil {
+			return "", 0, err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		if cfg.Audience != "" {
+			return fetchIDToken(cfg.HTTPClient, req)
+		}
+
+		return fetchToken(cfg.HTTPClient, req)
+	}, nil
+}
+
+// GoogleMetadataAuthorizer returns an AuthorizeFunc obtaining Google tokens from the metadata server.
+func GoogleMetadataAuthorizer(cfg GoogleMetadata) middlewares.AuthorizeFunc {
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = defaultGoogleMetadataURL
+	}
+
+	return func() (string, time.Duration, error) {
+		var target string
+		if cfg.Audience != "" {
+			target = endpoint + "/identity?" + url.Values{"audience": {cfg.Audience}, "format": {"full"}}.Encode()
+		} else {
+			target = endpoint + "/token"
+			if len(cfg.Scopes) > 0 {
+				target += "?" + url.Values{"scopes": {strings.Join(cfg.Scopes, ",")}}.Encode()
+			}
+		}
+
+		req, err := newRequest(http.MethodGet, target, nil)
+		if err != nil {
+			return "", 0, err
+		}
+		req.Header.Set("Metadata-Flavor", "Google")
+
+		if cfg.Audience == "" {
+			return fetchToken(cfg.HTTPClient, req)
+		}
+
+		body, err := send(cfg.HTTPClient, req)
+		if err != nil {
+			return "", 0, err
+		}
+
+		token := strings.TrimSpace(string(body))
+		lifeSpan, err := jwtLifeSpan(token)
+
+		return token, lifeSpan, err
+	}
+}
+
+// fetchIDToken sends the token request and returns the ID token of the response, with the lifespan read from its exp claim.
+func fetchIDToken(client *http.Client, req *http.Request) (string, time.Duration, error) {
+	body, err := send(client, req)
+	if err != nil {
+		return "", 0, err
+	}
+
+	var tr struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.Unmarshal(body, &tr); err != nil {
+		return "", 0, fmt.Errorf("could not parse token response: %w", err)
+	}
+
+	lifeSpan, err := jwtLifeSpan(tr.IDToken)
+
+	return tr.IDToken, lifeSpan, err
+}
+
+// parseRSAKey parses a PEM encoded PKCS #8 or PKCS #1 RSA private key.
+func parseRSAKey(pemKey string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(pemKey))
+	if block == nil {
+		return nil, errors.New("service account private key is not PEM encoded")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse service account private key: %w", err)
+	}
+
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("service account private key is not an RSA key")
+	}
+
+	return key, nil
+}
+
+// signRS256 creates a JWT with the claims signed using RS256.
+func signRS256(key *rsa.PrivateKey, keyID string, claims map[string]any) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": keyID})
+	if err != nil {
+		return "", err
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(unsigned))
+
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// jwtLifeSpan returns the time left until the exp claim of the JWT.
+func jwtLifeSpan(token string) (time.Duration, error) {
+	exp, err := middlewares.JWTExpiry(token)
+	if err != nil {
+		return 0, err
+	}
+
+	return time.Until(exp), nil
+}
@@ -0,0 +1,28 @@
+package auth
+
+import (
+	"time"
+
+	"github.com/liviudnicoara/swiftreq/middlewares"
+	"golang.org/x/oauth2"
+)
+
+// tokenSourcePollInterval is the lifespan given to tokens without expiry, after which the source is asked again.
+const tokenSourcePollInterval = time.Hour
+
+// TokenSourceAuthorizer returns an AuthorizeFunc obtaining tokens from an oauth2.TokenSource.
+// The lifespan is derived from the token expiry. Tokens without expiry are requested again every hour.
+func TokenSourceAuthorizer(ts oauth2.TokenSource) middlewares.AuthorizeFunc {
+	return func() (string, time.Duration, error) {
+		tok, err := ts.Token()
+		if err != nil {
+			return "", 0, err
+		}
+
+		if tok.Expiry.IsZero() {
+			return tok.AccessToken, tokenSourcePollInterval, nil
+		}
+
+		return tok.AccessToken, time.Until(tok.Expiry), nil
+	}
+}
@@ -0,0 +1,84 @@
+package swiftreq
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// BatchOptions configures DoAll.
+type BatchOptions struct {
+	// Workers is the number of requests executed concurrently. It defaults to 10.
+	Workers int
+	// FailFast cancels the requests in flight and skips the remaining ones once a request fails.
+	// Otherwise every request is executed and all the errors are collected.
+	FailFast bool
+}
+
+// BatchResult is the outcome of a request executed by DoAll.
+type BatchResult[T any] struct {
+	Value *T
+	Meta  *ResponseMeta
+	Err   error
+}
+
+// DoAll executes the requests concurrently with a pool of workers, and returns their results in the order of the requests.
+// The returned error joins the errors of the failed requests. With FailFast it is the first error instead,
+// and the requests canceled or skipped because of it fail with a CanceledError whose cause is that error.
+func DoAll[T any](ctx context.Context, opts BatchOptions, requests ...*Request[T]) ([]BatchResult[T], error) {
+	if opts.Workers <= 0 {
+		opts.Workers = 10
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	results := make([]BatchResult[T], len(requests))
+	indexes := make(chan int)
+
+	var first error
+	var once sync.Once
+
+	var wg sync.WaitGroup
+	for w := 0; w < min(opts.Workers, len(requests)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for i := range indexes {
+				if ctx.Err() != nil {
+					results[i].Err = &CanceledError{Err: ctx.Err(), Cause: context.Cause(ctx)}
+					continue
+				}
+
+				value, meta, err := requests[i].DoWithResponse(ctx)
+				results[i] = BatchResult[T]{Value: value, Meta: meta, Err: err}
+
+				if err != nil && opts.FailFast {
+					once.Do(func() {
+						first = err
+						cancel(err)
+					})
+				}
+			}
+		}()
+	}
+
+	for i := range requests {
+		indexes <- i
+	}
+	close(indexes)
+
+	wg.Wait()
+
+	if opts.FailFast {
+		return results, first
+	}
+
+	errs := make([]error, len(results))
+	for i, r := range results {
+		errs[i] = r.Err
+	}
+
+	return results, errors.Join(errs...)
+}
@@ -0,0 +1,55 @@
+package swiftreq
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Budget splits the time remaining before the deadline of a context across a sequence of dependent calls,
+// so that the last call of the chain is not the only one running out of time.
+type Budget struct {
+	ctx context.Context
+
+	mu     sync.Mutex
+	shares []float64
+	step   int
+}
+
+// NewBudget creates a Budget over the deadline of ctx, reserving to each step its share of the remaining time, such as
+// NewBudget(ctx, 20, 30, 50) for three steps taking 20%, 30% and 50% of it.
+// The time left unused by a step is redistributed to the next steps, in proportion of their shares.
+func NewBudget(ctx context.Context, shares ...float64) *Budget {
+	return &Budget{
+		ctx:    ctx,
+		shares: shares,
+	}
+}
+
+// Next returns the context of the next step, whose deadline is its share of the time remaining before the deadline of the budget.
+// Contexts without deadline, and steps beyond the shares, get the remaining time. The CancelFunc must be called once the step is done.
+func (b *Budget) Next() (context.Context, context.CancelFunc) {
+	b.mu.Lock()
+	step := b.step
+	b.step++
+	b.mu.Unlock()
+
+	deadline, ok := b.ctx.Deadline()
+	if !ok || step >= len(b.shares) {
+		return context.WithCancel(b.ctx)
+	}
+
+	var total float64
+	for _, share := range b.shares[step:] {
+		total += share
+	}
+
+	if total <= 0 {
+		return context.WithCancel(b.ctx)
+	}
+
+	remaining := time.Until(deadline)
+	stepBudget := time.Duration(float64(remaining) * b.shares[step] / total)
+
+	return context.WithTimeout(b.ctx, stepBudget)
+}
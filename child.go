@@ -0,0 +1,71 @@
+package swiftreq
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/liviudnicoara/swiftreq/middlewares"
+)
+
+// ChildOption overrides a setting of a child RequestExecutor, see RequestExecutor.Child.
+type ChildOption func(child *RequestExecutor)
+
+// ChildTimeout overrides the timeout of the child executor.
+func ChildTimeout(timeout time.Duration) ChildOption {
+	return func(child *RequestExecutor) {
+		child.WithTimeout(timeout)
+	}
+}
+
+// ChildRetry overrides the retry of the child executor with an exponential retry of the given count.
+// The retry middleware of the parent is replaced in place, so it keeps its position among the middlewares.
+func ChildRetry(retry int) ChildOption {
+	return func(child *RequestExecutor) {
+		rh := middlewares.RetryHandler{
+			MinWait:    child.MinWaitRetry,
+			MaxWait:    child.MaxWaitRetry,
+			RetryCount: retry,
+			Backoff:    middlewares.ExponentialBackoffTime,
+		}
+
+		child.mu.Lock()
+		defer child.mu.Unlock()
+
+		child.setRetry(rh)
+	}
+}
+
+// ChildHeaders adds headers to every request of the child executor, unless the request sets them.
+func ChildHeaders(headers map[string]string) ChildOption {
+	h := http.Header{}
+	for k, v := range headers {
+		h.Set(k, v)
+	}
+
+	return func(child *RequestExecutor) {
+		child.WithMiddleware(func(next middlewares.Handler) middlewares.Handler {
+			return func(req *http.Request) (*http.Response, error) {
+				for k, vs := range h {
+					if req.Header.Get(k) == "" {
+						req.Header[k] = vs
+					}
+				}
+
+				return next(req)
+			}
+		})
+	}
+}
+
+// Child creates a RequestExecutor inheriting the configuration of re, with the settings overridden by the options.
+// The child shares the transport, and so the connection pool, and the middlewares of re, such as its authorization tokens and cache.
+// Later changes to re do not apply to the child, and changes to the child do not apply to re.
+func (re *RequestExecutor) Child(opts ...ChildOption) *RequestExecutor {
+	child := re.derive()
+
+	for _, opt := range opts {
+		opt(child)
+	}
+
+	return child
+}
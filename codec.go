@@ -0,0 +1,160 @@
+package swiftreq
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// Codec encodes payloads and decodes responses of the media types it declares, such as YAML, MessagePack or CBOR.
+// Register it with RequestExecutor.RegisterCodec.
+type Codec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+	// ContentTypes lists the media types of the codec. The first one is sent in the Content-Type and Accept headers.
+	ContentTypes() []string
+}
+
+// namedCodec is a Codec registered under a name.
+type namedCodec struct {
+	name  string
+	codec Codec
+}
+
+// decoder decodes response bodies of a specific media type.
+type decoder interface {
+	Unmarshal(data []byte, v any) error
+}
+
+// jsonCodec encodes and decodes JSON bodies, converting the keys with the naming strategy when one is set.
+type jsonCodec struct {
+	naming      NamingStrategy
+	useNumber   bool
+	timeLayouts []string
+}
+
+// ContentTypes returns the JSON media type.
+func (jsonCodec) ContentTypes() []string { return []string{"application/json"} }
+
+// Unmarshal parses the JSON-encoded data and stores the result in the value pointed to by v.
+func (c jsonCodec) Unmarshal(data []byte, v any) error {
+	if c.naming == nil && len(c.timeLayouts) == 0 {
+		return c.unmarshal(data, v)
+	}
+
+	generic, err := decodeGeneric(data)
+	if err != nil {
+		return err
+	}
+
+	w := jsonRewriter{naming: c.naming, layouts: c.timeLayouts}
+	data, err = json.Marshal(w.rewrite(generic, reflect.TypeOf(v)))
+	if err != nil {
+		return err
+	}
+
+	return c.unmarshal(data, v)
+}
+
+// unmarshal decodes data into v, keeping numbers as json.Number in interface values when useNumber is set.
+func (c jsonCodec) unmarshal(data []byte, v any) error {
+	if !c.useNumber {
+		return json.Unmarshal(data, v)
+	}
+
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+
+	if err := d.Decode(v); err != nil {
+		return err
+	}
+
+	if _, err := d.Token(); err != io.EOF {
+		return errors.New("invalid data after top-level JSON value")
+	}
+
+	return nil
+}
+
+// Marshal returns the JSON encoding of v.
+func (c jsonCodec) Marshal(v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil || c.naming == nil {
+		return data, err
+	}
+
+	generic, err := decodeGeneric(data)
+	if err != nil {
+		return nil, err
+	}
+
+	w := jsonRewriter{naming: c.naming, toWire: true}
+	return json.Marshal(w.rewrite(generic, reflect.TypeOf(v)))
+}
+
+// decodeGeneric decodes JSON data into maps, slices and json.Number values, so that it can be encoded again without loss.
+func decodeGeneric(data []byte) (any, error) {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+
+	var generic any
+	err := d.Decode(&generic)
+
+	return generic, err
+}
+
+// xmlCodec encodes and decodes XML bodies.
+type xmlCodec struct{}
+
+// Unmarshal parses the XML-encoded data and stores the result in the value pointed to by v.
+func (xmlCodec) Unmarshal(data []byte, v any) error { return xml.Unmarshal(data, v) }
+
+// Marshal returns the XML encoding of v.
+func (xmlCodec) Marshal(v any) ([]byte, error) { return xml.Marshal(v) }
+
+// ContentTypes returns the XML media types.
+func (xmlCodec) ContentTypes() []string { return []string{"application/xml", "text/xml"} }
+
+// isXML reports whether the media type is XML, such as application/xml, text/xml or application/soap+xml.
+func isXML(mediaType string) bool {
+	mt := baseMediaType(mediaType)
+
+	return mt == "application/xml" || mt == "text/xml" || strings.HasSuffix(mt, "+xml")
+}
+
+// baseMediaType returns the lower case media type without its parameters, such as "text/xml" for "text/xml; charset=utf-8".
+func baseMediaType(mediaType string) string {
+	mt, _, _ := strings.Cut(strings.ToLower(mediaType), ";")
+
+	return strings.TrimSpace(mt)
+}
+
+// handles reports whether the codec declares the media type.
+func handles(c Codec, mediaType string) bool {
+	mt := baseMediaType(mediaType)
+	for _, ct := range c.ContentTypes() {
+		if baseMediaType(ct) == mt {
+			return true
+		}
+	}
+
+	return false
+}
+
+// codecFor returns the codec able to decode the given media type, or nil if there is none.
+func codecFor(mediaType string) decoder {
+	switch mt := strings.ToLower(mediaType); {
+	case strings.Contains(mt, "json"):
+		return jsonCodec{}
+	case strings.Contains(mt, "xml"):
+		return xmlCodec{}
+	case strings.Contains(mt, "html"):
+		return htmlCodec{}
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,65 @@
+package swiftreq
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// GetModifyPut updates a resource with optimistic concurrency control: it gets the resource and its ETag, applies modify,
+// and puts the result with an If-Match header. When the resource changed in the meantime and the server answers 412 Precondition Failed,
+// the resource is fetched again and modify is applied again, up to retries times.
+// It returns the resource returned by the PUT request, or the modified resource when the server answers without body.
+// The requests are executed with re, or the default RequestExecutor when it is nil.
+func GetModifyPut[T any](ctx context.Context, re *RequestExecutor, url string, retries int, modify func(resource *T) error) (*T, error) {
+	if re == nil {
+		re = Default()
+	}
+
+	for attempt := 0; ; attempt++ {
+		resource, meta, err := newRequest[T](re).WithMethod("GET").WithURL(url).DoWithResponse(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		etag := meta.Header.Get("ETag")
+		if etag == "" {
+			return nil, &Error{Message: "resource has no ETag " + url, StatusCode: meta.StatusCode}
+		}
+
+		if err := modify(resource); err != nil {
+			return nil, err
+		}
+
+		body, meta, err := newRequest[RawBytes](re).
+			WithMethod("PUT").
+			WithURL(url).
+			WithPayload(resource).
+			WithHeader("If-Match", etag).
+			DoWithResponse(ctx)
+
+		var swiftErr *Error
+		if errors.As(err, &swiftErr) && swiftErr.StatusCode == http.StatusPreconditionFailed && attempt < retries {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if len(*body) == 0 {
+			return resource, nil
+		}
+
+		updated, err := newRequest[T](re).decode(meta.Header.Get("Content-Type"), *body)
+		if err != nil {
+			return nil, &Error{
+				Message:    "error decoding response for request " + url,
+				Cause:      &DecodeError{ContentType: meta.Header.Get("Content-Type"), Err: err},
+				StatusCode: meta.StatusCode,
+			}
+		}
+
+		return &updated, nil
+	}
+}
@@ -0,0 +1,102 @@
+package swiftreq
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// AsCurl renders the request as a curl command, with its method, URL, headers and body, to reproduce it from a shell:
+//
+//	curl -X POST 'https://api.example.com/posts' \
+//	  -H 'Content-Type: application/json' \
+//	  --data-binary '{"title":"hello"}'
+//
+// The headers added by the middlewares of the RequestExecutor, such as authentication, are not included,
+// and the headers of the request are included as is, credentials too. A body which can only be read once,
+// see WithRawPayload, is rendered as read from the standard input.
+func (r *Request[T]) AsCurl() string {
+	built := *r
+	built.progress = nil
+
+	req, err := built.buildRequest(context.Background())
+	if err != nil {
+		return "# " + err.Error()
+	}
+
+	var b strings.Builder
+	b.WriteString("curl")
+
+	switch req.Method {
+	case http.MethodGet:
+	case http.MethodHead:
+		b.WriteString(" --head")
+	default:
+		b.WriteString(" -X " + req.Method)
+	}
+
+	b.WriteString(" " + shellQuote(req.URL.String()))
+
+	keys := make([]string, 0, len(req.Header))
+	for k := range req.Header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		for _, v := range req.Header[k] {
+			fmt.Fprintf(&b, " \\\n  -H %s", shellQuote(k+": "+v))
+		}
+	}
+
+	switch {
+	case req.Body == nil || req.Body == http.NoBody:
+	case req.GetBody == nil:
+		b.WriteString(" \\\n  --data-binary @-")
+	default:
+		body, err := req.GetBody()
+		if err != nil {
+			return "# could not read body of request " + r.url + ": " + err.Error()
+		}
+		data, err := io.ReadAll(body)
+		body.Close()
+		if err != nil {
+			return "# could not read body of request " + r.url + ": " + err.Error()
+		}
+
+		if len(data) > 0 {
+			b.WriteString(" \\\n  --data-binary " + shellQuote(string(data)))
+		}
+	}
+
+	return b.String()
+}
+
+// shellQuote quotes s as a single shell word. Text is single quoted, other bytes use the $'...' quoting of bash and zsh.
+func shellQuote(s string) string {
+	if utf8.ValidString(s) && !strings.ContainsRune(s, 0) {
+		return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+	}
+
+	var b strings.Builder
+	b.WriteString("$'")
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\'' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c >= 0x20 && c < 0x7f:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, `\x%02x`, c)
+		}
+	}
+	b.WriteString("'")
+
+	return b.String()
+}
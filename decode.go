@@ -0,0 +1,179 @@
+package swiftreq
+
+import (
+	"encoding"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// RawBytes is a result type which receives the response body as is, without any decoding.
+type RawBytes []byte
+
+// stream returns the live response body when the result type of the request is io.ReadCloser and the response is successful.
+// The caller is then responsible for reading and closing the body.
+func (r *Request[T]) stream(res *http.Response) (*T, bool) {
+	var responseObject T
+
+	v, ok := any(&responseObject).(*io.ReadCloser)
+	if !ok || res.StatusCode >= http.StatusBadRequest {
+		return nil, false
+	}
+
+	*v = res.Body
+
+	return &responseObject, true
+}
+
+// decode converts the response body into the result type of the request.
+// Raw byte results receive the body as is. Otherwise the codec selected by WithCodec or WithAccept is used, then the codec registered for the content type,
+// then JSON for JSON or unspecified content types,
+// XML for struct results of XML content types, and the html tags of struct results for HTML. The remaining ones are decoded with the encoding.TextUnmarshaler or encoding.BinaryUnmarshaler of the result type, or converted from plain text.
+func (r *Request[T]) decode(contentType string, data []byte) (T, error) {
+	var responseObject T
+
+	switch v := any(&responseObject).(type) {
+	case *[]byte:
+		*v = data
+		return responseObject, nil
+	case *RawBytes:
+		*v = data
+		return responseObject, nil
+	}
+
+	c, err := r.selectedCodec()
+	if err != nil {
+		return responseObject, err
+	}
+
+	if c == nil && r.accept != "" {
+		c, _ = r.re.codecFor(r.accept)
+	}
+
+	if c == nil && r.codec == nil {
+		c, _ = r.re.codecFor(contentType)
+	}
+
+	if c != nil {
+		err = c.Unmarshal(data, &responseObject)
+		return responseObject, err
+	}
+
+	_, acceptJSON := r.codec.(jsonCodec)
+	_, acceptHTML := r.codec.(htmlCodec)
+
+	if r.codec != nil && !acceptJSON && !acceptHTML {
+		err := r.codec.Unmarshal(data, &responseObject)
+		return responseObject, err
+	}
+
+	if acceptJSON || (r.codec == nil && (strings.Contains(contentType, "application/json") || contentType == "")) {
+		err := r.jsonCodec.Unmarshal(data, &responseObject)
+		return responseObject, err
+	}
+
+	if r.codec == nil && isXML(contentType) && isStruct(reflect.TypeOf(responseObject)) {
+		err := xmlCodec{}.Unmarshal(data, &responseObject)
+		return responseObject, err
+	}
+
+	if (acceptHTML || strings.Contains(contentType, "text/html")) && isStruct(reflect.TypeOf(responseObject)) {
+		err := htmlCodec{}.Unmarshal(data, &responseObject)
+		return responseObject, err
+	}
+
+	if ok, err := decodeUnmarshaler(contentType, data, &responseObject); ok {
+		return responseObject, err
+	}
+
+	err = decodeText(string(data), &responseObject)
+	return responseObject, err
+}
+
+// isStruct reports whether t is a struct, or a pointer to a struct, which does not decode itself from text.
+func isStruct(t reflect.Type) bool {
+	if t == nil {
+		return false
+	}
+
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	textUnmarshaler := reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+	return t.Kind() == reflect.Struct && !reflect.PointerTo(t).Implements(textUnmarshaler)
+}
+
+// decodeUnmarshaler decodes the body with the encoding.TextUnmarshaler or encoding.BinaryUnmarshaler implementation of the value pointed to by v.
+// When v points to a nil pointer, the pointed type is allocated and checked instead.
+// Textual content types prefer UnmarshalText, other content types prefer UnmarshalBinary.
+// It reports false when the value implements neither interface.
+func decodeUnmarshaler(contentType string, data []byte, v any) (bool, error) {
+	target := v
+	if rv := reflect.ValueOf(v).Elem(); rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+		target = rv.Interface()
+	}
+
+	tu, isText := target.(encoding.TextUnmarshaler)
+	bu, isBinary := target.(encoding.BinaryUnmarshaler)
+
+	textual := strings.HasPrefix(strings.ToLower(contentType), "text/")
+	switch {
+	case isText && (textual || !isBinary):
+		return true, tu.UnmarshalText(data)
+	case isBinary:
+		return true, bu.UnmarshalBinary(data)
+	default:
+		if target != v {
+			reflect.ValueOf(v).Elem().SetZero()
+		}
+		return false, nil
+	}
+}
+
+// decodeText converts a plain text body into the value pointed to by v.
+// Strings, booleans, integers, unsigned integers and floats, including named types based on them, are parsed from the trimmed text.
+func decodeText(text string, v any) error {
+	rv := reflect.ValueOf(v).Elem()
+	trimmed := strings.TrimSpace(text)
+
+	switch rv.Kind() {
+	case reflect.String:
+		rv.SetString(text)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(trimmed)
+		if err != nil {
+			return err
+		}
+		rv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(trimmed, 10, rv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		rv.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u, err := strconv.ParseUint(trimmed, 10, rv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		rv.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(trimmed, rv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		rv.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported conversion type: %s", rv.Type())
+	}
+
+	return nil
+}
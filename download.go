@@ -0,0 +1,106 @@
+package swiftreq
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/liviudnicoara/swiftreq/middlewares"
+)
+
+// DoStream executes the HTTP request and returns the response body as received, without reading it into memory.
+// The caller must close the body. Unsuccessful status codes fail the call as Do does, with the body read into the error.
+func (r *Request[T]) DoStream(ctx context.Context) (io.ReadCloser, error) {
+	req, res, _, err := r.send(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode < http.StatusBadRequest {
+		return res.Body, nil
+	}
+
+	defer middlewares.DrainBody(res)
+
+	body, _ := io.ReadAll(res.Body)
+
+	return nil, r.fail(&Error{
+		Message:    fmt.Sprintf("error calling %s", req.URL),
+		Cause:      classifyStatusError(res.StatusCode, body),
+		StatusCode: res.StatusCode,
+	}, req, res, body)
+}
+
+// DownloadToFile executes the HTTP request and streams the response body to the file at path, returning the number of bytes written.
+// The body is written to a temporary file in the same directory, which replaces the file at path once the download completed,
+// so that a failed download never leaves a truncated file behind.
+func (r *Request[T]) DownloadToFile(ctx context.Context, path string) (int64, error) {
+	req, res, _, err := r.send(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer middlewares.DrainBody(res)
+
+	if res.StatusCode >= http.StatusBadRequest {
+		body, _ := io.ReadAll(res.Body)
+
+		return 0, r.fail(&Error{
+			Message:    fmt.Sprintf("error calling %s", req.URL),
+			Cause:      classifyStatusError(res.StatusCode, body),
+			StatusCode: res.StatusCode,
+		}, req, res, body)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.part")
+	if err != nil {
+		return 0, &Error{Message: "could not create file for download " + r.url, Cause: err, Method: req.Method, URL: req.URL.String()}
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return 0, &Error{Message: "could not create file for download " + r.url, Cause: err, Method: req.Method, URL: req.URL.String()}
+	}
+
+	body := &readErrRecorder{r: res.Body}
+	n, err := io.Copy(tmp, body)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+
+	if body.err != nil {
+		return n, r.fail(&Error{
+			Message:    "failed to read response body for url request " + r.url,
+			Cause:      classifyTransportError(req.Context(), body.err),
+			StatusCode: res.StatusCode,
+		}, req, res, nil)
+	}
+
+	if err != nil {
+		return n, &Error{Message: "could not write download " + r.url + " to " + path, Cause: err, Method: req.Method, URL: req.URL.String()}
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return n, &Error{Message: "could not write download " + r.url + " to " + path, Cause: err, Method: req.Method, URL: req.URL.String()}
+	}
+
+	return n, nil
+}
+
+// readErrRecorder records the error returned by the reader, to tell it apart from the errors of the writer in io.Copy.
+type readErrRecorder struct {
+	r   io.Reader
+	err error
+}
+
+func (e *readErrRecorder) Read(p []byte) (int, error) {
+	n, err := e.r.Read(p)
+	if err != nil && err != io.EOF {
+		e.err = err
+	}
+
+	return n, err
+}
@@ -0,0 +1,86 @@
+package swiftreq
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// maxDumpBody bounds the bytes of a body included in a dump.
+const maxDumpBody = 4 << 10
+
+// redactedHeaders lists the headers whose values are hidden in dumps.
+var redactedHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"Set-Cookie":          true,
+	"X-Api-Key":           true,
+}
+
+// withDump attaches the dump of the exchange to e when debug dumps are enabled.
+// res and body may be nil when no response was received or read.
+func (re *RequestExecutor) withDump(e *Error, req *http.Request, res *http.Response, body []byte) *Error {
+	if !re.debugDumps.Load() {
+		return e
+	}
+
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%s %s %s\n", req.Method, req.URL, req.Proto)
+	dumpHeader(&b, req.Header)
+
+	if req.GetBody != nil {
+		if rb, err := req.GetBody(); err == nil {
+			reqBody, _ := io.ReadAll(io.LimitReader(rb, maxDumpBody+1))
+			rb.Close()
+			dumpBody(&b, reqBody)
+		}
+	}
+
+	if res != nil {
+		fmt.Fprintf(&b, "\n%s %s\n", res.Proto, res.Status)
+		dumpHeader(&b, res.Header)
+		dumpBody(&b, body)
+	}
+
+	e.dump = b.String()
+
+	return e
+}
+
+// dumpHeader writes the headers sorted by name, redacting credentials.
+func dumpHeader(b *strings.Builder, h http.Header) {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		for _, v := range h[k] {
+			if redactedHeaders[http.CanonicalHeaderKey(k)] {
+				v = "[REDACTED]"
+			}
+			fmt.Fprintf(b, "%s: %s\n", k, v)
+		}
+	}
+}
+
+// dumpBody writes the body, truncated to maxDumpBody bytes.
+func dumpBody(b *strings.Builder, body []byte) {
+	if len(body) == 0 {
+		return
+	}
+
+	b.WriteString("\n")
+	if len(body) > maxDumpBody {
+		fmt.Fprintf(b, "%s\n[truncated]\n", body[:maxDumpBody])
+		return
+	}
+
+	b.Write(body)
+	b.WriteString("\n")
+}
@@ -2,6 +2,11 @@ package swiftreq
 
 import (
 	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/liviudnicoara/swiftreq/middlewares"
 )
 
 // Error represents an error that may occur during an HTTP request.
@@ -9,13 +14,44 @@ type Error struct {
 	Message    string
 	Cause      error
 	StatusCode int
+
+	// Method and URL identify the failed request. They are empty when the request could not be built.
+	Method string
+	URL    string
+
+	// Attempts is the number of times the request was sent, and Backoff the total time waited between the attempts.
+	Attempts int
+	Backoff  time.Duration
+
+	header http.Header
+	body   []byte
+	dump   string
 }
 
+// Header returns the headers of the response which led to the error, such as Retry-After or a correlation ID.
+// It is nil when no response was received.
+func (e *Error) Header() http.Header { return e.header }
+
+// Body returns the raw body of the response which led to the error. It is nil when no response body was read.
+func (e *Error) Body() []byte { return e.body }
+
+// Dump returns a redacted dump of the request and of the response which led to the error.
+// It is empty unless debug dumps are enabled on the RequestExecutor, see RequestExecutor.WithDebugDumps.
+func (e *Error) Dump() string { return e.dump }
+
 // Error returns a formatted error message including the original cause and status code.
 func (e *Error) Error() string {
-	return fmt.Sprintf("message: %s\n cause: %s\n statusCode: %d", e.Message, e.Cause.Error(), e.StatusCode)
+	cause := "<nil>"
+	if e.Cause != nil {
+		cause = e.Cause.Error()
+	}
+
+	return fmt.Sprintf("message: %s\n cause: %s\n statusCode: %d", e.Message, cause, e.StatusCode)
 }
 
+// Unwrap returns the original cause so the error kind can be inspected with errors.As.
+func (e *Error) Unwrap() error { return e.Cause }
+
 // Response represents the result of an HTTP request.
 type Response struct {
 	Data       interface{}
@@ -23,3 +59,50 @@ type Response struct {
 	Error      error
 	StatusCode int
 }
+
+// StructValidator validates a struct using its field tags.
+// It is satisfied by *validator.Validate from github.com/go-playground/validator.
+type StructValidator interface {
+	Struct(s interface{}) error
+}
+
+// ResponseMeta holds the metadata of a received HTTP response.
+type ResponseMeta struct {
+	StatusCode int
+	Header     http.Header
+	// Trailer holds the trailers sent by the server after the body.
+	// With streamed results, only the trailers announced in the Trailer header are set, once the body is read to the end.
+	Trailer http.Header
+
+	// FinalURL is the URL of the request which produced the response, after following redirects.
+	FinalURL *url.URL
+	// Redirects lists the URLs requested before FinalURL, starting with the original one. It is empty when no redirect was followed.
+	Redirects []*url.URL
+
+	// Attempts is the number of times the request was sent, and Backoff the total time waited between the attempts.
+	Attempts int
+	Backoff  time.Duration
+}
+
+// newResponseMeta extracts the metadata of the response, walking back the redirect chain recorded in the requests.
+func newResponseMeta(res *http.Response, stats *middlewares.RetryStats) *ResponseMeta {
+	meta := &ResponseMeta{
+		StatusCode: res.StatusCode,
+		Header:     res.Header,
+		Trailer:    res.Trailer,
+		Attempts:   max(1, stats.Attempts),
+		Backoff:    stats.Backoff,
+	}
+
+	if res.Request == nil {
+		return meta
+	}
+
+	meta.FinalURL = res.Request.URL
+
+	for prev := res.Request.Response; prev != nil && prev.Request != nil; prev = prev.Request.Response {
+		meta.Redirects = append([]*url.URL{prev.Request.URL}, meta.Redirects...)
+	}
+
+	return meta
+}
@@ -0,0 +1,77 @@
+package swiftreq
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Environment variables read by the default RequestExecutor.
+const (
+	// EnvTimeout sets the client timeout, as a duration ("10s") or a number of seconds.
+	EnvTimeout = "SWIFTREQ_TIMEOUT"
+	// EnvRetries enables exponential retry with the given retry count. Zero leaves the retries disabled.
+	EnvRetries = "SWIFTREQ_RETRIES"
+	// EnvIgnore disables every environment based configuration of the default RequestExecutor, including HTTP_PROXY, HTTPS_PROXY and NO_PROXY.
+	EnvIgnore = "SWIFTREQ_IGNORE_ENV"
+)
+
+// WithEnvironment configures the RequestExecutor from the SWIFTREQ_TIMEOUT and SWIFTREQ_RETRIES environment variables.
+// The proxy is resolved from HTTP_PROXY, HTTPS_PROXY and NO_PROXY by the default transport used when the http.Client has none.
+// Invalid values are logged and ignored.
+func (re *RequestExecutor) WithEnvironment() *RequestExecutor {
+	if v, ok := os.LookupEnv(EnvTimeout); ok {
+		if timeout, err := parseEnvDuration(v); err == nil {
+			re.WithTimeout(timeout)
+		} else {
+			re.Logger.Warn("Invalid environment variable", "Name", EnvTimeout, "Value", v, "Error", err)
+		}
+	}
+
+	if v, ok := os.LookupEnv(EnvRetries); ok {
+		retries, err := strconv.Atoi(v)
+		if err == nil && retries < 0 {
+			err = errors.New("negative retry count")
+		}
+
+		if err != nil {
+			re.Logger.Warn("Invalid environment variable", "Name", EnvRetries, "Value", v, "Error", err)
+		} else if retries > 0 {
+			re.WithExponentialRetry(retries)
+		}
+	}
+
+	return re
+}
+
+// withoutEnvironment makes the RequestExecutor ignore the proxy environment variables.
+func (re *RequestExecutor) withoutEnvironment() *RequestExecutor {
+	re.updateClient(func(c *http.Client) {
+		if c.Transport != nil && c.Transport != http.DefaultTransport {
+			return
+		}
+
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		t.Proxy = nil
+		c.Transport = t
+	})
+
+	return re
+}
+
+// ignoreEnvironment reports whether SWIFTREQ_IGNORE_ENV is set to a true value.
+func ignoreEnvironment() bool {
+	ignore, _ := strconv.ParseBool(os.Getenv(EnvIgnore))
+	return ignore
+}
+
+// parseEnvDuration parses a duration such as "10s", or a plain number of seconds.
+func parseEnvDuration(v string) (time.Duration, error) {
+	if seconds, err := strconv.ParseFloat(v, 64); err == nil {
+		return time.Duration(seconds * float64(time.Second)), nil
+	}
+
+	return time.ParseDuration(v)
+}
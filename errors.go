@@ -0,0 +1,268 @@
+package swiftreq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+)
+
+// ConnectionError indicates that the request could not reach the server or that the transport failed while exchanging data.
+type ConnectionError struct {
+	Err error
+}
+
+// Error returns the message of the underlying transport error.
+func (e *ConnectionError) Error() string {
+	return fmt.Sprintf("connection error: %s", e.Err)
+}
+
+// Unwrap returns the underlying transport error.
+func (e *ConnectionError) Unwrap() error { return e.Err }
+
+// TimeoutError indicates that the request did not complete before a deadline or client timeout expired.
+// Cause holds the value of context.Cause when the deadline of the request context expired.
+type TimeoutError struct {
+	Err   error
+	Cause error
+}
+
+// Error returns the message of the underlying timeout error.
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("timeout: %s", withCause(e.Err, e.Cause))
+}
+
+// Unwrap returns the underlying timeout error and, when the request context expired, context.DeadlineExceeded and its cause.
+func (e *TimeoutError) Unwrap() []error { return unwrapCause(e.Err, e.Cause, context.DeadlineExceeded) }
+
+// CanceledError indicates that the request context was canceled before the request completed.
+// Cause holds the value of context.Cause, e.g. the error passed to the context.CancelCauseFunc.
+type CanceledError struct {
+	Err   error
+	Cause error
+}
+
+// Error returns the message of the underlying error and the cancellation cause.
+func (e *CanceledError) Error() string {
+	return fmt.Sprintf("canceled: %s", withCause(e.Err, e.Cause))
+}
+
+// Unwrap returns the underlying error, context.Canceled and the cancellation cause.
+func (e *CanceledError) Unwrap() []error {
+	if e.Cause == nil {
+		return []error{e.Err, context.Canceled}
+	}
+
+	return unwrapCause(e.Err, e.Cause, context.Canceled)
+}
+
+// ServerError indicates that the server answered with a 5xx status code.
+type ServerError struct {
+	StatusCode int
+	Body       []byte
+}
+
+// Error returns the response body sent by the server.
+func (e *ServerError) Error() string {
+	return string(e.Body)
+}
+
+// ClientError indicates that the server answered with a 4xx status code.
+type ClientError struct {
+	StatusCode int
+	Body       []byte
+}
+
+// Error returns the response body sent by the server.
+func (e *ClientError) Error() string {
+	return string(e.Body)
+}
+
+// DecodeError indicates that the response body could not be converted into the requested type.
+type DecodeError struct {
+	ContentType string
+	Err         error
+}
+
+// Error returns the message of the underlying decoding error.
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("decode %q: %s", e.ContentType, e.Err)
+}
+
+// Unwrap returns the underlying decoding error.
+func (e *DecodeError) Unwrap() error { return e.Err }
+
+// ValidationError indicates that the decoded response was rejected by a validator.
+type ValidationError struct {
+	Err error
+}
+
+// Error returns the message of the validation error.
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("validation: %s", e.Err)
+}
+
+// Unwrap returns the validation error.
+func (e *ValidationError) Unwrap() error { return e.Err }
+
+// CircuitOpenError indicates that the request was rejected without being sent because a circuit breaker is open.
+type CircuitOpenError struct {
+	Err error
+}
+
+// Error returns the message of the error reported by the circuit breaker.
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("circuit open: %s", e.Err)
+}
+
+// Unwrap returns the error reported by the circuit breaker.
+func (e *CircuitOpenError) Unwrap() error { return e.Err }
+
+// LoadShedError indicates that the request was rejected without being sent because of its low priority while the load is high.
+type LoadShedError struct {
+	Err error
+}
+
+// Error returns the reason the request was shed.
+func (e *LoadShedError) Error() string {
+	return fmt.Sprintf("load shed: %s", e.Err)
+}
+
+// Unwrap returns the reason the request was shed.
+func (e *LoadShedError) Unwrap() error { return e.Err }
+
+// SignatureError indicates that the response was rejected because its signature is missing or invalid.
+type SignatureError struct {
+	Err error
+}
+
+// Error returns the reason of the verification failure.
+func (e *SignatureError) Error() string {
+	return fmt.Sprintf("signature: %s", e.Err)
+}
+
+// Unwrap returns the reason of the verification failure.
+func (e *SignatureError) Unwrap() error { return e.Err }
+
+// RateLimitError indicates that the request was rejected without being sent because the client-side rate limit was reached.
+type RateLimitError struct {
+	Err error
+}
+
+// Error returns the message of the error reported by the rate limiter.
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("rate limited: %s", e.Err)
+}
+
+// Unwrap returns the error reported by the rate limiter.
+func (e *RateLimitError) Unwrap() error { return e.Err }
+
+// StallError indicates that the transfer was aborted because no response bytes were received for the idle window of the stall watchdog.
+type StallError struct {
+	Err error
+}
+
+// Error returns the message of the error reported by the watchdog.
+func (e *StallError) Error() string {
+	return fmt.Sprintf("stalled: %s", e.Err)
+}
+
+// Unwrap returns the error reported by the watchdog.
+func (e *StallError) Unwrap() error { return e.Err }
+
+// circuitOpener is implemented by errors which are returned when a circuit breaker rejects a request.
+type circuitOpener interface {
+	CircuitOpen() bool
+}
+
+// loadShedder is implemented by errors which are returned when a request is shed.
+type loadShedder interface {
+	LoadShed() bool
+}
+
+// signatureRejecter is implemented by errors which are returned when a response fails signature verification.
+type signatureRejecter interface {
+	SignatureInvalid() bool
+}
+
+// rateLimiter is implemented by errors which are returned when a rate limiter rejects a request.
+type rateLimiter interface {
+	RateLimited() bool
+}
+
+// staller is implemented by errors which are returned when a stalled transfer is aborted.
+type staller interface {
+	Stalled() bool
+}
+
+// classifyTransportError wraps an error returned by the pipeline into the matching error kind.
+// Errors caused by the end of ctx carry its context.Cause.
+func classifyTransportError(ctx context.Context, err error) error {
+	var co circuitOpener
+	if errors.As(err, &co) && co.CircuitOpen() {
+		return &CircuitOpenError{Err: err}
+	}
+
+	var ls loadShedder
+	if errors.As(err, &ls) && ls.LoadShed() {
+		return &LoadShedError{Err: err}
+	}
+
+	var sr signatureRejecter
+	if errors.As(err, &sr) && sr.SignatureInvalid() {
+		return &SignatureError{Err: err}
+	}
+
+	var rl rateLimiter
+	if errors.As(err, &rl) && rl.RateLimited() {
+		return &RateLimitError{Err: err}
+	}
+
+	var st staller
+	if errors.As(err, &st) && st.Stalled() {
+		return &StallError{Err: err}
+	}
+
+	if ctx.Err() != nil {
+		cause := context.Cause(ctx)
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return &TimeoutError{Err: err, Cause: cause}
+		}
+
+		return &CanceledError{Err: err, Cause: cause}
+	}
+
+	var ne net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &ne) && ne.Timeout()) {
+		return &TimeoutError{Err: err}
+	}
+
+	return &ConnectionError{Err: err}
+}
+
+// withCause appends cause to the message of err unless err already reports it.
+func withCause(err, cause error) string {
+	if cause == nil || errors.Is(err, cause) {
+		return err.Error()
+	}
+
+	return fmt.Sprintf("%s (cause: %s)", err, cause)
+}
+
+// unwrapCause returns err alone when there is no cause, otherwise err, cause and the context error the cause stands for.
+func unwrapCause(err, cause, ctxErr error) []error {
+	if cause == nil {
+		return []error{err}
+	}
+
+	return []error{err, cause, ctxErr}
+}
+
+// classifyStatusError returns the error kind matching an unsuccessful status code.
+func classifyStatusError(statusCode int, body []byte) error {
+	if statusCode >= 500 {
+		return &ServerError{StatusCode: statusCode, Body: body}
+	}
+
+	return &ClientError{StatusCode: statusCode, Body: body}
+}
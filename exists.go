@@ -0,0 +1,24 @@
+package swiftreq
+
+import (
+	"context"
+	"net/http"
+)
+
+// Exists sends a HEAD request through the default RequestExecutor and reports whether the resource exists.
+// 2xx responses report true, and 404 Not Found and 410 Gone report false, without being reported as errors.
+// Other failures are returned as errors.
+func Exists(ctx context.Context, url string) (bool, error) {
+	req := newDefaultRequest[RawBytes]().
+		WithMethod("HEAD").
+		WithURL(url).
+		WithAbsentStatus(http.StatusNotFound, http.StatusGone)
+	req.maybe = true
+
+	_, meta, err := req.DoWithResponse(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	return meta.StatusCode != http.StatusNotFound && meta.StatusCode != http.StatusGone, nil
+}
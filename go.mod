@@ -1,12 +1,20 @@
 module github.com/liviudnicoara/swiftreq
 
-go 1.20
+go 1.23
 
-require github.com/stretchr/testify v1.8.4
+require (
+	github.com/andybalholm/cascadia v1.3.2
+	github.com/klauspost/compress v1.17.11
+	github.com/patrickmn/go-cache v2.1.0+incompatible
+	github.com/stretchr/testify v1.8.4
+	golang.org/x/net v0.31.0
+	golang.org/x/oauth2 v0.24.0
+	google.golang.org/protobuf v1.36.12
+)
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
-	github.com/patrickmn/go-cache v2.1.0+incompatible // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
+	golang.org/x/text v0.20.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -0,0 +1,239 @@
+package swiftreq
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/liviudnicoara/swiftreq/middlewares"
+)
+
+// HARRecorderOptions configures a HARRecorder.
+type HARRecorderOptions struct {
+	// MaxBodySize is the number of bytes of each body kept in the archive, the rest is left out. It defaults to 1 MB.
+	MaxBodySize int
+	// IncludeCredentials records the values of the headers carrying credentials, such as Authorization and Cookie.
+	// They are recorded as [REDACTED] otherwise, as for debug dumps.
+	IncludeCredentials bool
+}
+
+// HARRecorder records the exchanges sent through executors as an HTTP Archive, to inspect them in browser devtools or other HAR tools.
+// It is safe for concurrent use, and can record the exchanges of several executors.
+type HARRecorder struct {
+	opts HARRecorderOptions
+
+	mu      sync.Mutex
+	entries []HAREntry
+}
+
+// NewHARRecorder creates a HARRecorder with the provided options.
+func NewHARRecorder(opts HARRecorderOptions) *HARRecorder {
+	if opts.MaxBodySize <= 0 {
+		opts.MaxBodySize = 1 << 20
+	}
+
+	return &HARRecorder{opts: opts}
+}
+
+// RecordHAR adds a middleware recording the exchanges of the RequestExecutor to the recorder.
+// An exchange is recorded once its response body is read or closed. Exchanges failing without response are recorded with a zero status.
+// Added after the retry, each request is recorded once, with its last response. Added before it, every attempt is recorded.
+func (re *RequestExecutor) RecordHAR(recorder *HARRecorder) *RequestExecutor {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+
+	re.addMiddlewares(recorder.Middleware())
+
+	return re
+}
+
+// Middleware returns the middleware recording the exchanges to the recorder.
+func (rec *HARRecorder) Middleware() middlewares.Middleware {
+	return func(next middlewares.Handler) middlewares.Handler {
+		return func(req *http.Request) (*http.Response, error) {
+			entry := HAREntry{StartedDateTime: time.Now(), Request: rec.request(req)}
+
+			resp, err := next(req)
+			if err != nil || resp == nil {
+				rec.add(entry, HARResponse{HTTPVersion: req.Proto, Headers: []HARNameValue{}, HeadersSize: -1, BodySize: -1})
+				return resp, err
+			}
+
+			if resp.Body == nil || resp.Body == http.NoBody {
+				rec.add(entry, rec.response(resp, nil, 0))
+				return resp, err
+			}
+
+			resp.Body = &harBody{ReadCloser: resp.Body, done: func(body []byte, size int) {
+				rec.add(entry, rec.response(resp, body, size))
+			}, max: rec.opts.MaxBodySize}
+
+			return resp, err
+		}
+	}
+}
+
+// HAR returns a copy of the archive recorded so far.
+func (rec *HARRecorder) HAR() *HAR {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	return &HAR{Log: HARLog{
+		Version: "1.2",
+		Creator: HARCreator{Name: "swiftreq", Version: "1.0"},
+		Entries: append([]HAREntry{}, rec.entries...),
+	}}
+}
+
+// WriteTo writes the archive recorded so far to w as JSON.
+func (rec *HARRecorder) WriteTo(w io.Writer) (int64, error) {
+	data, err := json.MarshalIndent(rec.HAR(), "", "  ")
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := w.Write(data)
+	return int64(n), err
+}
+
+// Save writes the archive recorded so far to the file at path, replacing it.
+func (rec *HARRecorder) Save(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if _, err := rec.WriteTo(f); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// add completes the entry with the response and appends it to the archive.
+func (rec *HARRecorder) add(entry HAREntry, resp HARResponse) {
+	entry.Response = resp
+	entry.Time = float64(time.Since(entry.StartedDateTime).Microseconds()) / 1000
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	rec.entries = append(rec.entries, entry)
+}
+
+// request records the request, with its body when it can be read again, see http.Request.GetBody.
+func (rec *HARRecorder) request(req *http.Request) HARRequest {
+	hr := HARRequest{
+		Method:      req.Method,
+		URL:         req.URL.String(),
+		HTTPVersion: req.Proto,
+		Headers:     rec.headers(req.Header),
+		QueryString: []HARNameValue{},
+		HeadersSize: -1,
+		BodySize:    int(req.ContentLength),
+	}
+
+	query := req.URL.Query()
+	for _, name := range sortedKeys(query) {
+		for _, v := range query[name] {
+			hr.QueryString = append(hr.QueryString, HARNameValue{Name: name, Value: v})
+		}
+	}
+
+	if req.GetBody != nil && req.ContentLength != 0 {
+		if body, err := req.GetBody(); err == nil {
+			data, _ := io.ReadAll(io.LimitReader(body, int64(rec.opts.MaxBodySize)))
+			body.Close()
+			hr.PostData = &HARPostData{MimeType: req.Header.Get("Content-Type"), Text: string(data)}
+		}
+	}
+
+	return hr
+}
+
+// response records the response with the kept bytes of its body, base64 encoded when they are not text, and the size of the body read.
+func (rec *HARRecorder) response(resp *http.Response, body []byte, size int) HARResponse {
+	hr := HARResponse{
+		Status:      resp.StatusCode,
+		StatusText:  http.StatusText(resp.StatusCode),
+		HTTPVersion: resp.Proto,
+		Headers:     rec.headers(resp.Header),
+		Content:     HARContent{Size: size, MimeType: resp.Header.Get("Content-Type")},
+		RedirectURL: resp.Header.Get("Location"),
+		HeadersSize: -1,
+		BodySize:    size,
+	}
+
+	if utf8.Valid(body) {
+		hr.Content.Text = string(body)
+	} else {
+		hr.Content.Text = base64.StdEncoding.EncodeToString(body)
+		hr.Content.Encoding = "base64"
+	}
+
+	return hr
+}
+
+// headers records the headers sorted by name, redacting credentials unless they are included.
+func (rec *HARRecorder) headers(h http.Header) []HARNameValue {
+	headers := []HARNameValue{}
+
+	for _, name := range sortedKeys(h) {
+		for _, v := range h[name] {
+			if !rec.opts.IncludeCredentials && redactedHeaders[http.CanonicalHeaderKey(name)] {
+				v = "[REDACTED]"
+			}
+			headers = append(headers, HARNameValue{Name: name, Value: v})
+		}
+	}
+
+	return headers
+}
+
+// sortedKeys returns the names of the header or query values, sorted.
+func sortedKeys(values map[string][]string) []string {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// harBody keeps the first max bytes read from a response body, and reports them with the size read once the body is read to the end or closed.
+type harBody struct {
+	io.ReadCloser
+	done func(body []byte, size int)
+	max  int
+	buf  bytes.Buffer
+	size int
+	once sync.Once
+}
+
+func (b *harBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.size += n
+	if keep := min(n, b.max-b.buf.Len()); keep > 0 {
+		b.buf.Write(p[:keep])
+	}
+
+	if err != nil {
+		b.once.Do(func() { b.done(b.buf.Bytes(), b.size) })
+	}
+
+	return n, err
+}
+
+func (b *harBody) Close() error {
+	b.once.Do(func() { b.done(b.buf.Bytes(), b.size) })
+	return b.ReadCloser.Close()
+}
@@ -0,0 +1,176 @@
+package swiftreq
+
+import (
+	"bytes"
+	"encoding"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/cascadia"
+	"golang.org/x/net/html"
+)
+
+// selectors caches the compiled CSS selectors of the html tags.
+var selectors sync.Map
+
+// htmlCodec decodes HTML documents into structs whose fields carry a CSS selector in their html tag:
+//
+//	type Status struct {
+//		Title      string   `html:"h1"`
+//		Components []string `html:".component .name"`
+//		Logo       string   `html:"img.logo,attr=src"`
+//	}
+//
+// A field receives the trimmed text of the first matching element, or the value of the attribute named by attr.
+// Slice fields receive every match, and struct fields are extracted from the first matching element, which scopes their selectors.
+// An empty selector designates the element itself.
+type htmlCodec struct{}
+
+// Unmarshal parses the HTML document and extracts the tagged fields of the struct pointed to by v.
+func (htmlCodec) Unmarshal(data []byte, v any) error {
+	doc, err := html.Parse(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	rv := reflect.ValueOf(v).Elem()
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+		rv = rv.Elem()
+	}
+
+	if rv.Kind() != reflect.Struct {
+		return fmt.Errorf("unsupported html decoding type: %s", rv.Type())
+	}
+
+	return extractHTML(doc, rv)
+}
+
+// extractHTML fills the tagged fields of the struct value rv from the node.
+func extractHTML(node *html.Node, rv reflect.Value) error {
+	t := rv.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		tag, ok := t.Field(i).Tag.Lookup("html")
+		if !ok || !t.Field(i).IsExported() {
+			continue
+		}
+
+		selector, attr := parseHTMLTag(tag)
+
+		matches, err := selectAll(node, selector)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", t.Field(i).Name, err)
+		}
+
+		field := rv.Field(i)
+		if field.Kind() == reflect.Slice && field.Type().Elem().Kind() != reflect.Uint8 {
+			items := reflect.MakeSlice(field.Type(), len(matches), len(matches))
+			for j, m := range matches {
+				if err := setHTMLValue(m, attr, items.Index(j)); err != nil {
+					return fmt.Errorf("field %s: %w", t.Field(i).Name, err)
+				}
+			}
+			field.Set(items)
+			continue
+		}
+
+		if len(matches) == 0 {
+			continue
+		}
+
+		if err := setHTMLValue(matches[0], attr, field); err != nil {
+			return fmt.Errorf("field %s: %w", t.Field(i).Name, err)
+		}
+	}
+
+	return nil
+}
+
+// setHTMLValue stores the value extracted from the node into v.
+func setHTMLValue(node *html.Node, attr string, v reflect.Value) error {
+	if v.Kind() == reflect.Pointer {
+		v.Set(reflect.New(v.Type().Elem()))
+		v = v.Elem()
+	}
+
+	text := nodeText(node)
+	if attr != "" {
+		text = nodeAttr(node, attr)
+	}
+
+	if tu, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return tu.UnmarshalText([]byte(text))
+	}
+
+	if v.Kind() == reflect.Struct {
+		return extractHTML(node, v)
+	}
+
+	return decodeText(strings.TrimSpace(text), v.Addr().Interface())
+}
+
+// parseHTMLTag splits an html tag into its selector and attribute name.
+func parseHTMLTag(tag string) (selector, attr string) {
+	selector, opts, _ := strings.Cut(tag, ",")
+	for _, opt := range strings.Split(opts, ",") {
+		if name, ok := strings.CutPrefix(opt, "attr="); ok {
+			attr = name
+		}
+	}
+
+	return strings.TrimSpace(selector), attr
+}
+
+// selectAll returns the nodes matching the selector under node, or node itself for an empty selector.
+func selectAll(node *html.Node, selector string) ([]*html.Node, error) {
+	if selector == "" {
+		return []*html.Node{node}, nil
+	}
+
+	if sel, ok := selectors.Load(selector); ok {
+		return cascadia.QueryAll(node, sel.(cascadia.Sel)), nil
+	}
+
+	sel, err := cascadia.Parse(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	selectors.Store(selector, sel)
+
+	return cascadia.QueryAll(node, sel), nil
+}
+
+// nodeText returns the text content of the node with its whitespace trimmed.
+func nodeText(node *html.Node) string {
+	var b strings.Builder
+
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(node)
+
+	return strings.TrimSpace(b.String())
+}
+
+// nodeAttr returns the value of the attribute of the node, empty when it is missing.
+func nodeAttr(node *html.Node, name string) string {
+	for _, a := range node.Attr {
+		if a.Key == name {
+			return a.Val
+		}
+	}
+
+	return ""
+}
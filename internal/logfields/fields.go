@@ -0,0 +1,44 @@
+// Package logfields converts slog style arguments into structured fields for third party loggers.
+package logfields
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+// badKey is the key used for a value which has no key, following the slog convention.
+const badKey = "!BADKEY"
+
+// Map converts alternating keys and values, or slog.Attr values, into a map of fields.
+func Map(args ...any) map[string]any {
+	fields := make(map[string]any, len(args)/2)
+
+	Range(args, func(key string, value any) {
+		fields[key] = value
+	})
+
+	return fields
+}
+
+// Range calls fn with each key and value of alternating keys and values, or slog.Attr values, in order.
+// Values without a string key are reported under the !BADKEY key, as slog does.
+func Range(args []any, fn func(key string, value any)) {
+	for len(args) > 0 {
+		switch k := args[0].(type) {
+		case slog.Attr:
+			fn(k.Key, k.Value.Resolve().Any())
+			args = args[1:]
+		case string:
+			if len(args) == 1 {
+				fn(badKey, k)
+				return
+			}
+
+			fn(k, args[1])
+			args = args[2:]
+		default:
+			fn(badKey, fmt.Sprint(k))
+			args = args[1:]
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package logfields_test
+
+import (
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/liviudnicoara/swiftreq/internal/logfields"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Map(t *testing.T) {
+	err := errors.New("boom")
+
+	tests := []struct {
+		name     string
+		args     []any
+		expected map[string]any
+	}{
+		{name: "Pairs", args: []any{"URL", "https://example.com", "Status", 200}, expected: map[string]any{"URL": "https://example.com", "Status": 200}},
+		{name: "Attrs", args: []any{slog.String("TraceID", "abc"), slog.Int("Attempt", 2)}, expected: map[string]any{"TraceID": "abc", "Attempt": int64(2)}},
+		{name: "Mixed", args: []any{slog.String("TraceID", "abc"), "Error", err}, expected: map[string]any{"TraceID": "abc", "Error": err}},
+		{name: "KeyWithoutValue", args: []any{"URL", "u", "dangling"}, expected: map[string]any{"URL": "u", "!BADKEY": "dangling"}},
+		{name: "NonStringKey", args: []any{42, "URL", "u"}, expected: map[string]any{"!BADKEY": "42", "URL": "u"}},
+		{name: "Empty", args: nil, expected: map[string]any{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// act
+			fields := logfields.Map(tt.args...)
+
+			// assert
+			assert.Equal(t, tt.expected, fields)
+		})
+	}
+}
+
+func Test_Range(t *testing.T) {
+	// arrange
+	var keys []string
+
+	// act
+	logfields.Range([]any{"b", 1, slog.Bool("a", true), "c", "x"}, func(key string, _ any) {
+		keys = append(keys, key)
+	})
+
+	// assert
+	assert.Equal(t, []string{"b", "a", "c"}, keys)
+}
@@ -0,0 +1,18 @@
+module github.com/liviudnicoara/swiftreq/logadapters/logrusadapter
+
+go 1.23
+
+require (
+	github.com/liviudnicoara/swiftreq v0.0.0-20261014132842-3f31cb671219
+	github.com/sirupsen/logrus v1.9.3
+	github.com/stretchr/testify v1.8.4
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/klauspost/compress v1.17.11 // indirect
+	github.com/patrickmn/go-cache v2.1.0+incompatible // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	golang.org/x/sys v0.27.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
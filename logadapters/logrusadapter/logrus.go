@@ -0,0 +1,30 @@
+// Package logrusadapter adapts a logrus logger to the middlewares.Logger interface.
+package logrusadapter
+
+import (
+	"github.com/liviudnicoara/swiftreq/internal/logfields"
+	"github.com/liviudnicoara/swiftreq/middlewares"
+	"github.com/sirupsen/logrus"
+)
+
+// logger writes the middleware logs to a logrus.FieldLogger.
+type logger struct {
+	fl logrus.FieldLogger
+}
+
+// New returns a middlewares.Logger writing to the given logrus logger or entry.
+func New(l logrus.FieldLogger) middlewares.Logger {
+	return &logger{fl: l}
+}
+
+// Debug logs a message at debug level.
+func (l *logger) Debug(msg string, args ...any) { l.fl.WithFields(logfields.Map(args...)).Debug(msg) }
+
+// Info logs a message at info level.
+func (l *logger) Info(msg string, args ...any) { l.fl.WithFields(logfields.Map(args...)).Info(msg) }
+
+// Warn logs a message at warn level.
+func (l *logger) Warn(msg string, args ...any) { l.fl.WithFields(logfields.Map(args...)).Warn(msg) }
+
+// Error logs a message at error level.
+func (l *logger) Error(msg string, args ...any) { l.fl.WithFields(logfields.Map(args...)).Error(msg) }
@@ -0,0 +1,37 @@
+package logrusadapter_test
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/liviudnicoara/swiftreq/logadapters/logrusadapter"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Logger(t *testing.T) {
+	// arrange
+	ll := logrus.New()
+	ll.SetOutput(io.Discard)
+	ll.SetLevel(logrus.DebugLevel)
+	hook := test.NewLocal(ll)
+	l := logrusadapter.New(ll)
+
+	// act
+	l.Debug("debug", "URL", "https://example.com")
+	l.Info("info", slog.String("TraceID", "abc"), "Status", 200)
+	l.Warn("warn", "dangling")
+	l.Error("error", "Error", "boom")
+
+	// assert
+	entries := hook.AllEntries()
+	assert.Len(t, entries, 4)
+	assert.Equal(t, logrus.DebugLevel, entries[0].Level)
+	assert.Equal(t, logrus.Fields{"URL": "https://example.com"}, entries[0].Data)
+	assert.Equal(t, logrus.Fields{"TraceID": "abc", "Status": 200}, entries[1].Data)
+	assert.Equal(t, logrus.Fields{"!BADKEY": "dangling"}, entries[2].Data)
+	assert.Equal(t, logrus.ErrorLevel, entries[3].Level)
+	assert.Equal(t, logrus.Fields{"Error": "boom"}, entries[3].Data)
+}
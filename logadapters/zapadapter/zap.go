@@ -0,0 +1,40 @@
+// Package zapadapter adapts a zap logger to the middlewares.Logger interface.
+package zapadapter
+
+import (
+	"github.com/liviudnicoara/swiftreq/internal/logfields"
+	"github.com/liviudnicoara/swiftreq/middlewares"
+	"go.uber.org/zap"
+)
+
+// logger writes the middleware logs to a zap.Logger.
+type logger struct {
+	zl *zap.Logger
+}
+
+// New returns a middlewares.Logger writing to the given zap logger.
+func New(l *zap.Logger) middlewares.Logger {
+	return &logger{zl: l}
+}
+
+// Debug logs a message at debug level.
+func (l *logger) Debug(msg string, args ...any) { l.zl.Debug(msg, fields(args)...) }
+
+// Info logs a message at info level.
+func (l *logger) Info(msg string, args ...any) { l.zl.Info(msg, fields(args)...) }
+
+// Warn logs a message at warn level.
+func (l *logger) Warn(msg string, args ...any) { l.zl.Warn(msg, fields(args)...) }
+
+// Error logs a message at error level.
+func (l *logger) Error(msg string, args ...any) { l.zl.Error(msg, fields(args)...) }
+
+// fields converts the arguments, which may include slog.Attr values, into zap fields, in order.
+func fields(args []any) []zap.Field {
+	fs := make([]zap.Field, 0, len(args)/2)
+	logfields.Range(args, func(key string, value any) {
+		fs = append(fs, zap.Any(key, value))
+	})
+
+	return fs
+}
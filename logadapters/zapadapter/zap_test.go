@@ -0,0 +1,36 @@
+package zapadapter_test
+
+import (
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/liviudnicoara/swiftreq/logadapters/zapadapter"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func Test_Logger(t *testing.T) {
+	// arrange
+	core, logs := observer.New(zapcore.DebugLevel)
+	l := zapadapter.New(zap.New(core, zap.Development()))
+	err := errors.New("boom")
+
+	// act
+	l.Debug("debug", "URL", "https://example.com")
+	l.Info("info", slog.String("TraceID", "abc"), "Status", 200)
+	l.Warn("warn", "dangling")
+	l.Error("error", "Error", err)
+
+	// assert
+	entries := logs.AllUntimed()
+	assert.Len(t, entries, 4)
+	assert.Equal(t, []zapcore.Level{zapcore.DebugLevel, zapcore.InfoLevel, zapcore.WarnLevel, zapcore.ErrorLevel},
+		[]zapcore.Level{entries[0].Level, entries[1].Level, entries[2].Level, entries[3].Level})
+	assert.Equal(t, map[string]any{"URL": "https://example.com"}, entries[0].ContextMap())
+	assert.Equal(t, map[string]any{"TraceID": "abc", "Status": int64(200)}, entries[1].ContextMap())
+	assert.Equal(t, map[string]any{"!BADKEY": "dangling"}, entries[2].ContextMap())
+	assert.Equal(t, "boom", entries[3].ContextMap()["Error"])
+}
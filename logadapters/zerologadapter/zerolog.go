@@ -0,0 +1,30 @@
+// Package zerologadapter adapts a zerolog logger to the middlewares.Logger interface.
+package zerologadapter
+
+import (
+	"github.com/liviudnicoara/swiftreq/internal/logfields"
+	"github.com/liviudnicoara/swiftreq/middlewares"
+	"github.com/rs/zerolog"
+)
+
+// logger writes the middleware logs to a zerolog.Logger.
+type logger struct {
+	zl zerolog.Logger
+}
+
+// New returns a middlewares.Logger writing to the given zerolog logger.
+func New(l zerolog.Logger) middlewares.Logger {
+	return &logger{zl: l}
+}
+
+// Debug logs a message at debug level.
+func (l *logger) Debug(msg string, args ...any) { l.zl.Debug().Fields(logfields.Map(args...)).Msg(msg) }
+
+// Info logs a message at info level.
+func (l *logger) Info(msg string, args ...any) { l.zl.Info().Fields(logfields.Map(args...)).Msg(msg) }
+
+// Warn logs a message at warn level.
+func (l *logger) Warn(msg string, args ...any) { l.zl.Warn().Fields(logfields.Map(args...)).Msg(msg) }
+
+// Error logs a message at error level.
+func (l *logger) Error(msg string, args ...any) { l.zl.Error().Fields(logfields.Map(args...)).Msg(msg) }
@@ -0,0 +1,39 @@
+package zerologadapter_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/liviudnicoara/swiftreq/logadapters/zerologadapter"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Logger(t *testing.T) {
+	// arrange
+	var buf bytes.Buffer
+	l := zerologadapter.New(zerolog.New(&buf).Level(zerolog.DebugLevel))
+
+	// act
+	l.Debug("debug", "URL", "https://example.com")
+	l.Info("info", slog.String("TraceID", "abc"), "Status", 200)
+	l.Warn("warn", "dangling")
+	l.Error("error", "Error", "boom")
+
+	// assert
+	var entries []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry map[string]any
+		assert.Nil(t, json.Unmarshal([]byte(line), &entry))
+		entries = append(entries, entry)
+	}
+
+	assert.Len(t, entries, 4)
+	assert.Equal(t, map[string]any{"level": "debug", "message": "debug", "URL": "https://example.com"}, entries[0])
+	assert.Equal(t, map[string]any{"level": "info", "message": "info", "TraceID": "abc", "Status": float64(200)}, entries[1])
+	assert.Equal(t, map[string]any{"level": "warn", "message": "warn", "!BADKEY": "dangling"}, entries[2])
+	assert.Equal(t, map[string]any{"level": "error", "message": "error", "Error": "boom"}, entries[3])
+}
@@ -0,0 +1,73 @@
+package swiftreq
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"slices"
+)
+
+// Maybe is an optional result, which is either present with a value or absent.
+type Maybe[T any] struct {
+	value   T
+	present bool
+}
+
+// Some returns a present Maybe holding v.
+func Some[T any](v T) Maybe[T] {
+	return Maybe[T]{value: v, present: true}
+}
+
+// None returns an absent Maybe.
+func None[T any]() Maybe[T] {
+	return Maybe[T]{}
+}
+
+// Get returns the value and whether it is present.
+func (m Maybe[T]) Get() (T, bool) { return m.value, m.present }
+
+// Present reports whether the value is present.
+func (m Maybe[T]) Present() bool { return m.present }
+
+// OrElse returns the value when it is present, def otherwise.
+func (m Maybe[T]) OrElse(def T) T {
+	if m.present {
+		return m.value
+	}
+
+	return def
+}
+
+// WithAbsentStatus makes DoMaybe report the responses with the status codes as absent, such as http.StatusNotFound for lookup endpoints.
+func (r *Request[T]) WithAbsentStatus(statusCodes ...int) *Request[T] {
+	r.absentStatus = append(r.absentStatus, statusCodes...)
+	return r
+}
+
+// DoMaybe executes the HTTP request and returns its result as a Maybe.
+// 204 No Content responses, empty bodies and the status codes set with WithAbsentStatus are absent instead of
+// a decoding error or an ambiguous zero value. Other failures are returned as errors, as with Do.
+func (r *Request[T]) DoMaybe(ctx context.Context) (Maybe[T], error) {
+	maybe := *r
+	maybe.maybe = true
+
+	resp, _, err := maybe.DoWithResponse(ctx)
+	if err != nil || resp == nil {
+		return None[T](), err
+	}
+
+	return Some(*resp), nil
+}
+
+// absent reports whether the response is an absent result of DoMaybe.
+func (r *Request[T]) absent(statusCode int, body []byte) bool {
+	if !r.maybe {
+		return false
+	}
+
+	if slices.Contains(r.absentStatus, statusCode) {
+		return true
+	}
+
+	return statusCode < http.StatusBadRequest && (statusCode == http.StatusNoContent || len(bytes.TrimSpace(body)) == 0)
+}
@@ -1,17 +1,32 @@
 package middlewares
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"log/slog"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
 )
 
 // lifeSpanSafetyMargin defines the safety margin for token lifespan.
+// minRefreshInterval bounds how often tokens are requested when the lifespan is too short or authorization fails.
 var (
 	lifeSpanSafetyMargin = 1 * time.Second
+	minRefreshInterval   = 1 * time.Second
 )
 
+// sharedRefreshers holds the token refreshers shared across executors, by key.
+var (
+	sharedRefreshersMu sync.Mutex
+	sharedRefreshers   = map[string]*pendingRefresher{}
+)
+
+// ErrTokenRefresherStopped is returned for the tokens of a stopped TokenRefresher.
+var ErrTokenRefresherStopped = errors.New("token refresher stopped")
+
 // tokenInfo represents the information about an access token.
 type tokenInfo struct {
 	Token string
@@ -21,7 +36,9 @@ type tokenInfo struct {
 // TokenRefresher is a struct responsible for refreshing access tokens.
 type TokenRefresher struct {
 	accessToken chan tokenInfo
-	logger      *slog.Logger
+	stop        chan struct{}
+	stopOnce    sync.Once
+	logger      Logger
 	authorize   AuthorizeFunc
 
 	Schema string
@@ -31,9 +48,10 @@ type TokenRefresher struct {
 type AuthorizeFunc func() (token string, lifeSpan time.Duration, err error)
 
 // NewTokenRefresher creates a new TokenRefresher with the specified schema, authorization function, and logger.
-func NewTokenRefresher(schema string, fn AuthorizeFunc, logger *slog.Logger) *TokenRefresher {
+func NewTokenRefresher(schema string, fn AuthorizeFunc, logger Logger) *TokenRefresher {
 	tr := &TokenRefresher{
 		accessToken: make(chan tokenInfo),
+		stop:        make(chan struct{}),
 		logger:      logger,
 		authorize:   fn,
 
@@ -45,6 +63,48 @@ func NewTokenRefresher(schema string, fn AuthorizeFunc, logger *slog.Logger) *To
 	return tr
 }
 
+// SharedTokenRefresher returns the TokenRefresher registered under key, creating it on first use.
+// Executors using the same key share a single token, so they do not each authenticate against the identity provider.
+// A key made of the token issuer and the client ID is a good choice. The schema, function and logger of later calls are ignored.
+// The first token is obtained without holding the registry, so that a slow identity provider only delays the callers of its key.
+func SharedTokenRefresher(key, schema string, fn AuthorizeFunc, logger Logger) *TokenRefresher {
+	sharedRefreshersMu.Lock()
+	p, ok := sharedRefreshers[key]
+	if !ok {
+		p = newPendingRefresher()
+		sharedRefreshers[key] = p
+	}
+	sharedRefreshersMu.Unlock()
+
+	if !ok {
+		p.resolve(NewTokenRefresher(schema, fn, logger))
+	}
+
+	return p.get()
+}
+
+// pendingRefresher is a TokenRefresher being created by a first caller, which the other callers wait for.
+type pendingRefresher struct {
+	ready chan struct{}
+	tr    *TokenRefresher
+}
+
+func newPendingRefresher() *pendingRefresher {
+	return &pendingRefresher{ready: make(chan struct{})}
+}
+
+// resolve sets the created TokenRefresher and releases the callers waiting for it.
+func (p *pendingRefresher) resolve(tr *TokenRefresher) {
+	p.tr = tr
+	close(p.ready)
+}
+
+// get waits for the TokenRefresher to be created and returns it.
+func (p *pendingRefresher) get() *TokenRefresher {
+	<-p.ready
+	return p.tr
+}
+
 // RefreshToken refreshes the access token periodically.
 func (tr *TokenRefresher) RefreshToken() {
 	started := make(chan struct{})
@@ -54,21 +114,23 @@ func (tr *TokenRefresher) RefreshToken() {
 		var token string
 		var lifeSpan time.Duration
 		token, lifeSpan, err = tr.authorize()
-		expired := time.After(lifeSpan - lifeSpanSafetyMargin)
+		expired := time.After(refreshDelay(lifeSpan))
 		if err != nil {
-			tr.logger.Error("Could not retrieve access token", err)
+			tr.logger.Error("Could not retrieve access token", "Error", err)
 		}
 
 		<-started
 
 		for {
 			select {
+			case <-tr.stop:
+				return
 			case tr.accessToken <- tokenInfo{Token: token, Error: err}:
 			case <-expired:
 				token, lifeSpan, err = tr.authorize()
-				expired = time.After(lifeSpan - lifeSpanSafetyMargin)
+				expired = time.After(refreshDelay(lifeSpan))
 				if err != nil {
-					tr.logger.Error("Could not retrieve access token", err)
+					tr.logger.Error("Could not retrieve access token", "Error", err)
 				}
 			}
 
@@ -79,10 +141,78 @@ func (tr *TokenRefresher) RefreshToken() {
 	close(started)
 }
 
-// Get retrieves the current access token.
+// refreshDelay returns how long to wait before refreshing a token with the given lifespan.
+func refreshDelay(lifeSpan time.Duration) time.Duration {
+	delay := lifeSpan - lifeSpanSafetyMargin
+	if delay < minRefreshInterval {
+		return minRefreshInterval
+	}
+
+	return delay
+}
+
+// Get retrieves the current access token. It returns ErrTokenRefresherStopped once the refresher is stopped.
 func (tr *TokenRefresher) Get() (string, error) {
-	tokenInfo := <-tr.accessToken
-	return tokenInfo.Token, tokenInfo.Error
+	select {
+	case tokenInfo := <-tr.accessToken:
+		return tokenInfo.Token, tokenInfo.Error
+	case <-tr.stop:
+		return "", ErrTokenRefresherStopped
+	}
+}
+
+// Stop stops refreshing the access token and ends the background goroutine. It is safe to call several times.
+func (tr *TokenRefresher) Stop() {
+	tr.stopOnce.Do(func() { close(tr.stop) })
+}
+
+// WithJWTExpiry wraps an AuthorizeFunc so the lifespan of JWT tokens is read from their exp claim.
+// The lifespan returned by fn is only used when it is positive or when the token has no readable exp claim.
+func WithJWTExpiry(fn AuthorizeFunc) AuthorizeFunc {
+	return func() (string, time.Duration, error) {
+		token, lifeSpan, err := fn()
+		if err != nil || lifeSpan > 0 {
+			return token, lifeSpan, err
+		}
+
+		if exp, jwtErr := JWTExpiry(token); jwtErr == nil {
+			lifeSpan = time.Until(exp)
+		}
+
+		return token, lifeSpan, nil
+	}
+}
+
+// JWTExpiry returns the expiration time of a JWT, read from its exp claim.
+// The signature of the token is not verified.
+func JWTExpiry(token string) (time.Time, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, errors.New("token is not a JWT")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("could not decode JWT payload: %w", err)
+	}
+
+	var claims struct {
+		Exp *json.Number `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return time.Time{}, fmt.Errorf("could not parse JWT claims: %w", err)
+	}
+
+	if claims.Exp == nil {
+		return time.Time{}, errors.New("JWT has no exp claim")
+	}
+
+	exp, err := claims.Exp.Float64()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid JWT exp claim: %w", err)
+	}
+
+	return time.Unix(int64(exp), 0), nil
 }
 
 // AuthorizeMiddleware creates a middleware that adds the Authorization header to the HTTP request using the TokenRefresher.
@@ -91,7 +221,7 @@ func AuthorizeMiddleware(tr *TokenRefresher) Middleware {
 		return func(req *http.Request) (*http.Response, error) {
 			token, err := tr.Get()
 			if err != nil {
-				tr.logger.Warn("No token will be added to the request", "URL", req.URL, "Method", req.Method, "Error", err)
+				LoggerFromContext(req.Context(), tr.logger).Warn("No token will be added to the request", "URL", req.URL, "Method", req.Method, "Error", err)
 			} else {
 				req.Header.Add("Authorization", fmt.Sprintf("%s %s", tr.Schema, token))
 			}
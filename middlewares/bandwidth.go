@@ -0,0 +1,111 @@
+package middlewares
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// maxThrottledRead bounds the size of a single read of a throttled body, so that waits are spread evenly.
+const maxThrottledRead = 32 << 10
+
+// bandwidthLimiter is a token bucket counting bytes, with a capacity of one second of transfer.
+type bandwidthLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+// wait reserves n bytes and sleeps until they are available, or until ctx is done.
+func (l *bandwidthLimiter) wait(ctx context.Context, n int) error {
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.rate {
+		l.tokens = l.rate
+	}
+	l.last = now
+	l.tokens -= float64(n)
+	delay := time.Duration(-l.tokens / l.rate * float64(time.Second))
+	l.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// throttledBody is a body whose reads are limited by a bandwidthLimiter.
+type throttledBody struct {
+	io.ReadCloser
+	ctx     context.Context
+	limiter *bandwidthLimiter
+}
+
+// Read reads from the body once enough bandwidth is available.
+func (b *throttledBody) Read(p []byte) (int, error) {
+	if len(p) > maxThrottledRead {
+		p = p[:maxThrottledRead]
+	}
+
+	if max := int(b.limiter.rate); max > 0 && len(p) > max {
+		p = p[:max]
+	}
+
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		if werr := b.limiter.wait(b.ctx, n); werr != nil {
+			return n, werr
+		}
+	}
+
+	return n, err
+}
+
+// BandwidthMiddleware creates a middleware that limits the transfer of request and response bodies to bytesPerSec.
+// The limit is shared by all the requests going through the middleware.
+func BandwidthMiddleware(bytesPerSec int64) Middleware {
+	limiter := &bandwidthLimiter{rate: float64(bytesPerSec), tokens: float64(bytesPerSec), last: time.Now()}
+
+	return func(next Handler) Handler {
+		return func(req *http.Request) (*http.Response, error) {
+			ctx := req.Context()
+
+			if req.Body != nil && req.Body != http.NoBody {
+				req.Body = &throttledBody{ReadCloser: req.Body, ctx: ctx, limiter: limiter}
+
+				if getBody := req.GetBody; getBody != nil {
+					req.GetBody = func() (io.ReadCloser, error) {
+						body, err := getBody()
+						if err != nil {
+							return nil, err
+						}
+
+						return &throttledBody{ReadCloser: body, ctx: ctx, limiter: limiter}, nil
+					}
+				}
+			}
+
+			resp, err := next(req)
+			if err != nil || resp == nil || resp.Body == nil {
+				return resp, err
+			}
+
+			resp.Body = &throttledBody{ReadCloser: resp.Body, ctx: ctx, limiter: limiter}
+
+			return resp, nil
+		}
+	}
+}
@@ -1,34 +1,359 @@
 package middlewares
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
 	"net/http"
+	"path"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/patrickmn/go-cache"
 )
 
+// IdentityFunc returns the identity of the caller on whose behalf a request is made.
+// Cached responses are only served to requests having the same identity.
+type IdentityFunc func(req *http.Request) string
+
 // CachingMiddleware creates a middleware that caches the responses of GET requests using the provided cache and time-to-live (TTL).
 func CachingMiddleware(c *cache.Cache, ttl time.Duration) Middleware {
+	return CachingMiddlewareWithIdentity(c, ttl, AuthorizationIdentity)
+}
+
+// CachingMiddlewareWithIdentity creates a middleware that caches the responses of GET requests using the provided cache and time-to-live (TTL).
+// The identity returned for a request is part of its cache key.
+func CachingMiddlewareWithIdentity(c *cache.Cache, ttl time.Duration, identity IdentityFunc) Middleware {
+	return CachingMiddlewareWithOptions(c, CacheOptions{TTL: ttl, Identity: identity})
+}
+
+// CacheOptions configures CachingMiddlewareWithOptions.
+type CacheOptions struct {
+	// TTL is how long responses are cached. With CacheControl, it only applies to responses without Cache-Control max-age or Expires header,
+	// and zero does not cache them.
+	TTL time.Duration
+	// CacheControl derives the TTL of each response from its Cache-Control and Expires headers, as a private cache does (RFC 7234):
+	// responses with no-store or no-cache are not cached, and responses with private only when the request has an identity.
+	CacheControl bool
+	// Identity returns the identity of the caller, which is part of the cache key. It defaults to AuthorizationIdentity.
+	Identity IdentityFunc
+	// Key returns the key of a request within the responses cached for its identity. It defaults to DefaultCacheKey.
+	Key KeyFunc
+	// Stale is how long an expired response is kept, to be served while a request refreshes it.
+	// Zero makes the requests wait for the refresh instead.
+	Stale time.Duration
+}
+
+// KeyFunc returns the cache key of a request. Requests with the same key are answered with the same cached response.
+type KeyFunc func(req *http.Request) string
+
+// DefaultCacheKey identifies a request by its URL, query string included.
+func DefaultCacheKey(req *http.Request) string {
+	return strings.ToLower(req.URL.String())
+}
+
+// CachingMiddlewareWithOptions creates a middleware that caches the responses of GET requests using the provided cache.
+// Successful responses are read into the cache, and each hit is answered with a fresh response whose body can be read again.
+// Requests whose context bypasses the cache, see ContextWithCacheBypass, are sent and their response replaces the cached one.
+//
+// Concurrent misses of the same key are sent upstream once: the other requests wait for that response,
+// or get the expired response during the Stale window. They are sent upstream themselves when the response could not be cached.
+func CachingMiddlewareWithOptions(c *cache.Cache, opts CacheOptions) Middleware {
+	if opts.Identity == nil {
+		opts.Identity = AuthorizationIdentity
+	}
+	if opts.Key == nil {
+		opts.Key = DefaultCacheKey
+	}
+
 	return func(next Handler) Handler {
+		var mu sync.Mutex
+		flights := map[string]*cacheFlight{}
+
+		fetch := func(req *http.Request, key string, identified bool) (*http.Response, *cachedResponse, error) {
+			resp, err := next(req)
+			if err != nil || resp == nil || !cacheableStatus(resp.StatusCode) {
+				return resp, nil, err
+			}
+
+			now := time.Now()
+			ttl := opts.TTL
+			if opts.CacheControl {
+				ttl = cacheControlTTL(resp.Header, opts.TTL, identified, now)
+			}
+
+			if ttl <= 0 {
+				return resp, nil, nil
+			}
+
+			entry, err := newCachedResponse(req.URL.String(), resp)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			entry.expires = now.Add(ttl)
+			c.Set(key, entry, ttl+opts.Stale)
+
+			return entry.response(req), entry, nil
+		}
+
 		return func(req *http.Request) (*http.Response, error) {
 			if req.Method != "GET" {
 				return next(req)
 			}
 
-			key := strings.ToLower(req.URL.String())
+			key := opts.Key(req)
+			id := opts.Identity(req)
+			if id != "" {
+				key = id + " " + key
+			}
 
-			if resp, ok := c.Get(key); ok {
-				return resp.(*http.Response), nil
+			if CacheBypassFromContext(req.Context()) {
+				resp, _, err := fetch(req, key, id != "")
+				return resp, err
 			}
 
-			resp, err := next(req)
+			var stale *cachedResponse
+			if v, ok := c.Get(key); ok {
+				entry := v.(*cachedResponse)
+				if time.Now().Before(entry.expires) {
+					return entry.response(req), nil
+				}
+				stale = entry
+			}
 
-			if err != nil {
-				c.Set(key, resp, ttl)
+			mu.Lock()
+			if f, ok := flights[key]; ok {
+				mu.Unlock()
+
+				if stale != nil {
+					return stale.response(req), nil
+				}
+
+				select {
+				case <-f.done:
+				case <-req.Context().Done():
+					return nil, contextCause(req.Context())
+				}
+
+				if f.entry != nil {
+					return f.entry.response(req), nil
+				}
+
+				resp, _, err := fetch(req, key, id != "")
+				return resp, err
 			}
 
+			f := &cacheFlight{done: make(chan struct{})}
+			flights[key] = f
+			mu.Unlock()
+
+			defer func() {
+				mu.Lock()
+				delete(flights, key)
+				mu.Unlock()
+				close(f.done)
+			}()
+
+			resp, entry, err := fetch(req, key, id != "")
+			f.entry = entry
+
 			return resp, err
 		}
 	}
 }
+
+// cacheFlight is a request sent upstream for a cache key, which the concurrent requests of the same key wait for.
+type cacheFlight struct {
+	done chan struct{}
+	// entry is the response cached by the request, nil when it could not be cached.
+	entry *cachedResponse
+}
+
+// cacheControlTTL returns how long a response may be cached according to its Cache-Control, Expires and Age headers,
+// or fallback when it has no freshness information. A zero TTL means that it must not be cached.
+func cacheControlTTL(header http.Header, fallback time.Duration, identified bool, now time.Time) time.Duration {
+	directives := parseCacheControl(header.Values("Cache-Control"))
+
+	if _, ok := directives["no-store"]; ok {
+		return 0
+	}
+
+	if _, ok := directives["no-cache"]; ok {
+		return 0
+	}
+
+	if len(directives) == 0 && strings.EqualFold(strings.TrimSpace(header.Get("Pragma")), "no-cache") {
+		return 0
+	}
+
+	if _, ok := directives["private"]; ok && !identified {
+		return 0
+	}
+
+	if v, ok := directives["max-age"]; ok {
+		maxAge, err := strconv.Atoi(v)
+		if err != nil {
+			return 0
+		}
+
+		age, _ := strconv.Atoi(header.Get("Age"))
+
+		return time.Duration(maxAge-age) * time.Second
+	}
+
+	if v := header.Get("Expires"); v != "" {
+		expires, err := http.ParseTime(v)
+		if err != nil {
+			return 0
+		}
+
+		if date, err := http.ParseTime(header.Get("Date")); err == nil {
+			now = date
+		}
+
+		return expires.Sub(now)
+	}
+
+	return fallback
+}
+
+// parseCacheControl returns the directives of Cache-Control headers, such as "max-age=60, private", with their lower case names.
+func parseCacheControl(values []string) map[string]string {
+	directives := map[string]string{}
+
+	for _, value := range values {
+		for _, directive := range strings.Split(value, ",") {
+			name, arg, _ := strings.Cut(strings.TrimSpace(directive), "=")
+			if name == "" {
+				continue
+			}
+
+			directives[strings.ToLower(name)] = strings.Trim(arg, `"`)
+		}
+	}
+
+	return directives
+}
+
+// cacheBypassKey is the context key under which the cache bypass of a request is stored.
+type cacheBypassKey struct{}
+
+// ContextWithCacheBypass returns a copy of ctx whose requests are not answered from the cache, but still refresh it.
+func ContextWithCacheBypass(ctx context.Context) context.Context {
+	return context.WithValue(ctx, cacheBypassKey{}, true)
+}
+
+// CacheBypassFromContext reports whether the requests made with ctx bypass the cache.
+func CacheBypassFromContext(ctx context.Context) bool {
+	bypass, _ := ctx.Value(cacheBypassKey{}).(bool)
+	return bypass
+}
+
+// InvalidateCache removes the responses cached by CachingMiddlewareWithOptions for the URLs matching pattern,
+// for every identity, and returns the number of responses removed. The pattern has the syntax of path.Match,
+// so that "https://api.example.com/users/*" matches the users but not their sub-resources. A malformed pattern matches nothing.
+func InvalidateCache(c *cache.Cache, pattern string) int {
+	removed := 0
+
+	for key, item := range c.Items() {
+		entry, ok := item.Object.(*cachedResponse)
+		if !ok {
+			continue
+		}
+
+		if matched, _ := path.Match(pattern, entry.url); matched {
+			c.Delete(key)
+			removed++
+		}
+	}
+
+	return removed
+}
+
+// cachedResponse holds the status, headers and body of a response, so that it can be served any number of times.
+type cachedResponse struct {
+	url        string
+	expires    time.Time
+	status     string
+	statusCode int
+	proto      string
+	protoMajor int
+	protoMinor int
+	header     http.Header
+	body       []byte
+	trailer    http.Header
+}
+
+// newCachedResponse reads and closes the body of the response to url and keeps it with the status and headers.
+func newCachedResponse(url string, resp *http.Response) (*cachedResponse, error) {
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return &cachedResponse{
+		url:        url,
+		status:     resp.Status,
+		statusCode: resp.StatusCode,
+		proto:      resp.Proto,
+		protoMajor: resp.ProtoMajor,
+		protoMinor: resp.ProtoMinor,
+		header:     resp.Header.Clone(),
+		body:       body,
+		trailer:    resp.Trailer.Clone(),
+	}, nil
+}
+
+// response creates a response to req from the cached entry, with copies of the headers and a new reader over the body.
+func (e *cachedResponse) response(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        e.status,
+		StatusCode:    e.statusCode,
+		Proto:         e.proto,
+		ProtoMajor:    e.protoMajor,
+		ProtoMinor:    e.protoMinor,
+		Header:        e.header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(e.body)),
+		ContentLength: int64(len(e.body)),
+		Trailer:       e.trailer.Clone(),
+		Request:       req,
+	}
+}
+
+// cacheableStatus reports whether responses with the status code are cached: successful ones, other than partial content.
+func cacheableStatus(statusCode int) bool {
+	return statusCode >= 200 && statusCode < 300 && statusCode != http.StatusPartialContent
+}
+
+// AuthorizationIdentity identifies the caller by a hash of the Authorization header of the request.
+func AuthorizationIdentity(req *http.Request) string {
+	return hashIdentity(req.Header.Get("Authorization"))
+}
+
+// TokenIdentity identifies the caller by a hash of the current access token of the TokenRefresher.
+func TokenIdentity(tr *TokenRefresher) IdentityFunc {
+	return func(req *http.Request) string {
+		token, err := tr.Get()
+		if err != nil {
+			return ""
+		}
+
+		return hashIdentity(tr.Schema + " " + token)
+	}
+}
+
+// hashIdentity returns a hash of the credentials so they are not kept in clear in the cache keys.
+func hashIdentity(credentials string) string {
+	if credentials == "" {
+		return ""
+	}
+
+	sum := sha256.Sum256([]byte(credentials))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,141 @@
+package middlewares
+
+import (
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CanaryRule sends a percentage of the requests of a base URL to an alternate base URL, such as a new API version or region.
+type CanaryRule struct {
+	// Match is the base URL whose requests are split, such as "https://api.example.com/v1".
+	Match string
+	// Target is the base URL replacing Match in the requests sent to the canary, such as "https://api-eu.example.com/v2".
+	Target string
+	// Percent of the requests sent to Target, from 0 to 100.
+	Percent float64
+}
+
+// CanaryStats counts the requests sent to a base URL. Errors counts transport failures and 5xx responses.
+type CanaryStats struct {
+	Requests int64
+	Errors   int64
+	Duration time.Duration
+}
+
+// Canary splits the requests between base URLs according to its rules, and counts the requests sent to each of them.
+// Its rules can be updated at runtime, such as from the client configuration during a gradual migration.
+type Canary struct {
+	mu    sync.RWMutex
+	rules []CanaryRule
+	stats map[string]*CanaryStats
+}
+
+// NewCanary creates a Canary with the rules. The first rule matching a request applies.
+func NewCanary(rules ...CanaryRule) *Canary {
+	c := &Canary{stats: map[string]*CanaryStats{}}
+	c.Update(rules...)
+
+	return c
+}
+
+// Update replaces the rules of the canary.
+func (c *Canary) Update(rules ...CanaryRule) {
+	normalized := make([]CanaryRule, len(rules))
+	for i, r := range rules {
+		r.Match = strings.TrimRight(r.Match, "/")
+		r.Target = strings.TrimRight(r.Target, "/")
+		normalized[i] = r
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.rules = normalized
+}
+
+// Stats returns the counters of the requests sent to each base URL, the Match and the Target of the rules.
+func (c *Canary) Stats() map[string]CanaryStats {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	stats := make(map[string]CanaryStats, len(c.stats))
+	for base, s := range c.stats {
+		stats[base] = *s
+	}
+
+	return stats
+}
+
+// route returns the base URL the request is sent to and the URL to send it to, or false when no rule matches.
+func (c *Canary) route(u *url.URL) (string, *url.URL, bool) {
+	raw := u.String()
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for _, r := range c.rules {
+		rest, ok := strings.CutPrefix(raw, r.Match)
+		if !ok || (rest != "" && !strings.ContainsAny(rest[:1], "/?#")) {
+			continue
+		}
+
+		if rand.Float64()*100 >= r.Percent {
+			return r.Match, u, true
+		}
+
+		target, err := url.Parse(r.Target + rest)
+		if err != nil {
+			return r.Match, u, true
+		}
+
+		return r.Target, target, true
+	}
+
+	return "", u, false
+}
+
+// record counts a request sent to the base URL.
+func (c *Canary) record(base string, elapsed time.Duration, failed bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s, ok := c.stats[base]
+	if !ok {
+		s = &CanaryStats{}
+		c.stats[base] = s
+	}
+
+	s.Requests++
+	s.Duration += elapsed
+	if failed {
+		s.Errors++
+	}
+}
+
+// CanaryMiddleware creates a middleware which routes the requests according to the rules of the canary.
+func CanaryMiddleware(c *Canary) Middleware {
+	return func(next Handler) Handler {
+		return func(req *http.Request) (*http.Response, error) {
+			base, u, ok := c.route(req.URL)
+			if !ok {
+				return next(req)
+			}
+
+			if u != req.URL {
+				req = req.Clone(req.Context())
+				req.URL = u
+				req.Host = ""
+			}
+
+			start := time.Now()
+			resp, err := next(req)
+			c.record(base, time.Since(start), err != nil || resp == nil || resp.StatusCode >= http.StatusInternalServerError)
+
+			return resp, err
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package middlewares
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// CircuitState is the state of the circuit of a host.
+type CircuitState int
+
+const (
+	// CircuitClosed lets the requests through and counts their consecutive failures.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen rejects the requests without sending them.
+	CircuitOpen
+	// CircuitHalfOpen lets a few probe requests through to find out if the host recovered.
+	CircuitHalfOpen
+)
+
+// String returns the name of the state.
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// CircuitOpenError is returned without sending the request while the circuit of its host is open.
+type CircuitOpenError struct {
+	Key        string
+	RetryAfter time.Duration
+}
+
+// Error returns the circuit and how long it stays open.
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("circuit of %s is open, next probe in %s", e.Key, e.RetryAfter.Round(time.Millisecond))
+}
+
+// CircuitOpen reports that the request was rejected by a circuit breaker.
+func (e *CircuitOpenError) CircuitOpen() bool { return true }
+
+// CircuitBreakerOptions configures CircuitBreakerMiddleware.
+type CircuitBreakerOptions struct {
+	// FailureThreshold is the number of consecutive failures which opens the circuit. It defaults to 5.
+	FailureThreshold int
+	// OpenTimeout is how long the circuit stays open before letting probe requests through. It defaults to 30s.
+	OpenTimeout time.Duration
+	// HalfOpenRequests is the number of probe requests let through while half-open, which all have to succeed to close the circuit.
+	// It defaults to 1.
+	HalfOpenRequests int
+	// IsFailure decides if the outcome of a request counts as a failure. It defaults to transport errors and 5xx responses.
+	IsFailure func(resp *http.Response, err error) bool
+	// Key groups the requests sharing a circuit. It defaults to the host of the request.
+	Key func(req *http.Request) string
+	// OnStateChange, if set, is called whenever a circuit changes state.
+	OnStateChange func(key string, from, to CircuitState)
+}
+
+// circuit tracks the state of the requests sharing a key.
+type circuit struct {
+	mu        sync.Mutex
+	state     CircuitState
+	failures  int
+	openedAt  time.Time
+	probes    int
+	successes int
+}
+
+// allow reports if a request may be sent, or how long the circuit stays open.
+func (c *circuit) allow(opts CircuitBreakerOptions, now time.Time) (bool, time.Duration, CircuitState, CircuitState) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	from := c.state
+	if c.state == CircuitOpen {
+		if wait := opts.OpenTimeout - now.Sub(c.openedAt); wait > 0 {
+			return false, wait, from, from
+		}
+
+		c.state = CircuitHalfOpen
+		c.probes = 0
+		c.successes = 0
+	}
+
+	if c.state == CircuitHalfOpen {
+		if c.probes >= opts.HalfOpenRequests {
+			return false, opts.OpenTimeout, from, c.state
+		}
+		c.probes++
+	}
+
+	return true, 0, from, c.state
+}
+
+// release gives back a probe whose outcome is unknown, such as when the caller canceled the request.
+func (c *circuit) release() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.state == CircuitHalfOpen && c.probes > 0 {
+		c.probes--
+	}
+}
+
+// record counts the outcome of a request and returns the states before and after it.
+func (c *circuit) record(opts CircuitBreakerOptions, failed bool, now time.Time) (CircuitState, CircuitState) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	from := c.state
+
+	switch c.state {
+	case CircuitClosed:
+		if !failed {
+			c.failures = 0
+			break
+		}
+
+		c.failures++
+		if c.failures >= opts.FailureThreshold {
+			c.state = CircuitOpen
+			c.openedAt = now
+		}
+	case CircuitHalfOpen:
+		if failed {
+			c.state = CircuitOpen
+			c.openedAt = now
+			break
+		}
+
+		c.successes++
+		if c.successes >= opts.HalfOpenRequests {
+			c.state = CircuitClosed
+			c.failures = 0
+		}
+	}
+
+	return from, c.state
+}
+
+// CircuitBreakerMiddleware creates a middleware which stops sending requests to a host after consecutive failures.
+// Once FailureThreshold requests failed in a row, the circuit opens and requests fail fast with a CircuitOpenError.
+// After OpenTimeout, HalfOpenRequests probes are let through: the circuit closes if they all succeed, otherwise it opens again.
+// Add it before the retry middleware, so that each attempt is counted and retries stop once the circuit opens.
+func CircuitBreakerMiddleware(opts CircuitBreakerOptions) Middleware {
+	if opts.FailureThreshold <= 0 {
+		opts.FailureThreshold = 5
+	}
+	if opts.OpenTimeout <= 0 {
+		opts.OpenTimeout = 30 * time.Second
+	}
+	if opts.HalfOpenRequests <= 0 {
+		opts.HalfOpenRequests = 1
+	}
+	if opts.IsFailure == nil {
+		opts.IsFailure = func(resp *http.Response, err error) bool {
+			return err != nil || resp == nil || resp.StatusCode >= http.StatusInternalServerError
+		}
+	}
+	if opts.Key == nil {
+		opts.Key = func(req *http.Request) string { return req.URL.Host }
+	}
+
+	notify := func(key string, from, to CircuitState) {
+		if from != to && opts.OnStateChange != nil {
+			opts.OnStateChange(key, from, to)
+		}
+	}
+
+	var circuits sync.Map
+
+	return func(next Handler) Handler {
+		return func(req *http.Request) (*http.Response, error) {
+			key := opts.Key(req)
+			v, _ := circuits.LoadOrStore(key, &circuit{})
+			c := v.(*circuit)
+
+			ok, wait, from, to := c.allow(opts, time.Now())
+			notify(key, from, to)
+			if !ok {
+				return nil, &CircuitOpenError{Key: key, RetryAfter: wait}
+			}
+
+			resp, err := next(req)
+
+			if err != nil && req.Context().Err() != nil {
+				c.release()
+				return resp, err
+			}
+
+			from, to = c.record(opts, opts.IsFailure(resp, err), time.Now())
+			notify(key, from, to)
+
+			return resp, err
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package middlewares
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// CompressionOptions configures the compression of request bodies.
+type CompressionOptions struct {
+	// Encodings lists the content encodings to use, in order of preference: "zstd" and "gzip". Defaults to zstd, then gzip.
+	Encodings []string
+	// MinSize is the body size under which bodies are sent uncompressed, 1024 bytes by default.
+	MinSize int
+	// Negotiate compresses the bodies sent to a host only with the encodings it advertised in the Accept-Encoding header
+	// of a previous response (RFC 7694). Bodies are sent uncompressed until the host advertises an encoding.
+	Negotiate bool
+}
+
+// CompressionMiddleware creates a middleware that compresses request bodies with the first usable encoding and sets their Content-Encoding.
+// When a server rejects a compressed body with 415 Unsupported Media Type, the encodings it advertises are recorded for its host
+// and the request is sent again with one of them, or uncompressed.
+func CompressionMiddleware(opts CompressionOptions) Middleware {
+	if len(opts.Encodings) == 0 {
+		opts.Encodings = []string{"zstd", "gzip"}
+	}
+
+	if opts.MinSize == 0 {
+		opts.MinSize = 1024
+	}
+
+	var mu sync.Mutex
+	advertised := map[string][]string{}
+
+	encodingFor := func(host string) string {
+		mu.Lock()
+		accepted, known := advertised[host]
+		mu.Unlock()
+
+		if !known && !opts.Negotiate {
+			return opts.Encodings[0]
+		}
+
+		for _, enc := range opts.Encodings {
+			for _, a := range accepted {
+				if a == enc {
+					return enc
+				}
+			}
+		}
+
+		return ""
+	}
+
+	record := func(host string, resp *http.Response) {
+		if resp == nil {
+			return
+		}
+
+		header, ok := resp.Header["Accept-Encoding"]
+		if !ok && resp.StatusCode != http.StatusUnsupportedMediaType {
+			return
+		}
+
+		var accepted []string
+		for _, v := range header {
+			for _, enc := range strings.Split(v, ",") {
+				enc, _, _ = strings.Cut(enc, ";")
+				accepted = append(accepted, strings.ToLower(strings.TrimSpace(enc)))
+			}
+		}
+
+		mu.Lock()
+		advertised[host] = accepted
+		mu.Unlock()
+	}
+
+	return func(next Handler) Handler {
+		return func(req *http.Request) (*http.Response, error) {
+			if req.Body == nil || req.Body == http.NoBody || req.Header.Get("Content-Encoding") != "" {
+				resp, err := next(req)
+				record(req.URL.Host, resp)
+				return resp, err
+			}
+
+			body, err := io.ReadAll(req.Body)
+			req.Body.Close()
+			if err != nil {
+				return nil, err
+			}
+
+			enc := ""
+			if len(body) >= opts.MinSize {
+				enc = encodingFor(req.URL.Host)
+			}
+
+			if err := setEncodedBody(req, body, enc); err != nil {
+				return nil, err
+			}
+
+			resp, err := next(req)
+			record(req.URL.Host, resp)
+
+			if err != nil || enc == "" || resp.StatusCode != http.StatusUnsupportedMediaType {
+				return resp, err
+			}
+
+			DrainBody(resp)
+
+			retry := req.Clone(req.Context())
+			if err := setEncodedBody(retry, body, encodingFor(req.URL.Host)); err != nil {
+				return nil, err
+			}
+
+			return next(retry)
+		}
+	}
+}
+
+// setEncodedBody sets the body of the request, compressed with enc unless it is empty.
+func setEncodedBody(req *http.Request, body []byte, enc string) error {
+	req.Header.Del("Content-Encoding")
+
+	if enc != "" {
+		compressed, err := compress(body, enc)
+		if err != nil {
+			return err
+		}
+
+		body = compressed
+		req.Header.Set("Content-Encoding", enc)
+	}
+
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(body)), nil }
+	req.ContentLength = int64(len(body))
+
+	return nil
+}
+
+// compress encodes the body with the zstd or gzip encoding.
+func compress(body []byte, enc string) ([]byte, error) {
+	var buf bytes.Buffer
+
+	var w io.WriteCloser
+	switch enc {
+	case "zstd":
+		zw, err := zstd.NewWriter(&buf)
+		if err != nil {
+			return nil, err
+		}
+		w = zw
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q", enc)
+	}
+
+	if _, err := w.Write(body); err != nil {
+		return nil, err
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,91 @@
+package middlewares
+
+import (
+	"net/http"
+	"path"
+	"strings"
+)
+
+// Predicate reports whether a middleware should be applied to the HTTP request.
+type Predicate func(req *http.Request) bool
+
+// WhenFunc creates a middleware that runs m only for requests matching the predicate.
+// Other requests are passed directly to the next handler.
+func WhenFunc(predicate Predicate, m Middleware) Middleware {
+	return func(next Handler) Handler {
+		wrapped := m(next)
+
+		return func(req *http.Request) (*http.Response, error) {
+			if predicate(req) {
+				return wrapped(req)
+			}
+
+			return next(req)
+		}
+	}
+}
+
+// MethodIs returns a predicate matching requests using one of the given HTTP methods.
+func MethodIs(methods ...string) Predicate {
+	return func(req *http.Request) bool {
+		for _, m := range methods {
+			if strings.EqualFold(req.Method, m) {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// HasHeader returns a predicate matching requests having the header set.
+// When values are provided, the header must also be equal to one of them.
+func HasHeader(key string, values ...string) Predicate {
+	return func(req *http.Request) bool {
+		v := req.Header.Get(key)
+		if v == "" {
+			return false
+		}
+
+		if len(values) == 0 {
+			return true
+		}
+
+		for _, value := range values {
+			if v == value {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// PathMatches returns a predicate matching requests whose URL path matches the pattern.
+// The pattern uses the path.Match syntax, and a trailing "/*" also matches every nested path.
+func PathMatches(pattern string) Predicate {
+	prefix, nested := strings.CutSuffix(pattern, "/*")
+
+	return func(req *http.Request) bool {
+		p := req.URL.Path
+		if nested && (p == prefix || strings.HasPrefix(p, prefix+"/")) {
+			return true
+		}
+
+		ok, err := path.Match(pattern, p)
+		return err == nil && ok
+	}
+}
+
+// And returns a predicate matching requests matched by all the predicates.
+func And(predicates ...Predicate) Predicate {
+	return func(req *http.Request) bool {
+		for _, p := range predicates {
+			if !p(req) {
+				return false
+			}
+		}
+
+		return true
+	}
+}
@@ -0,0 +1,166 @@
+package middlewares
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// AcceptEncodingOptions configures the negotiation of response encodings.
+type AcceptEncodingOptions struct {
+	// Encodings lists the encodings advertised in the Accept-Encoding header of the requests which do not set it,
+	// such as "zstd", "gzip" or "deflate". Use "identity" to ask for uncompressed responses. Nothing is added when it is empty.
+	Encodings []string
+	// Passthrough leaves the response bodies compressed, with their Content-Encoding header, for proxies relaying them as is.
+	// The transport then stops requesting and decompressing gzip responses on its own.
+	Passthrough bool
+}
+
+// AcceptEncodingMiddleware creates a middleware which advertises the encodings of the options and decompresses the gzip, deflate and zstd
+// responses, unless Passthrough is set. Responses with other encodings are returned compressed.
+func AcceptEncodingMiddleware(opts AcceptEncodingOptions) Middleware {
+	acceptEncoding := strings.Join(opts.Encodings, ", ")
+
+	return func(next Handler) Handler {
+		return func(req *http.Request) (*http.Response, error) {
+			if acceptEncoding != "" && req.Header.Get("Accept-Encoding") == "" {
+				req.Header.Set("Accept-Encoding", acceptEncoding)
+			}
+
+			resp, err := next(req)
+			if err != nil || resp == nil || opts.Passthrough {
+				return resp, err
+			}
+
+			if err := decodeBody(resp); err != nil {
+				DrainBody(resp)
+				return nil, err
+			}
+
+			return resp, nil
+		}
+	}
+}
+
+// decodeBody replaces the body of the response by its decompressed content when all its content encodings are supported.
+// Responses without body, such as the responses to HEAD requests, 204 and 304 responses, are left unchanged.
+func decodeBody(resp *http.Response) error {
+	if resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusNotModified || resp.ContentLength == 0 ||
+		(resp.Request != nil && resp.Request.Method == http.MethodHead) {
+		return nil
+	}
+
+	var encodings []string
+	for _, v := range resp.Header.Values("Content-Encoding") {
+		for _, enc := range strings.Split(v, ",") {
+			if enc = strings.ToLower(strings.TrimSpace(enc)); enc != "" && enc != "identity" {
+				encodings = append(encodings, enc)
+			}
+		}
+	}
+
+	if len(encodings) == 0 {
+		return nil
+	}
+
+	for _, enc := range encodings {
+		if enc != "gzip" && enc != "x-gzip" && enc != "deflate" && enc != "zstd" {
+			return nil
+		}
+	}
+
+	resp.Body = &decodedBody{body: resp.Body, encodings: encodings}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+
+	return nil
+}
+
+// decompressor returns a reader decompressing r with the encoding.
+func decompressor(r io.Reader, enc string) (io.Reader, io.Closer, error) {
+	switch enc {
+	case "gzip", "x-gzip":
+		zr, err := gzip.NewReader(r)
+		return zr, zr, err
+	case "deflate":
+		zr, err := zlib.NewReader(r)
+		return zr, zr, err
+	default:
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, nil, err
+		}
+		return zr, closerFunc(func() error { zr.Close(); return nil }), nil
+	}
+}
+
+// closerFunc adapts a function to io.Closer.
+type closerFunc func() error
+
+func (f closerFunc) Close() error { return f() }
+
+// decodedBody is a decompressed response body, closing the decompressors and the original body.
+// The decompressors are created on the first read, so that empty bodies read as empty instead of failing.
+type decodedBody struct {
+	body      io.ReadCloser
+	encodings []string
+	reader    io.Reader
+	closers   []io.Closer
+	err       error
+}
+
+func (b *decodedBody) Read(p []byte) (int, error) {
+	if b.reader == nil && b.err == nil {
+		b.err = b.open()
+	}
+
+	if b.err != nil {
+		return 0, b.err
+	}
+
+	return b.reader.Read(p)
+}
+
+// open creates the decompressors. Encodings are listed in the order they were applied, so they are removed from the last one.
+func (b *decodedBody) open() error {
+	var r io.Reader = b.body
+
+	for i := len(b.encodings) - 1; i >= 0; i-- {
+		zr, c, err := decompressor(r, b.encodings[i])
+		if err == io.EOF {
+			return io.EOF
+		}
+		if err != nil {
+			return fmt.Errorf("decoding %s response: %w", b.encodings[i], err)
+		}
+
+		r = zr
+		b.closers = append(b.closers, c)
+	}
+
+	b.reader = r
+
+	return nil
+}
+
+func (b *decodedBody) Close() error {
+	var err error
+	for i := len(b.closers) - 1; i >= 0; i-- {
+		if cerr := b.closers[i].Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+
+	if cerr := b.body.Close(); cerr != nil && err == nil {
+		err = cerr
+	}
+
+	return err
+}
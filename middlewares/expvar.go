@@ -0,0 +1,59 @@
+package middlewares
+
+import (
+	"expvar"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// expvarMu serializes the publication of the expvar maps, which panics on duplicate names.
+var expvarMu sync.Mutex
+
+// ExpvarMiddleware creates a middleware that publishes request counters under the expvar map namespace,
+// served by expvar on /debug/vars: requests, errors (transport failures), in_flight, duration_ms (total)
+// and one counter per status class (status_2xx, status_4xx, ...).
+// When it is added after the retry middleware, retries and backoff_ms (total) count the attempts beyond the first one.
+// Middlewares using the same namespace share the same counters.
+func ExpvarMiddleware(namespace string) Middleware {
+	vars := expvarMap(namespace)
+
+	return func(next Handler) Handler {
+		return func(req *http.Request) (*http.Response, error) {
+			start := time.Now()
+			vars.Add("requests", 1)
+			vars.Add("in_flight", 1)
+
+			resp, err := next(req)
+
+			vars.Add("in_flight", -1)
+			vars.AddFloat("duration_ms", float64(time.Since(start).Microseconds())/1000)
+
+			if stats := RetryStatsFromContext(req.Context()); stats != nil && stats.Attempts > 1 {
+				vars.Add("retries", int64(stats.Attempts-1))
+				vars.AddFloat("backoff_ms", float64(stats.Backoff.Microseconds())/1000)
+			}
+
+			if err != nil || resp == nil {
+				vars.Add("errors", 1)
+			} else {
+				vars.Add(fmt.Sprintf("status_%dxx", resp.StatusCode/100), 1)
+			}
+
+			return resp, err
+		}
+	}
+}
+
+// expvarMap returns the map published under namespace, publishing it if needed.
+func expvarMap(namespace string) *expvar.Map {
+	expvarMu.Lock()
+	defer expvarMu.Unlock()
+
+	if v, ok := expvar.Get(namespace).(*expvar.Map); ok {
+		return v
+	}
+
+	return expvar.NewMap(namespace)
+}
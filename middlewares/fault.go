@@ -0,0 +1,98 @@
+package middlewares
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Fault is a failure injected into the requests by FaultInjectionMiddleware, to test the retry and circuit breaking settings.
+type Fault struct {
+	// URL restricts the fault to the requests whose URL starts with it, such as "https://api.example.com/v1/orders". Empty matches every URL.
+	URL string
+	// Match restricts the fault to the requests for which it returns true. Nil matches every request.
+	Match func(req *http.Request) bool
+	// Probability of injecting the fault into a matching request, from 0, never, to 1, every matching request. It must be set.
+	Probability float64
+	// Latency delays the request before it is sent, or before the fault is returned.
+	Latency time.Duration
+	// Drop fails the request with a FaultError, as a dropped connection would, instead of sending it.
+	Drop bool
+	// StatusCode answers the request with an empty response of this status code, such as 503, instead of sending it.
+	StatusCode int
+}
+
+// FaultError is returned for the requests dropped by FaultInjectionMiddleware.
+type FaultError struct {
+	URL string
+}
+
+func (e *FaultError) Error() string {
+	return fmt.Sprintf("injected fault: connection to %s dropped", e.URL)
+}
+
+// FaultInjectionMiddleware creates a middleware which injects the faults into the matching requests.
+// The faults are tried in order: the latency of each fault injected is added, and the first fault dropping
+// or answering the request ends it. Injected responses carry an X-Fault-Injected header.
+func FaultInjectionMiddleware(faults ...Fault) Middleware {
+	return func(next Handler) Handler {
+		return func(req *http.Request) (*http.Response, error) {
+			for _, f := range faults {
+				if !f.matches(req) || f.Probability <= 0 || (f.Probability < 1 && rand.Float64() >= f.Probability) {
+					continue
+				}
+
+				if f.Latency > 0 {
+					timer := time.NewTimer(f.Latency)
+					select {
+					case <-timer.C:
+					case <-req.Context().Done():
+						timer.Stop()
+						return nil, contextCause(req.Context())
+					}
+				}
+
+				if f.Drop {
+					return nil, &FaultError{URL: req.URL.String()}
+				}
+
+				if f.StatusCode != 0 {
+					return faultResponse(req, f.StatusCode), nil
+				}
+			}
+
+			return next(req)
+		}
+	}
+}
+
+// matches reports whether the fault applies to the request.
+func (f *Fault) matches(req *http.Request) bool {
+	if f.URL != "" && !strings.HasPrefix(req.URL.String(), f.URL) {
+		return false
+	}
+
+	return f.Match == nil || f.Match(req)
+}
+
+// faultResponse creates an empty response to req with the status code.
+func faultResponse(req *http.Request, statusCode int) *http.Response {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+
+	return &http.Response{
+		Status:     fmt.Sprintf("%d %s", statusCode, http.StatusText(statusCode)),
+		StatusCode: statusCode,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{"X-Fault-Injected": {"true"}},
+		Body:       io.NopCloser(bytes.NewReader(nil)),
+		Request:    req,
+	}
+}
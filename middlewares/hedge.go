@@ -0,0 +1,136 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// HedgeOptions configures HedgingMiddleware.
+type HedgeOptions struct {
+	// Delay is how long to wait for a response before sending a duplicate of the request. It defaults to 100ms.
+	// Set it around the p95 latency of the upstream, so that only the slowest requests are duplicated.
+	Delay time.Duration
+	// MaxHedges is the number of duplicates sent at most, one every Delay. It defaults to 1.
+	MaxHedges int
+	// Hedge decides if a request may be duplicated. It defaults to the idempotent GET, HEAD and OPTIONS requests.
+	Hedge func(req *http.Request) bool
+}
+
+// hedgeResult is the outcome of one of the copies of a hedged request.
+type hedgeResult struct {
+	index int
+	resp  *http.Response
+	err   error
+}
+
+// HedgingMiddleware creates a middleware which sends a duplicate of a request when no response arrived after the delay,
+// and returns whichever response arrives first, canceling the other copies.
+// A copy failing with an error sends the next duplicate right away. The error of the last copy is returned when they all fail.
+// Requests with a body are duplicated only when their body can be recreated, see http.Request.GetBody.
+func HedgingMiddleware(opts HedgeOptions) Middleware {
+	if opts.Delay <= 0 {
+		opts.Delay = 100 * time.Millisecond
+	}
+	if opts.MaxHedges <= 0 {
+		opts.MaxHedges = 1
+	}
+	if opts.Hedge == nil {
+		opts.Hedge = func(req *http.Request) bool {
+			return req.Method == http.MethodGet || req.Method == http.MethodHead || req.Method == http.MethodOptions
+		}
+	}
+
+	return func(next Handler) Handler {
+		return func(req *http.Request) (*http.Response, error) {
+			hasBody := req.Body != nil && req.Body != http.NoBody
+			if !opts.Hedge(req) || (hasBody && req.GetBody == nil) {
+				return next(req)
+			}
+
+			results := make(chan hedgeResult, opts.MaxHedges+1)
+			cancels := make([]context.CancelFunc, 0, opts.MaxHedges+1)
+
+			send := func() error {
+				ctx, cancel := context.WithCancel(req.Context())
+				attempt := req.Clone(ctx)
+
+				if hasBody && len(cancels) > 0 {
+					body, err := req.GetBody()
+					if err != nil {
+						cancel()
+						return err
+					}
+					attempt.Body = body
+				}
+
+				n := len(cancels)
+				cancels = append(cancels, cancel)
+
+				go func() {
+					resp, err := next(attempt)
+					results <- hedgeResult{index: n, resp: resp, err: err}
+				}()
+
+				return nil
+			}
+
+			if err := send(); err != nil {
+				return nil, err
+			}
+
+			pending := 1
+			timer := time.NewTimer(opts.Delay)
+			defer timer.Stop()
+
+			var last hedgeResult
+
+			for pending > 0 {
+				select {
+				case <-timer.C:
+					if len(cancels) <= opts.MaxHedges && send() == nil {
+						pending++
+						timer.Reset(opts.Delay)
+					}
+				case r := <-results:
+					pending--
+
+					if r.err == nil {
+						for i, cancel := range cancels {
+							if i != r.index {
+								cancel()
+							}
+						}
+						go discardHedges(results, pending)
+
+						if r.resp == nil || r.resp.Body == nil {
+							cancels[r.index]()
+							return r.resp, nil
+						}
+
+						r.resp.Body = &cancelBody{ReadCloser: r.resp.Body, cancel: cancels[r.index]}
+						return r.resp, nil
+					}
+
+					cancels[r.index]()
+					last = r
+
+					if len(cancels) <= opts.MaxHedges && req.Context().Err() == nil && send() == nil {
+						pending++
+						timer.Reset(opts.Delay)
+					}
+				}
+			}
+
+			return last.resp, last.err
+		}
+	}
+}
+
+// discardHedges releases the responses of the canceled copies of a hedged request.
+func discardHedges(results <-chan hedgeResult, pending int) {
+	for ; pending > 0; pending-- {
+		r := <-results
+		DrainBody(r.resp)
+	}
+}
@@ -0,0 +1,171 @@
+package middlewares
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// JWEOptions configures the encryption of request bodies.
+// Either Key or JWKS must be set.
+type JWEOptions struct {
+	// Key is the static RSA public key of the recipient.
+	Key *rsa.PublicKey
+	// KeyID is the kid header of the token. With JWKS, it selects the key of the set.
+	KeyID string
+	// JWKS is the key set of the recipient, the first encryption key is used when KeyID is empty.
+	JWKS *JWKS
+	// Algorithm is the key management algorithm, "RSA-OAEP-256" (default) or "RSA-OAEP".
+	Algorithm string
+	// ContentType is the cty header describing the encrypted payload, "application/json" by default.
+	ContentType string
+}
+
+// jweHeader is the protected header of a JWE token.
+type jweHeader struct {
+	Alg string `json:"alg"`
+	Enc string `json:"enc"`
+	Kid string `json:"kid,omitempty"`
+	Cty string `json:"cty,omitempty"`
+}
+
+// JWEMiddleware creates a middleware that encrypts request bodies as JWE compact tokens using A256GCM,
+// and sets the Content-Type of the request to application/jose.
+// Requests without body are sent unchanged. The request fails when no encryption key can be obtained.
+func JWEMiddleware(opts JWEOptions) Middleware {
+	if opts.Algorithm == "" {
+		opts.Algorithm = "RSA-OAEP-256"
+	}
+
+	if opts.ContentType == "" {
+		opts.ContentType = "application/json"
+	}
+
+	return func(next Handler) Handler {
+		return func(req *http.Request) (*http.Response, error) {
+			if req.Body == nil || req.Body == http.NoBody {
+				return next(req)
+			}
+
+			body, err := io.ReadAll(req.Body)
+			req.Body.Close()
+			if err != nil {
+				return nil, err
+			}
+
+			if len(body) == 0 {
+				req.Body = http.NoBody
+				return next(req)
+			}
+
+			kid, key, err := opts.key()
+			if err != nil {
+				return nil, err
+			}
+
+			token, err := encryptJWE(jweHeader{Alg: opts.Algorithm, Enc: "A256GCM", Kid: kid, Cty: opts.ContentType}, key, body)
+			if err != nil {
+				return nil, err
+			}
+
+			req.Body = io.NopCloser(bytes.NewReader(token))
+			req.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(token)), nil }
+			req.ContentLength = int64(len(token))
+			req.Header.Set("Content-Type", "application/jose")
+
+			return next(req)
+		}
+	}
+}
+
+// key returns the encryption key and its ID.
+func (o JWEOptions) key() (string, *rsa.PublicKey, error) {
+	if o.Key != nil {
+		return o.KeyID, o.Key, nil
+	}
+
+	if o.JWKS == nil {
+		return "", nil, errors.New("jwe: no encryption key configured")
+	}
+
+	kid, pub, err := o.JWKS.Key(o.KeyID, "enc")
+	if err != nil {
+		return "", nil, err
+	}
+
+	key, ok := pub.(*rsa.PublicKey)
+	if !ok {
+		return "", nil, fmt.Errorf("jwe: key %q is not an RSA key", kid)
+	}
+
+	return kid, key, nil
+}
+
+// encryptJWE encrypts the payload with a random content encryption key, itself encrypted for the recipient key.
+func encryptJWE(header jweHeader, key *rsa.PublicKey, payload []byte) ([]byte, error) {
+	var h hash.Hash
+	switch header.Alg {
+	case "RSA-OAEP-256":
+		h = sha256.New()
+	case "RSA-OAEP":
+		h = sha1.New()
+	default:
+		return nil, fmt.Errorf("jwe: unsupported algorithm %q", header.Alg)
+	}
+
+	cek := make([]byte, 32)
+	if _, err := rand.Read(cek); err != nil {
+		return nil, err
+	}
+
+	encryptedKey, err := rsa.EncryptOAEP(h, rand.Reader, key, cek, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	iv := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(iv); err != nil {
+		return nil, err
+	}
+
+	protected, err := json.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+
+	encodedHeader := base64.RawURLEncoding.EncodeToString(protected)
+	sealed := gcm.Seal(nil, iv, payload, []byte(encodedHeader))
+	ciphertext, tag := sealed[:len(sealed)-gcm.Overhead()], sealed[len(sealed)-gcm.Overhead():]
+
+	token := strings.Join([]string{
+		encodedHeader,
+		base64.RawURLEncoding.EncodeToString(encryptedKey),
+		base64.RawURLEncoding.EncodeToString(iv),
+		base64.RawURLEncoding.EncodeToString(ciphertext),
+		base64.RawURLEncoding.EncodeToString(tag),
+	}, ".")
+
+	return []byte(token), nil
+}
@@ -0,0 +1,240 @@
+package middlewares
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// jwksTTL is how long a fetched key set is used before being fetched again.
+	jwksTTL = time.Hour
+
+	// jwksMinRefetch bounds how often an unknown key ID triggers a new fetch of the key set.
+	jwksMinRefetch = time.Minute
+
+	// jwksFailureBackoff is how long a failed fetch is not attempted again, the cached keys being used meanwhile.
+	jwksFailureBackoff = 10 * time.Second
+)
+
+// defaultJWKSClient fetches the key sets of the JWKS created without client.
+var defaultJWKSClient = &http.Client{Timeout: 10 * time.Second}
+
+// JWKS is a JSON Web Key Set fetched from a URL.
+// The keys are cached and fetched again when they expire or when an unknown key ID is requested.
+// When a fetch fails, the cached keys are still used, and the fetch is attempted again after a backoff.
+type JWKS struct {
+	url    string
+	client *http.Client
+
+	fetchMu sync.Mutex
+
+	mu        sync.Mutex
+	keys      []jsonWebKey
+	fetched   time.Time
+	attempted time.Time
+	fetchErr  error
+}
+
+// jsonWebKey represents a public key of a key set.
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// NewJWKS creates a JWKS which fetches its keys from url with the given client.
+// A client with a 10 seconds timeout is used when client is nil.
+func NewJWKS(url string, client *http.Client) *JWKS {
+	if client == nil {
+		client = defaultJWKSClient
+	}
+
+	return &JWKS{url: url, client: client}
+}
+
+// Key returns the public key with the given key ID, along with its ID.
+// When kid is empty, the first key intended for use ("enc" or "sig") is returned.
+func (s *JWKS) Key(kid, use string) (string, crypto.PublicKey, error) {
+	s.mu.Lock()
+	expired := time.Since(s.fetched) > jwksTTL
+	cached := len(s.keys) > 0
+	attempted := s.attempted
+	s.mu.Unlock()
+
+	if expired && s.canFetch(attempted, jwksFailureBackoff) {
+		s.refresh(attempted, !cached)
+	}
+
+	k, ok, attempted := s.find(kid, use)
+	if !ok && s.canFetch(attempted, jwksMinRefetch) {
+		s.refresh(attempted, true)
+		k, ok, _ = s.find(kid, use)
+	}
+
+	if !ok {
+		s.mu.Lock()
+		fetchErr := s.fetchErr
+		s.mu.Unlock()
+
+		if fetchErr != nil {
+			return "", nil, fmt.Errorf("no key %q for %q in key set %s: %w", kid, use, s.url, fetchErr)
+		}
+
+		return "", nil, fmt.Errorf("no key %q for %q in key set %s", kid, use, s.url)
+	}
+
+	pub, err := k.publicKey()
+	if err != nil {
+		return "", nil, err
+	}
+
+	return k.Kid, pub, nil
+}
+
+// canFetch reports whether the key set can be fetched again, the last attempt being made at attempted.
+// A failed attempt is not repeated before the failure backoff, a successful one before wait.
+func (s *JWKS) canFetch(attempted time.Time, wait time.Duration) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.fetchErr != nil {
+		wait = jwksFailureBackoff
+	}
+
+	return attempted.IsZero() || time.Since(attempted) > wait
+}
+
+// refresh fetches the key set, unless another caller attempted it since attempted.
+// The cached keys are kept when the fetch fails. Fetches are serialized: unless wait is set,
+// refresh returns at once when another caller is fetching, so that the cached keys keep being used meanwhile.
+func (s *JWKS) refresh(attempted time.Time, wait bool) {
+	if !s.fetchMu.TryLock() {
+		if !wait {
+			return
+		}
+		s.fetchMu.Lock()
+	}
+	defer s.fetchMu.Unlock()
+
+	s.mu.Lock()
+	done := !s.attempted.Equal(attempted)
+	s.mu.Unlock()
+
+	if done {
+		return
+	}
+
+	keys, err := s.fetch()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.attempted = time.Now()
+	s.fetchErr = err
+	if err == nil {
+		s.keys = keys
+		s.fetched = s.attempted
+	}
+}
+
+// find returns the cached key matching kid and use, along with the time of the last fetch attempt.
+func (s *JWKS) find(kid, use string) (jsonWebKey, bool, time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, k := range s.keys {
+		if kid != "" && k.Kid != kid {
+			continue
+		}
+
+		if k.Use != "" && use != "" && k.Use != use {
+			continue
+		}
+
+		return k, true, s.attempted
+	}
+
+	return jsonWebKey{}, false, s.attempted
+}
+
+// fetch downloads the key set.
+func (s *JWKS) fetch() ([]jsonWebKey, error) {
+	resp, err := s.client.Get(s.url)
+	if err != nil {
+		return nil, err
+	}
+	defer DrainBody(resp)
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching key set %s returned status %d", s.url, resp.StatusCode)
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("decoding key set %s: %w", s.url, err)
+	}
+
+	return set.Keys, nil
+}
+
+// publicKey converts the key into an *rsa.PublicKey or an *ecdsa.PublicKey.
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid modulus of key %q: %w", k.Kid, err)
+		}
+
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exponent of key %q: %w", k.Kid, err)
+		}
+
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q of key %q", k.Crv, k.Kid)
+		}
+
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid x coordinate of key %q: %w", k.Kid, err)
+		}
+
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("invalid y coordinate of key %q: %w", k.Kid, err)
+		}
+
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q of key %q", k.Kty, k.Kid)
+	}
+}
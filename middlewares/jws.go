@@ -0,0 +1,217 @@
+package middlewares
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+)
+
+// JWSOptions configures the verification of response signatures.
+// Either Key or JWKS must be set.
+type JWSOptions struct {
+	// Key is the static public key of the signer, an *rsa.PublicKey or an *ecdsa.PublicKey.
+	Key crypto.PublicKey
+	// JWKS is the key set of the signer, the key is selected by the kid header of the signature.
+	JWKS *JWKS
+	// Header is the response header carrying detached signatures, "x-jws-signature" by default.
+	// Responses without this header must have a compact JWS as body.
+	Header string
+}
+
+// JWSVerificationError indicates that the signature of a response is missing or invalid.
+type JWSVerificationError struct {
+	Err error
+}
+
+// Error returns the reason of the verification failure.
+func (e *JWSVerificationError) Error() string {
+	return fmt.Sprintf("jws verification failed: %s", e.Err)
+}
+
+// Unwrap returns the reason of the verification failure.
+func (e *JWSVerificationError) Unwrap() error { return e.Err }
+
+// SignatureInvalid reports that the response was rejected because of its signature.
+func (e *JWSVerificationError) SignatureInvalid() bool { return true }
+
+// jwsHeader is the protected header of a JWS token.
+type jwsHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	B64 *bool  `json:"b64"`
+}
+
+// JWSVerifyMiddleware creates a middleware that verifies the signature of successful responses before they are decoded.
+// Detached signatures are read from the configured header; otherwise the body must be a compact JWS, which is replaced by its payload.
+// Unsigned payloads (RFC 7797, "b64": false) are supported. Responses which fail verification are discarded and a *JWSVerificationError is returned.
+func JWSVerifyMiddleware(opts JWSOptions) Middleware {
+	if opts.Header == "" {
+		opts.Header = "x-jws-signature"
+	}
+
+	return func(next Handler) Handler {
+		return func(req *http.Request) (*http.Response, error) {
+			resp, err := next(req)
+			if err != nil || resp == nil || resp.Body == nil || resp.StatusCode >= http.StatusBadRequest {
+				return resp, err
+			}
+
+			body, err := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if err != nil {
+				return nil, err
+			}
+
+			payload, err := opts.verify(resp.Header.Get(opts.Header), body)
+			if err != nil {
+				return nil, &JWSVerificationError{Err: err}
+			}
+
+			ReplaceBody(resp, payload)
+
+			return resp, nil
+		}
+	}
+}
+
+// verify checks the detached signature, or the body when signature is empty, and returns the signed payload.
+func (o JWSOptions) verify(signature string, body []byte) ([]byte, error) {
+	token := signature
+	if token == "" {
+		token = string(bytes.TrimSpace(body))
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed signature")
+	}
+
+	rawHeader, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("malformed signature header: %w", err)
+	}
+
+	var header jwsHeader
+	if err := json.Unmarshal(rawHeader, &header); err != nil {
+		return nil, fmt.Errorf("malformed signature header: %w", err)
+	}
+
+	encoded := header.B64 == nil || *header.B64
+
+	var payload []byte
+	switch {
+	case signature == "":
+		payload = []byte(parts[1])
+	case parts[1] != "":
+		return nil, errors.New("detached signature has a payload")
+	case encoded:
+		payload = []byte(base64.RawURLEncoding.EncodeToString(body))
+	default:
+		payload = body
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("malformed signature: %w", err)
+	}
+
+	key, err := o.key(header.Kid)
+	if err != nil {
+		return nil, err
+	}
+
+	signingInput := append([]byte(parts[0]+"."), payload...)
+	if err := verifySignature(header.Alg, key, signingInput, sig); err != nil {
+		return nil, err
+	}
+
+	if signature != "" {
+		return body, nil
+	}
+
+	if !encoded {
+		return payload, nil
+	}
+
+	return base64.RawURLEncoding.DecodeString(parts[1])
+}
+
+// key returns the verification key with the given ID.
+func (o JWSOptions) key(kid string) (crypto.PublicKey, error) {
+	if o.Key != nil {
+		return o.Key, nil
+	}
+
+	if o.JWKS == nil {
+		return nil, errors.New("no verification key configured")
+	}
+
+	_, key, err := o.JWKS.Key(kid, "sig")
+	return key, err
+}
+
+// verifySignature checks sig against the signing input with the algorithm named by alg.
+func verifySignature(alg string, key crypto.PublicKey, signingInput, sig []byte) error {
+	if len(alg) != 5 {
+		return fmt.Errorf("unsupported algorithm %q", alg)
+	}
+
+	var h crypto.Hash
+	switch alg[2:] {
+	case "256":
+		h = crypto.SHA256
+	case "384":
+		h = crypto.SHA384
+	case "512":
+		h = crypto.SHA512
+	default:
+		return fmt.Errorf("unsupported algorithm %q", alg)
+	}
+
+	hasher := h.New()
+	hasher.Write(signingInput)
+	digest := hasher.Sum(nil)
+
+	switch {
+	case strings.HasPrefix(alg, "RS"), strings.HasPrefix(alg, "PS"):
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("algorithm %q requires an RSA key", alg)
+		}
+
+		if alg[0] == 'P' {
+			return rsa.VerifyPSS(pub, h, digest, sig, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		}
+
+		return rsa.VerifyPKCS1v15(pub, h, digest, sig)
+	case strings.HasPrefix(alg, "ES"):
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("algorithm %q requires an EC key", alg)
+		}
+
+		// The signature is the concatenation of R and S, each padded to the byte size of the curve, see RFC 7518 section 3.4.
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return fmt.Errorf("invalid signature length %d for algorithm %q", len(sig), alg)
+		}
+
+		r, s := new(big.Int).SetBytes(sig[:size]), new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(pub, digest, r, s) {
+			return errors.New("invalid signature")
+		}
+
+		return nil
+	default:
+		return fmt.Errorf("unsupported algorithm %q", alg)
+	}
+}
@@ -0,0 +1,59 @@
+package middlewares
+
+import (
+	"context"
+	"log/slog"
+)
+
+// loggerKey and logArgsKey are the context keys under which the request-scoped logger and log attributes are stored.
+type (
+	loggerKey  struct{}
+	logArgsKey struct{}
+)
+
+// ContextWithLogger returns a copy of ctx carrying the logger used by the middlewares for the requests made with it,
+// instead of the logger of the RequestExecutor.
+func ContextWithLogger(ctx context.Context, logger Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// ContextWithLogAttrs returns a copy of ctx carrying attributes added to every log line of the middlewares for the requests made with it,
+// such as "UserID", id. Args are alternating keys and values, or slog.Attr values, and add to the ones already carried by ctx.
+func ContextWithLogAttrs(ctx context.Context, args ...any) context.Context {
+	prev, _ := ctx.Value(logArgsKey{}).([]any)
+	return context.WithValue(ctx, logArgsKey{}, append(append([]any{}, prev...), args...))
+}
+
+// LoggerFromContext returns the logger carried by ctx, or fallback, with the attributes carried by ctx.
+func LoggerFromContext(ctx context.Context, fallback Logger) Logger {
+	logger := fallback
+	if l, ok := ctx.Value(loggerKey{}).(Logger); ok && l != nil {
+		logger = l
+	}
+
+	args, _ := ctx.Value(logArgsKey{}).([]any)
+	if len(args) == 0 {
+		return logger
+	}
+
+	if l, ok := logger.(*slog.Logger); ok {
+		return l.With(args...)
+	}
+
+	return argsLogger{logger: logger, args: args}
+}
+
+// argsLogger appends attributes to the arguments of every log line of a Logger.
+type argsLogger struct {
+	logger Logger
+	args   []any
+}
+
+func (l argsLogger) Debug(msg string, args ...any) { l.logger.Debug(msg, l.with(args)...) }
+func (l argsLogger) Info(msg string, args ...any)  { l.logger.Info(msg, l.with(args)...) }
+func (l argsLogger) Warn(msg string, args ...any)  { l.logger.Warn(msg, l.with(args)...) }
+func (l argsLogger) Error(msg string, args ...any) { l.logger.Error(msg, l.with(args)...) }
+
+func (l argsLogger) with(args []any) []any {
+	return append(append([]any{}, args...), l.args...)
+}
@@ -3,21 +3,67 @@ package middlewares
 import (
 	"log/slog"
 	"net/http"
+	"sync/atomic"
 )
 
+// LogOptions configures how LoggerMiddleware logs requests.
+// Each request is logged before it is sent, and again once it completes. Failed requests are always logged at error level.
+type LogOptions struct {
+	// Level is the level used for the logs of the requests sent and of the successful ones. Defaults to slog.LevelInfo.
+	Level slog.Level
+	// DisableSuccess turns off the logs of the requests sent and of the successful ones.
+	DisableSuccess bool
+	// SampleRate logs only 1 in SampleRate requests, apart from their errors. Values lower than 2 log all of them.
+	SampleRate uint64
+}
+
 // LoggerMiddleware creates a middleware that logs information about the HTTP request using the provided logger.
-func LoggerMiddleware(logger *slog.Logger) Middleware {
+func LoggerMiddleware(logger Logger) Middleware {
+	return LoggerMiddlewareWithOptions(logger, LogOptions{Level: slog.LevelInfo})
+}
+
+// LoggerMiddlewareWithOptions creates a middleware that logs information about the HTTP request using the provided logger and options.
+func LoggerMiddlewareWithOptions(logger Logger, opts LogOptions) Middleware {
+	var count atomic.Uint64
+
 	return func(next Handler) Handler {
 		return func(r *http.Request) (*http.Response, error) {
-			logger.Info("Executing request", "URL", r.URL.String(), "Method", r.Method)
+			sampled := !opts.DisableSuccess && (opts.SampleRate < 2 || (count.Add(1)-1)%opts.SampleRate == 0)
+
+			if sampled {
+				logAtLevel(LoggerFromContext(r.Context(), logger), opts.Level, "Executing request", "URL", r.URL.String(), "Method", r.Method)
+			}
 
 			response, err := next(r)
 
 			if err != nil {
-				logger.Error("Error on request", "URL", r.URL, "Error", err.Error())
+				LoggerFromContext(r.Context(), logger).Error("Error on request", "URL", r.URL, "Method", r.Method, "Error", err.Error())
+				return response, err
+			}
+
+			if sampled {
+				args := []any{"URL", r.URL.String(), "Method", r.Method}
+				if response != nil {
+					args = append(args, "StatusCode", response.StatusCode)
+				}
+				logAtLevel(LoggerFromContext(r.Context(), logger), opts.Level, "Executed request", args...)
 			}
 
 			return response, err
 		}
 	}
 }
+
+// logAtLevel logs the message with the logger method matching the level.
+func logAtLevel(logger Logger, level slog.Level, msg string, args ...any) {
+	switch {
+	case level < slog.LevelInfo:
+		logger.Debug(msg, args...)
+	case level < slog.LevelWarn:
+		logger.Info(msg, args...)
+	case level < slog.LevelError:
+		logger.Warn(msg, args...)
+	default:
+		logger.Error(msg, args...)
+	}
+}
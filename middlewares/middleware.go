@@ -1,9 +1,62 @@
 package middlewares
 
-import "net/http"
+import (
+	"errors"
+	"io"
+	"net/http"
+)
+
+// errNoReplayableBody is returned when a request has to be sent again but its body cannot be read a second time.
+var errNoReplayableBody = errors.New("request body cannot be replayed")
+
+// maxDrainBytes bounds how much of an unused body is read before closing it.
+// Bodies larger than this are closed without being fully read, dropping the connection instead of reusing it.
+const maxDrainBytes = 4 << 20
 
 // Handler represents a function that processes an HTTP request and returns an HTTP response or an error.
 type Handler func(req *http.Request) (*http.Response, error)
 
 // Middleware represents a function that takes a Handler and returns a new Handler with additional behavior.
 type Middleware func(next Handler) Handler
+
+// DrainBody reads the remaining response body and closes it so the underlying keep-alive connection can be reused.
+// Use it whenever a response is discarded by a middleware without being returned.
+func DrainBody(resp *http.Response) {
+	if resp == nil || resp.Body == nil {
+		return
+	}
+
+	_, _ = io.CopyN(io.Discard, resp.Body, maxDrainBytes)
+	_ = resp.Body.Close()
+}
+
+// Logger is the logging interface used by the middlewares.
+// Args are alternating keys and values, as accepted by *slog.Logger, which satisfies this interface.
+type Logger interface {
+	Debug(msg string, args ...any)
+	Info(msg string, args ...any)
+	Warn(msg string, args ...any)
+	Error(msg string, args ...any)
+}
+
+// rewindRequest returns a copy of the request which can be sent again, with a fresh body obtained from GetBody.
+// It fails when the request has a body which cannot be replayed.
+func rewindRequest(req *http.Request) (*http.Request, error) {
+	clone := req.Clone(req.Context())
+	if req.Body == nil || req.Body == http.NoBody {
+		return clone, nil
+	}
+
+	if req.GetBody == nil {
+		return nil, errNoReplayableBody
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+
+	clone.Body = body
+
+	return clone, nil
+}
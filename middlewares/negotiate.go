@@ -0,0 +1,94 @@
+package middlewares
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"strings"
+)
+
+// TicketProvider obtains the SPNEGO token used by the Negotiate authentication scheme.
+// It is typically backed by a Kerberos client holding a ticket granting ticket for the current user or service.
+type TicketProvider interface {
+	// Token returns the initial SPNEGO token for the service principal name, such as "HTTP/intranet.example.com".
+	Token(ctx context.Context, spn string) ([]byte, error)
+}
+
+// TicketProviderFunc is an adapter allowing a function to be used as a TicketProvider.
+type TicketProviderFunc func(ctx context.Context, spn string) ([]byte, error)
+
+// Token calls f(ctx, spn).
+func (f TicketProviderFunc) Token(ctx context.Context, spn string) ([]byte, error) {
+	return f(ctx, spn)
+}
+
+// NegotiateOptions configures the Negotiate authentication middleware.
+type NegotiateOptions struct {
+	// Preemptive sends the token with the first request instead of waiting for a Negotiate challenge.
+	Preemptive bool
+	// SPN returns the service principal name of the request target. Defaults to "HTTP/" followed by the request host name.
+	SPN func(req *http.Request) string
+}
+
+// NegotiateMiddleware creates a middleware that authenticates requests with the Negotiate (SPNEGO) scheme using tokens of the provider.
+// Unless preemptive, the token is only sent when the server answers with a 401 status and a Negotiate challenge, and the request is then sent again.
+func NegotiateMiddleware(provider TicketProvider, opts NegotiateOptions, logger Logger) Middleware {
+	spn := opts.SPN
+	if spn == nil {
+		spn = func(req *http.Request) string {
+			return "HTTP/" + req.URL.Hostname()
+		}
+	}
+
+	authorize := func(req *http.Request) error {
+		token, err := provider.Token(req.Context(), spn(req))
+		if err != nil {
+			return err
+		}
+
+		req.Header.Set("Authorization", "Negotiate "+base64.StdEncoding.EncodeToString(token))
+		return nil
+	}
+
+	return func(next Handler) Handler {
+		return func(req *http.Request) (*http.Response, error) {
+			if opts.Preemptive {
+				if err := authorize(req); err != nil {
+					LoggerFromContext(req.Context(), logger).Warn("No Negotiate token will be added to the request", "URL", req.URL, "Method", req.Method, "Error", err)
+				}
+
+				return next(req)
+			}
+
+			resp, err := next(req)
+			if err != nil || resp == nil || resp.StatusCode != http.StatusUnauthorized || !hasNegotiateChallenge(resp) {
+				return resp, err
+			}
+
+			retry, err := rewindRequest(req)
+			if err != nil {
+				return resp, nil
+			}
+
+			if err := authorize(retry); err != nil {
+				LoggerFromContext(req.Context(), logger).Warn("Could not answer the Negotiate challenge", "URL", req.URL, "Method", req.Method, "Error", err)
+				return resp, nil
+			}
+
+			DrainBody(resp)
+
+			return next(retry)
+		}
+	}
+}
+
+// hasNegotiateChallenge reports whether the response asks for Negotiate authentication.
+func hasNegotiateChallenge(resp *http.Response) bool {
+	for _, challenge := range resp.Header.Values("WWW-Authenticate") {
+		if strings.HasPrefix(strings.ToLower(challenge), "negotiate") {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,447 @@
+package middlewares
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ErrQueued is returned when a request could not be delivered and was stored in the outbox to be replayed later.
+var ErrQueued = errors.New("request queued in outbox")
+
+// ErrCorruptedOutboxEntry is returned by the stores for an entry which cannot be read, after moving it aside.
+var ErrCorruptedOutboxEntry = errors.New("corrupted outbox entry")
+
+// credentialHeaders lists the headers carrying credentials, which are not stored in the outbox by default.
+var credentialHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"X-Api-Key":           true,
+}
+
+// OutboxHeaderFilter reports whether the request header with the canonical name is stored in the outbox.
+type OutboxHeaderFilter func(name string) bool
+
+// SkipCredentialHeaders is the default OutboxHeaderFilter. It stores the headers except those carrying credentials,
+// Authorization, Proxy-Authorization, Cookie and X-Api-Key, so that they are not written to disk. Credentials needed by the
+// replayed requests are then added by the authorization middlewares added before the outbox, with a token valid at replay time.
+func SkipCredentialHeaders(name string) bool {
+	return !credentialHeaders[name]
+}
+
+// OutboxEntry is a request stored in an outbox.
+type OutboxEntry struct {
+	Method  string      `json:"method"`
+	URL     string      `json:"url"`
+	Header  http.Header `json:"header"`
+	Body    []byte      `json:"body"`
+	Created time.Time   `json:"created"`
+}
+
+// OutboxStore keeps the entries of an outbox in the order they were added.
+type OutboxStore interface {
+	// Push adds an entry at the end of the store.
+	Push(e OutboxEntry) error
+	// Peek returns the oldest entry, false when the store is empty.
+	Peek() (OutboxEntry, bool, error)
+	// Pop removes the oldest entry.
+	Pop() error
+}
+
+// Outbox stores mutating requests which failed to reach the server and replays them in order once it is reachable again.
+type Outbox struct {
+	store   OutboxStore
+	logger  Logger
+	minWait time.Duration
+	maxWait time.Duration
+	headers OutboxHeaderFilter
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+
+	mu        sync.Mutex
+	replaying bool
+	closed    bool
+}
+
+// NewOutbox creates an Outbox persisting its entries in store.
+// Replays are retried with an exponential backoff between minWait and maxWait while the server is unreachable.
+// The headers carrying credentials are not stored, see SkipCredentialHeaders and Outbox.WithHeaderFilter.
+// Close stops the replays.
+func NewOutbox(store OutboxStore, minWait, maxWait time.Duration, logger Logger) *Outbox {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Outbox{store: store, minWait: minWait, maxWait: maxWait, logger: logger, headers: SkipCredentialHeaders, ctx: ctx, cancel: cancel}
+}
+
+// Close stops replaying the stored entries, canceling the replayed request in flight, and waits for the replay to end.
+// Requests failing after Close are still stored, to be replayed by the next outbox using the store.
+func (o *Outbox) Close() {
+	o.mu.Lock()
+	o.closed = true
+	o.mu.Unlock()
+
+	o.cancel()
+	o.wg.Wait()
+}
+
+// WithHeaderFilter sets the filter selecting the request headers stored with the entries.
+// Storing every header, credentials included, replays them as they were sent, even if they expired in the meantime.
+func (o *Outbox) WithHeaderFilter(filter OutboxHeaderFilter) *Outbox {
+	o.headers = filter
+	return o
+}
+
+// OutboxMiddleware creates a middleware that queues POST, PUT, PATCH and DELETE requests in the outbox when the server cannot be reached.
+// While the outbox is not empty, new mutating requests are queued without being sent so that the order is kept.
+// Queued requests fail with an error wrapping ErrQueued. Entries left by a previous process are replayed on the first request.
+func OutboxMiddleware(o *Outbox) Middleware {
+	return func(next Handler) Handler {
+		var start sync.Once
+
+		return func(req *http.Request) (*http.Response, error) {
+			start.Do(func() { o.replay(next) })
+
+			if !isMutating(req.Method) {
+				return next(req)
+			}
+
+			entry, err := o.newEntry(req)
+			if err != nil {
+				return nil, err
+			}
+
+			if _, pending, err := o.store.Peek(); err == nil && pending {
+				return nil, o.enqueue(req.Context(), entry, next, errors.New("earlier requests are pending"))
+			}
+
+			resp, err := next(req)
+			if err != nil && isUnreachable(err) {
+				return nil, o.enqueue(req.Context(), entry, next, err)
+			}
+
+			return resp, err
+		}
+	}
+}
+
+// enqueue stores the entry of the request made with ctx and starts replaying the outbox.
+func (o *Outbox) enqueue(ctx context.Context, entry OutboxEntry, send Handler, cause error) error {
+	if err := o.store.Push(entry); err != nil {
+		return fmt.Errorf("storing request in outbox: %w", err)
+	}
+
+	LoggerFromContext(ctx, o.logger).Warn("Request queued in outbox", "URL", entry.URL, "Method", entry.Method, "Error", cause)
+	o.replay(send)
+
+	return fmt.Errorf("%w: %w", ErrQueued, cause)
+}
+
+// replay sends the stored entries in order in the background, unless a replay is already running or the outbox is closed.
+func (o *Outbox) replay(send Handler) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.replaying || o.closed {
+		return
+	}
+
+	o.replaying = true
+	o.wg.Add(1)
+
+	go func() {
+		defer o.wg.Done()
+
+		attempt := 0
+		for {
+			if o.ctx.Err() != nil {
+				o.stopReplay()
+				return
+			}
+
+			entry, ok, err := o.store.Peek()
+			if errors.Is(err, ErrCorruptedOutboxEntry) {
+				o.logger.Error("Skipping corrupted outbox request", "Error", err)
+				continue
+			}
+
+			if err != nil {
+				o.logger.Error("Reading request from outbox", "Error", err)
+			}
+
+			if err != nil || !ok {
+				o.mu.Lock()
+				if _, ok, err = o.store.Peek(); errors.Is(err, ErrCorruptedOutboxEntry) {
+					o.logger.Error("Skipping corrupted outbox request", "Error", err)
+				} else if err != nil || !ok {
+					o.replaying = false
+					o.mu.Unlock()
+					return
+				}
+				o.mu.Unlock()
+				continue
+			}
+
+			resp, err := send(entry.request(o.ctx))
+			if o.ctx.Err() != nil {
+				DrainBody(resp)
+				o.stopReplay()
+				return
+			}
+
+			if err != nil && isUnreachable(err) {
+				attempt++
+				timer := time.NewTimer(ExponentialBackoffTime(attempt, o.minWait, o.maxWait, nil))
+				select {
+				case <-timer.C:
+				case <-o.ctx.Done():
+					timer.Stop()
+				}
+				continue
+			}
+
+			if err != nil {
+				o.logger.Error("Dropping outbox request", "URL", entry.URL, "Method", entry.Method, "Error", err)
+			} else if resp == nil {
+				o.logger.Error("Dropping outbox request", "URL", entry.URL, "Method", entry.Method, "Error", "empty response")
+			} else if resp.StatusCode >= http.StatusBadRequest {
+				o.logger.Error("Outbox request rejected", "URL", entry.URL, "Method", entry.Method, "StatusCode", resp.StatusCode)
+			}
+
+			DrainBody(resp)
+			attempt = 0
+
+			if err := o.store.Pop(); err != nil {
+				o.logger.Error("Removing request from outbox", "Error", err)
+			}
+		}
+	}()
+}
+
+// stopReplay records that the replay ended, so that the next queued request starts a new one.
+func (o *Outbox) stopReplay() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.replaying = false
+}
+
+// newEntry copies the request with the headers selected by the filter, reading its body without consuming it.
+func (o *Outbox) newEntry(req *http.Request) (OutboxEntry, error) {
+	entry := OutboxEntry{
+		Method:  req.Method,
+		URL:     req.URL.String(),
+		Header:  http.Header{},
+		Created: time.Now(),
+	}
+
+	for name, values := range req.Header {
+		if o.headers == nil || o.headers(http.CanonicalHeaderKey(name)) {
+			entry.Header[name] = append([]string(nil), values...)
+		}
+	}
+
+	if req.Body == nil || req.Body == http.NoBody {
+		return entry, nil
+	}
+
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return entry, err
+	}
+
+	entry.Body = body
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(body)), nil }
+
+	return entry, nil
+}
+
+// request rebuilds the stored request, to be sent with ctx.
+func (e OutboxEntry) request(ctx context.Context) *http.Request {
+	req, _ := http.NewRequestWithContext(ctx, e.Method, e.URL, bytes.NewReader(e.Body))
+	req.Header = e.Header.Clone()
+
+	return req
+}
+
+// isMutating reports whether the method changes the state of the server.
+func isMutating(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// isUnreachable reports whether the error means that the request did not reach the server.
+func isUnreachable(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ENETUNREACH) ||
+		errors.Is(err, syscall.EHOSTUNREACH) ||
+		errors.As(err, &dnsErr)
+}
+
+// MemoryOutboxStore keeps the outbox entries in memory. Entries are lost when the process exits.
+type MemoryOutboxStore struct {
+	mu      sync.Mutex
+	entries []OutboxEntry
+}
+
+// Push adds an entry at the end of the store.
+func (s *MemoryOutboxStore) Push(e OutboxEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries = append(s.entries, e)
+	return nil
+}
+
+// Peek returns the oldest entry, false when the store is empty.
+func (s *MemoryOutboxStore) Peek() (OutboxEntry, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.entries) == 0 {
+		return OutboxEntry{}, false, nil
+	}
+
+	return s.entries[0], true, nil
+}
+
+// Pop removes the oldest entry.
+func (s *MemoryOutboxStore) Pop() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.entries) > 0 {
+		s.entries = s.entries[1:]
+	}
+
+	return nil
+}
+
+// FileOutboxStore persists each outbox entry as a JSON file in a directory, so that entries survive restarts.
+type FileOutboxStore struct {
+	dir string
+
+	mu  sync.Mutex
+	seq uint64
+}
+
+// NewFileOutboxStore creates a FileOutboxStore in dir, creating the directory if needed.
+func NewFileOutboxStore(dir string) (*FileOutboxStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	s := &FileOutboxStore{dir: dir}
+
+	names, err := s.names()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(names) > 0 {
+		s.seq, _ = strconv.ParseUint(strings.TrimSuffix(names[len(names)-1], ".json"), 10, 64)
+	}
+
+	return s, nil
+}
+
+// Push adds an entry at the end of the store.
+func (s *FileOutboxStore) Push(e OutboxEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	s.seq++
+	name := filepath.Join(s.dir, fmt.Sprintf("%020d.json", s.seq))
+
+	if err := os.WriteFile(name+".tmp", data, 0o600); err != nil {
+		return err
+	}
+
+	return os.Rename(name+".tmp", name)
+}
+
+// Peek returns the oldest entry, false when the store is empty.
+func (s *FileOutboxStore) Peek() (OutboxEntry, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names, err := s.names()
+	if err != nil || len(names) == 0 {
+		return OutboxEntry{}, false, err
+	}
+
+	data, err := os.ReadFile(filepath.Join(s.dir, names[0]))
+	if err != nil {
+		return OutboxEntry{}, false, err
+	}
+
+	var e OutboxEntry
+	if err := json.Unmarshal(data, &e); err != nil {
+		name := filepath.Join(s.dir, names[0])
+		if rerr := os.Rename(name, name+".corrupted"); rerr != nil {
+			return OutboxEntry{}, false, fmt.Errorf("moving corrupted outbox entry %s aside: %w", names[0], rerr)
+		}
+
+		return OutboxEntry{}, false, fmt.Errorf("%w %s, moved to %s.corrupted: %w", ErrCorruptedOutboxEntry, names[0], names[0], err)
+	}
+
+	return e, true, nil
+}
+
+// Pop removes the oldest entry.
+func (s *FileOutboxStore) Pop() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names, err := s.names()
+	if err != nil || len(names) == 0 {
+		return err
+	}
+
+	return os.Remove(filepath.Join(s.dir, names[0]))
+}
+
+// names returns the entry files in order.
+func (s *FileOutboxStore) names() ([]string, error) {
+	files, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, f := range files {
+		if !f.IsDir() && strings.HasSuffix(f.Name(), ".json") {
+			names = append(names, f.Name())
+		}
+	}
+
+	sort.Strings(names)
+
+	return names, nil
+}
@@ -1,13 +1,12 @@
 package middlewares
 
 import (
-	"log/slog"
 	"net/http"
 	"time"
 )
 
 // PerformanceMiddleware creates a middleware that logs a warning if the HTTP request takes longer than the specified threshold.
-func PerformanceMiddleware(threshold time.Duration, logger *slog.Logger) Middleware {
+func PerformanceMiddleware(threshold time.Duration, logger Logger) Middleware {
 	return func(next Handler) Handler {
 		return func(req *http.Request) (*http.Response, error) {
 			start := time.Now()
@@ -17,7 +16,7 @@ func PerformanceMiddleware(threshold time.Duration, logger *slog.Logger) Middlew
 			elapsed := time.Since(start)
 
 			if elapsed > threshold {
-				logger.Warn("Slow request", "URL", req.URL, "Elapsed", elapsed)
+				LoggerFromContext(req.Context(), logger).Warn("Slow request", "URL", req.URL, "Elapsed", elapsed)
 			}
 
 			return resp, err
@@ -0,0 +1,169 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Priority ranks requests for load shedding. The zero value is PriorityNormal.
+type Priority int
+
+// Priorities of requests, from the first shed to the last.
+const (
+	PriorityLow    Priority = -1
+	PriorityNormal Priority = 0
+	PriorityHigh   Priority = 1
+)
+
+// String returns the name of the priority.
+func (p Priority) String() string {
+	switch {
+	case p < PriorityNormal:
+		return "low"
+	case p > PriorityNormal:
+		return "high"
+	default:
+		return "normal"
+	}
+}
+
+// priorityKey is the context key under which the priority of a request is stored.
+type priorityKey struct{}
+
+// ContextWithPriority returns a copy of ctx carrying the priority of the requests made with it.
+func ContextWithPriority(ctx context.Context, p Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, p)
+}
+
+// PriorityFromContext returns the priority stored in ctx, PriorityNormal when none is set.
+func PriorityFromContext(ctx context.Context) Priority {
+	p, _ := ctx.Value(priorityKey{}).(Priority)
+	return p
+}
+
+// ShedError is returned when a request is rejected without being sent to relieve the load.
+type ShedError struct {
+	Priority Priority
+	Reason   string
+}
+
+// Error returns the priority of the request and the reason of the load.
+func (e *ShedError) Error() string {
+	return fmt.Sprintf("%s priority request shed: %s", e.Priority, e.Reason)
+}
+
+// LoadShed reports that the request was shed.
+func (e *ShedError) LoadShed() bool { return true }
+
+// LoadShedOptions configures LoadSheddingMiddleware.
+type LoadShedOptions struct {
+	// MinPriority is the lowest priority sent under load. It defaults to PriorityNormal, shedding low priority requests.
+	MinPriority Priority
+	// MaxInFlight is the number of requests in flight from which the load is high. Zero disables the limit.
+	MaxInFlight int
+	// Cooldown is how long the load stays high after a 429 or 503 response, or after a circuit breaker rejected a request.
+	// A longer Retry-After header takes precedence. It defaults to 5s.
+	Cooldown time.Duration
+	// Pressure reports whether the load is high for another reason, such as a rate limiter running out of tokens.
+	Pressure func() bool
+}
+
+// LoadSheddingMiddleware creates a middleware which rejects the requests below the minimum priority with a ShedError while the load is high,
+// instead of queuing them with the requests of higher priority. The priority of a request is read from its context, see ContextWithPriority.
+// Add it after the rate limiting and circuit breaking middlewares, so that it runs before them.
+func LoadSheddingMiddleware(opts LoadShedOptions) Middleware {
+	if opts.Cooldown <= 0 {
+		opts.Cooldown = 5 * time.Second
+	}
+
+	var (
+		inFlight atomic.Int64
+		mu       sync.Mutex
+		until    time.Time
+		reason   string
+	)
+
+	throttle := func(d time.Duration, why string) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if t := time.Now().Add(d); t.After(until) {
+			until, reason = t, why
+		}
+	}
+
+	underLoad := func() (bool, string) {
+		if opts.MaxInFlight > 0 && inFlight.Load() >= int64(opts.MaxInFlight) {
+			return true, fmt.Sprintf("%d requests in flight", opts.MaxInFlight)
+		}
+
+		mu.Lock()
+		throttled, why := time.Now().Before(until), reason
+		mu.Unlock()
+
+		if throttled {
+			return true, why
+		}
+
+		if opts.Pressure != nil && opts.Pressure() {
+			return true, "pressure reported"
+		}
+
+		return false, ""
+	}
+
+	return func(next Handler) Handler {
+		return func(req *http.Request) (*http.Response, error) {
+			priority := PriorityFromContext(req.Context())
+
+			if priority < opts.MinPriority {
+				if loaded, why := underLoad(); loaded {
+					return nil, &ShedError{Priority: priority, Reason: why}
+				}
+			}
+
+			inFlight.Add(1)
+			resp, err := next(req)
+			inFlight.Add(-1)
+
+			var co interface{ CircuitOpen() bool }
+			if err != nil && errors.As(err, &co) && co.CircuitOpen() {
+				throttle(opts.Cooldown, "circuit open")
+			}
+
+			if err == nil && resp != nil && (resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable) {
+				d := opts.Cooldown
+				if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok && retryAfter > d {
+					d = retryAfter
+				}
+
+				throttle(d, fmt.Sprintf("server responded %d", resp.StatusCode))
+			}
+
+			return resp, err
+		}
+	}
+}
+
+// parseRetryAfter parses a Retry-After header holding a number of seconds or an HTTP date.
+func parseRetryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if t, err := http.ParseTime(v); err == nil {
+		return time.Until(t), true
+	}
+
+	return 0, false
+}
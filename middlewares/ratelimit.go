@@ -0,0 +1,151 @@
+package middlewares
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RateLimiter is a token bucket refilled at a number of requests per second, holding up to burst requests.
+// It can be shared by several executors calling the same API, and its Exhausted method fits LoadShedOptions.Pressure.
+type RateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter creates a RateLimiter allowing rps requests per second on average and bursts of up to burst requests.
+// rps must be positive. A burst lower than 1 allows a single request at a time.
+func NewRateLimiter(rps float64, burst int) *RateLimiter {
+	b := float64(max(burst, 1))
+
+	return &RateLimiter{rate: rps, burst: b, tokens: b, last: time.Now()}
+}
+
+// refill adds the tokens accumulated since the last call. It must be called with the lock held.
+func (l *RateLimiter) refill(now time.Time) {
+	l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+}
+
+// reserve takes a token and returns how long to wait until it is available, or gives it back if the wait exceeds maxWait.
+func (l *RateLimiter) reserve(maxWait time.Duration) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.refill(time.Now())
+
+	delay := time.Duration(0)
+	if l.tokens < 1 {
+		delay = time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+	}
+
+	if maxWait > 0 && delay > maxWait {
+		return delay, false
+	}
+
+	l.tokens--
+
+	return delay, true
+}
+
+// cancel gives back a reserved token which was not used.
+func (l *RateLimiter) cancel() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.tokens = min(l.burst, l.tokens+1)
+}
+
+// Allow takes a token if one is available right away.
+func (l *RateLimiter) Allow() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.refill(time.Now())
+	if l.tokens < 1 {
+		return false
+	}
+
+	l.tokens--
+
+	return true
+}
+
+// Wait blocks until a token is available, or until ctx is done.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	delay, _ := l.reserve(0)
+
+	return l.sleep(ctx, delay)
+}
+
+// sleep waits for a reserved token, giving it back when ctx is done first.
+func (l *RateLimiter) sleep(ctx context.Context, delay time.Duration) error {
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		l.cancel()
+		return contextCause(ctx)
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Exhausted reports whether no token is available, meaning that the next request will have to wait.
+func (l *RateLimiter) Exhausted() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.refill(time.Now())
+
+	return l.tokens < 1
+}
+
+// RateLimitError is returned when a request would wait longer than RateLimitOptions.MaxWait for the rate limiter.
+type RateLimitError struct {
+	Wait time.Duration
+}
+
+// Error returns how long the request would have waited.
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("rate limit exceeded, next request allowed in %s", e.Wait.Round(time.Millisecond))
+}
+
+// RateLimited reports that the request was rejected by a rate limiter.
+func (e *RateLimitError) RateLimited() bool { return true }
+
+// RateLimitOptions configures RateLimitMiddleware.
+type RateLimitOptions struct {
+	// MaxWait is the longest a request waits for the rate limiter. Requests which would wait longer fail with a RateLimitError.
+	// Zero waits as long as needed, bounded by the request context.
+	MaxWait time.Duration
+}
+
+// RateLimitMiddleware creates a middleware which throttles the requests with the rate limiter before sending them.
+// Requests block until allowed by default, and fail with the cause of their context if it is done first.
+func RateLimitMiddleware(l *RateLimiter, opts RateLimitOptions) Middleware {
+	return func(next Handler) Handler {
+		return func(req *http.Request) (*http.Response, error) {
+			delay, ok := l.reserve(opts.MaxWait)
+			if !ok {
+				return nil, &RateLimitError{Wait: delay}
+			}
+
+			if err := l.sleep(req.Context(), delay); err != nil {
+				return nil, err
+			}
+
+			return next(req)
+		}
+	}
+}
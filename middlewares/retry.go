@@ -3,6 +3,7 @@ package middlewares
 import (
 	"context"
 	"crypto/x509"
+	"errors"
 	"fmt"
 	"math"
 	"math/rand"
@@ -26,34 +27,60 @@ type RetryHandler struct {
 	MaxWait    time.Duration
 	RetryCount int
 	Backoff    BackoffTime
+	CheckRetry CheckRetry
+	// AttemptHeader, when set, is the header carrying the number of the attempt, starting at 1, for server-side correlation.
+	AttemptHeader string
+	// AttemptTimeout, when set, bounds each attempt until its response headers are received. Attempts cut by it are retried.
+	// Unlike the client timeout, it leaves the time to read the body of the response unbounded.
+	AttemptTimeout time.Duration
+	// MaxElapsedTime, when set, bounds the time spent over all the attempts and the waits between them,
+	// after which the request fails with an error wrapping context.DeadlineExceeded.
+	MaxElapsedTime time.Duration
 }
 
+// RetryStats records the attempts made to send a request and the total time waited between them.
+type RetryStats struct {
+	Attempts int
+	Backoff  time.Duration
+}
+
+// retryStatsKey is the context key under which the RetryStats of a request are stored.
+type retryStatsKey struct{}
+
+// ContextWithRetryStats returns a copy of ctx in which the retry middleware records the attempts of the requests made with it.
+func ContextWithRetryStats(ctx context.Context, stats *RetryStats) context.Context {
+	return context.WithValue(ctx, retryStatsKey{}, stats)
+}
+
+// RetryStatsFromContext returns the RetryStats stored in ctx, or nil when none is.
+// Middlewares added after the retry middleware can read the final stats once the request returns.
+func RetryStatsFromContext(ctx context.Context) *RetryStats {
+	stats, _ := ctx.Value(retryStatsKey{}).(*RetryStats)
+	return stats
+}
+
+// CheckRetry decides if the HTTP request should be retried based on the response and error of the last attempt.
+// The returned error, if any, is reported when the retries are exhausted.
+type CheckRetry func(ctx context.Context, resp *http.Response, err error) (bool, error)
+
 // shouldRetry checks if the HTTP request should be retried based on the response and error.
+// It uses DefaultRetryPolicy when no CheckRetry is configured.
 func (rh *RetryHandler) shouldRetry(ctx context.Context, resp *http.Response, err error) (bool, error) {
-	if ctx.Err() != nil {
-		return false, ctx.Err()
+	if rh.CheckRetry != nil {
+		return rh.CheckRetry(ctx, resp, err)
 	}
 
-	if err != nil {
-		if v, ok := err.(*url.Error); ok {
-			if redirectsErrorRe.MatchString(v.Error()) {
-				return false, v
-			}
-
-			if schemeErrorRe.MatchString(v.Error()) {
-				return false, v
-			}
-
-			if notTrustedErrorRe.MatchString(v.Error()) {
-				return false, v
-			}
+	return DefaultRetryPolicy(ctx, resp, err)
+}
 
-			if _, ok := v.Err.(x509.UnknownAuthorityError); ok {
-				return false, v
-			}
-		}
+// DefaultRetryPolicy retries on transport errors, on http.StatusTooManyRequests and on 5xx responses other than http.StatusNotImplemented.
+func DefaultRetryPolicy(ctx context.Context, resp *http.Response, err error) (bool, error) {
+	if ctx.Err() != nil {
+		return false, contextCause(ctx)
+	}
 
-		return true, nil
+	if err != nil {
+		return isRetryableError(err)
 	}
 
 	if resp.StatusCode == http.StatusTooManyRequests {
@@ -67,6 +94,70 @@ func (rh *RetryHandler) shouldRetry(ctx context.Context, resp *http.Response, er
 	return false, nil
 }
 
+// TransportErrorRetryPolicy retries only on network and transport failures.
+// Responses are never retried, whatever their status code.
+func TransportErrorRetryPolicy(ctx context.Context, resp *http.Response, err error) (bool, error) {
+	if ctx.Err() != nil {
+		return false, contextCause(ctx)
+	}
+
+	if err != nil {
+		return isRetryableError(err)
+	}
+
+	return false, nil
+}
+
+// contextCause returns the error of a done ctx, combined with its context.Cause when a distinct cause was given.
+func contextCause(ctx context.Context) error {
+	err, cause := ctx.Err(), context.Cause(ctx)
+	if cause == nil || cause == err {
+		return err
+	}
+
+	return fmt.Errorf("%w: %w", err, cause)
+}
+
+// isRetryableError checks if a transport error is worth retrying.
+// Errors caused by redirects, unsupported schemes, untrusted certificates, an open circuit, a shed request or an invalid
+// response signature will not go away on a new attempt.
+func isRetryableError(err error) (bool, error) {
+	var co interface{ CircuitOpen() bool }
+	if errors.As(err, &co) && co.CircuitOpen() {
+		return false, err
+	}
+
+	var ls interface{ LoadShed() bool }
+	if errors.As(err, &ls) && ls.LoadShed() {
+		return false, err
+	}
+
+	var si interface{ SignatureInvalid() bool }
+	if errors.As(err, &si) && si.SignatureInvalid() {
+		return false, err
+	}
+
+	if v, ok := err.(*url.Error); ok {
+		if redirectsErrorRe.MatchString(v.Error()) {
+			return false, v
+		}
+
+		if schemeErrorRe.MatchString(v.Error()) {
+			return false, v
+		}
+
+		if notTrustedErrorRe.MatchString(v.Error()) {
+			return false, v
+		}
+
+		if _, ok := v.Err.(x509.UnknownAuthorityError); ok {
+			return false, v
+		}
+	}
+
+	return true, nil
+}
+
 // RetryMiddleware creates a middleware that retries HTTP requests based on the RetryHandler configuration.
 func RetryMiddleware(rh RetryHandler) Middleware {
 	return func(next Handler) Handler {
@@ -76,36 +167,102 @@ func RetryMiddleware(rh RetryHandler) Middleware {
 			var err error
 			var attempt int
 
+			stats := RetryStatsFromContext(req.Context())
+			replayable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+
+			ctx, release := req.Context(), func() {}
+			if rh.MaxElapsedTime > 0 {
+				var cancel context.CancelCauseFunc
+				ctx, cancel = context.WithCancelCause(ctx)
+				timer := time.AfterFunc(rh.MaxElapsedTime, func() {
+					cancel(fmt.Errorf("retries exceeded the maximum elapsed time of %s: %w", rh.MaxElapsedTime, context.DeadlineExceeded))
+				})
+				release = func() {
+					timer.Stop()
+					cancel(nil)
+				}
+			}
+
+			// done returns the error of ctx, the maximum elapsed time when the context of the request is not done itself.
+			done := func() error {
+				if req.Context().Err() == nil {
+					return context.Cause(ctx)
+				}
+
+				return contextCause(req.Context())
+			}
+
 			for ; ; attempt++ {
-				resp, err = next(req)
+				if stats != nil {
+					stats.Attempts = attempt + 1
+				}
+
+				if rh.AttemptHeader != "" {
+					req.Header.Set(rh.AttemptHeader, strconv.Itoa(attempt+1))
+				}
+
+				attemptReq := req
+				if attempt > 0 {
+					if attemptReq, err = rewindRequest(req); err != nil {
+						release()
+						return nil, fmt.Errorf("%s %s giving up after %d attempt(s): %w", req.Method, req.URL, attempt, err)
+					}
+				}
 
-				shouldRetry, err = rh.shouldRetry(req.Context(), resp, err)
+				var attemptRelease func()
+				resp, attemptRelease, err = rh.send(next, attemptReq, ctx)
+
+				shouldRetry, err = rh.shouldRetry(ctx, resp, err)
+				if ctx.Err() != nil {
+					shouldRetry, err = false, done()
+				}
 
 				if !shouldRetry {
+					release = chainRelease(attemptRelease, release)
 					break
 				}
 
 				remain := rh.RetryCount - attempt
-				if remain <= 0 {
+				if remain <= 0 || !replayable {
+					release = chainRelease(attemptRelease, release)
 					break
 				}
 
+				DrainBody(resp)
+				attemptRelease()
+
 				wait := rh.Backoff(attempt, rh.MinWait, rh.MaxWait, resp)
 
 				timer := time.NewTimer(wait)
 				select {
-				case <-req.Context().Done():
+				case <-ctx.Done():
 					timer.Stop()
-					return nil, req.Context().Err()
+					err := done()
+					release()
+					return nil, fmt.Errorf("%s %s giving up after %d attempt(s): %w",
+						req.Method, req.URL, attempt+1, err)
 				case <-timer.C:
 				}
 
+				if stats != nil {
+					stats.Backoff += wait
+				}
+
 			}
 
 			if err == nil && !shouldRetry {
+				if resp == nil || resp.Body == nil {
+					release()
+				} else if rh.AttemptTimeout > 0 || rh.MaxElapsedTime > 0 {
+					resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: release}
+				}
+
 				return resp, nil
 			}
 
+			DrainBody(resp)
+			release()
+
 			if err == nil {
 				return nil, fmt.Errorf("%s %s giving up after %d attempt(s)",
 					req.Method, req.URL, attempt)
@@ -117,6 +274,38 @@ func RetryMiddleware(rh RetryHandler) Middleware {
 	}
 }
 
+// send makes an attempt with the context, bounded by the attempt timeout until the response headers are received.
+// The returned function releases the context of the attempt once its response is no longer used.
+func (rh *RetryHandler) send(next Handler, req *http.Request, ctx context.Context) (*http.Response, func(), error) {
+	if rh.AttemptTimeout <= 0 {
+		if ctx != req.Context() {
+			req = req.WithContext(ctx)
+		}
+
+		resp, err := next(req)
+		return resp, func() {}, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	timer := time.AfterFunc(rh.AttemptTimeout, cancel)
+
+	resp, err := next(req.WithContext(ctx))
+
+	if !timer.Stop() && err != nil {
+		err = fmt.Errorf("attempt timeout of %s exceeded: %w", rh.AttemptTimeout, context.DeadlineExceeded)
+	}
+
+	return resp, cancel, err
+}
+
+// chainRelease returns a function calling both release functions.
+func chainRelease(first, second func()) func() {
+	return func() {
+		first()
+		second()
+	}
+}
+
 // BackoffTime calculates how long to wait between retries.
 type BackoffTime func(retry int, min, max time.Duration, resp *http.Response) time.Duration
 
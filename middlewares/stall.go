@@ -0,0 +1,95 @@
+package middlewares
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// StallError is returned when no response bytes were received for the idle window of StallWatchdogMiddleware.
+type StallError struct {
+	Idle time.Duration
+	Err  error
+}
+
+// Error returns the idle window which was exceeded.
+func (e *StallError) Error() string {
+	return fmt.Sprintf("no response bytes received for %s: %s", e.Idle, e.Err)
+}
+
+// Unwrap returns the error of the aborted read.
+func (e *StallError) Unwrap() error { return e.Err }
+
+// Stalled reports that the transfer was aborted by the watchdog.
+func (e *StallError) Stalled() bool { return true }
+
+// stallBody aborts the request once a read waits for bytes longer than the idle window.
+type stallBody struct {
+	io.ReadCloser
+	idle    time.Duration
+	timer   *time.Timer
+	stalled *atomic.Bool
+	cancel  context.CancelFunc
+}
+
+func (b *stallBody) Read(p []byte) (int, error) {
+	b.timer.Reset(b.idle)
+	n, err := b.ReadCloser.Read(p)
+	b.timer.Stop()
+
+	if err != nil && err != io.EOF && b.stalled.Load() {
+		err = &StallError{Idle: b.idle, Err: err}
+	}
+
+	return n, err
+}
+
+func (b *stallBody) Close() error {
+	b.timer.Stop()
+	err := b.ReadCloser.Close()
+	b.cancel()
+
+	return err
+}
+
+// StallWatchdogMiddleware creates a middleware aborting requests which receive no bytes for the idle window,
+// either while waiting for the response headers or while a read of the body is blocked.
+// Unlike a timeout, it does not bound the whole transfer, so long downloads proceed as long as bytes keep arriving.
+// Time spent by the caller between two reads of the body is not counted.
+func StallWatchdogMiddleware(idle time.Duration) Middleware {
+	return func(next Handler) Handler {
+		return func(req *http.Request) (*http.Response, error) {
+			ctx, cancel := context.WithCancel(req.Context())
+
+			stalled := &atomic.Bool{}
+			timer := time.AfterFunc(idle, func() {
+				stalled.Store(true)
+				cancel()
+			})
+
+			resp, err := next(req.WithContext(ctx))
+			timer.Stop()
+
+			if err != nil {
+				cancel()
+				if stalled.Load() {
+					return resp, &StallError{Idle: idle, Err: err}
+				}
+
+				return resp, err
+			}
+
+			if resp == nil || resp.Body == nil {
+				cancel()
+				return resp, nil
+			}
+
+			resp.Body = &stallBody{ReadCloser: resp.Body, idle: idle, timer: timer, stalled: stalled, cancel: cancel}
+
+			return resp, nil
+		}
+	}
+}
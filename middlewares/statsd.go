@@ -0,0 +1,86 @@
+package middlewares
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StatsDOptions configures the metrics sent by the StatsD middleware.
+type StatsDOptions struct {
+	// Address is the host:port of the StatsD agent, "127.0.0.1:8125" by default.
+	Address string
+	// Prefix is prepended to the metric names, "swiftreq." by default.
+	Prefix string
+	// DogStatsD enables the DogStatsD tag extension. Plain StatsD metrics have no tags.
+	DogStatsD bool
+	// Tags are added to every metric, e.g. "env:prod".
+	Tags []string
+}
+
+// StatsDMiddleware creates a middleware that sends the duration and the count of requests to a StatsD agent over UDP:
+// <prefix>request.duration (timing, in milliseconds), <prefix>request.count and <prefix>request.error (counters).
+// When it is added after the retry middleware, <prefix>request.attempts reports the attempts of each request,
+// as a DogStatsD histogram, or as a timing with plain StatsD.
+// With DogStatsD, metrics are tagged with method, host and status_code in addition to the configured tags.
+// Metrics are sent without waiting for the agent and delivery failures are ignored.
+func StatsDMiddleware(opts StatsDOptions) (Middleware, error) {
+	if opts.Address == "" {
+		opts.Address = "127.0.0.1:8125"
+	}
+
+	if opts.Prefix == "" {
+		opts.Prefix = "swiftreq."
+	}
+
+	conn, err := net.Dial("udp", opts.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(next Handler) Handler {
+		return func(req *http.Request) (*http.Response, error) {
+			start := time.Now()
+
+			resp, err := next(req)
+
+			elapsed := time.Since(start)
+
+			status := "error"
+			if err == nil && resp != nil {
+				status = strconv.Itoa(resp.StatusCode)
+			}
+
+			tags := ""
+			if opts.DogStatsD {
+				tags = "|#" + strings.Join(append(append([]string{}, opts.Tags...),
+					"method:"+req.Method, "host:"+req.URL.Hostname(), "status_code:"+status), ",")
+			}
+
+			metrics := []string{
+				fmt.Sprintf("%srequest.duration:%g|ms%s", opts.Prefix, float64(elapsed.Microseconds())/1000, tags),
+				fmt.Sprintf("%srequest.count:1|c%s", opts.Prefix, tags),
+			}
+
+			if stats := RetryStatsFromContext(req.Context()); stats != nil && stats.Attempts > 0 {
+				histogram := "ms"
+				if opts.DogStatsD {
+					histogram = "h"
+				}
+
+				metrics = append(metrics, fmt.Sprintf("%srequest.attempts:%d|%s%s", opts.Prefix, stats.Attempts, histogram, tags))
+			}
+
+			if err != nil || resp == nil || resp.StatusCode >= http.StatusInternalServerError {
+				metrics = append(metrics, fmt.Sprintf("%srequest.error:1|c%s", opts.Prefix, tags))
+			}
+
+			_, _ = conn.Write([]byte(strings.Join(metrics, "\n")))
+
+			return resp, err
+		}
+	}, nil
+}
@@ -0,0 +1,128 @@
+package middlewares
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// tenantKey is the context key under which the tenant ID is stored.
+type tenantKey struct{}
+
+// ContextWithTenant returns a copy of ctx carrying the tenant ID on whose behalf requests are made.
+func ContextWithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantFromContext returns the tenant ID stored in ctx, if any.
+func TenantFromContext(ctx context.Context) (string, bool) {
+	tenant, ok := ctx.Value(tenantKey{}).(string)
+	return tenant, ok
+}
+
+// TenantAuthorizeFunc is a function type for obtaining the access tokens of a tenant.
+type TenantAuthorizeFunc func(tenant string) (token string, lifeSpan time.Duration, err error)
+
+// TenantTokenRefresher keeps an independently refreshed access token per tenant.
+// Tenants are kept until they are removed, see Remove.
+type TenantTokenRefresher struct {
+	mu         sync.Mutex
+	refreshers map[string]*pendingRefresher
+	authorize  TenantAuthorizeFunc
+	logger     Logger
+
+	Schema string
+}
+
+// NewTenantTokenRefresher creates a new TenantTokenRefresher with the specified schema, authorization function, and logger.
+// The token of a tenant is obtained the first time it is needed, then refreshed in the background.
+func NewTenantTokenRefresher(schema string, fn TenantAuthorizeFunc, logger Logger) *TenantTokenRefresher {
+	return &TenantTokenRefresher{
+		refreshers: map[string]*pendingRefresher{},
+		authorize:  fn,
+		logger:     logger,
+
+		Schema: schema,
+	}
+}
+
+// Get retrieves the current access token of the tenant.
+func (tr *TenantTokenRefresher) Get(tenant string) (string, error) {
+	return tr.refresher(tenant).Get()
+}
+
+// Remove stops refreshing the token of the tenant and forgets it, such as when the tenant is offboarded.
+// The token is obtained again if the tenant is needed later.
+func (tr *TenantTokenRefresher) Remove(tenant string) {
+	tr.mu.Lock()
+	p, ok := tr.refreshers[tenant]
+	delete(tr.refreshers, tenant)
+	tr.mu.Unlock()
+
+	if ok {
+		go func() { p.get().Stop() }()
+	}
+}
+
+// Stop stops refreshing the tokens of all the tenants and forgets them.
+func (tr *TenantTokenRefresher) Stop() {
+	tr.mu.Lock()
+	refreshers := tr.refreshers
+	tr.refreshers = map[string]*pendingRefresher{}
+	tr.mu.Unlock()
+
+	for _, p := range refreshers {
+		go func() { p.get().Stop() }()
+	}
+}
+
+// refresher returns the TokenRefresher of the tenant, creating it on first use.
+// The first token of a tenant is obtained without holding the lock, so that a slow tenant does not delay the others.
+func (tr *TenantTokenRefresher) refresher(tenant string) *TokenRefresher {
+	tr.mu.Lock()
+	p, ok := tr.refreshers[tenant]
+	if !ok {
+		p = newPendingRefresher()
+		tr.refreshers[tenant] = p
+	}
+	tr.mu.Unlock()
+
+	if !ok {
+		p.resolve(NewTokenRefresher(tr.Schema, func() (string, time.Duration, error) {
+			return tr.authorize(tenant)
+		}, tr.logger))
+	}
+
+	return p.get()
+}
+
+// TenantAuthorizeMiddleware creates a middleware that adds the Authorization header of the tenant found in the request context.
+// Requests without tenant are sent without Authorization header.
+func TenantAuthorizeMiddleware(tr *TenantTokenRefresher) Middleware {
+	return func(next Handler) Handler {
+		return func(req *http.Request) (*http.Response, error) {
+			tenant, ok := TenantFromContext(req.Context())
+			if !ok {
+				LoggerFromContext(req.Context(), tr.logger).Warn("No tenant in request context, no token will be added to the request", "URL", req.URL, "Method", req.Method)
+				return next(req)
+			}
+
+			token, err := tr.Get(tenant)
+			if err != nil {
+				LoggerFromContext(req.Context(), tr.logger).Warn("No token will be added to the request", "URL", req.URL, "Method", req.Method, "Tenant", tenant, "Error", err)
+			} else {
+				req.Header.Set("Authorization", fmt.Sprintf("%s %s", tr.Schema, token))
+			}
+
+			return next(req)
+		}
+	}
+}
+
+// TenantIdentity identifies the caller by the tenant found in the request context.
+func TenantIdentity(req *http.Request) string {
+	tenant, _ := TenantFromContext(req.Context())
+	return hashIdentity(tenant)
+}
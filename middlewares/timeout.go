@@ -0,0 +1,151 @@
+package middlewares
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// AdaptiveTimeoutOptions configures AdaptiveTimeoutMiddleware.
+type AdaptiveTimeoutOptions struct {
+	// Percentile of the observed latencies the timeout is derived from. It defaults to 0.99.
+	Percentile float64
+	// Factor multiplies the percentile. It defaults to 2.
+	Factor float64
+	// Min and Max bound the timeout. They default to 100ms and 30s. Max applies until MinSamples latencies are observed.
+	Min time.Duration
+	Max time.Duration
+	// Window is the number of latest latencies kept per route. It defaults to 100.
+	Window int
+	// MinSamples is the number of latencies observed on a route before its timeout adapts. It defaults to 20.
+	MinSamples int
+	// Route groups the requests sharing a timeout. It defaults to the method, host and path of the request.
+	// Provide it when paths hold identifiers, such as /users/{id}, to group them.
+	Route func(req *http.Request) string
+}
+
+// latencyWindow holds the latest latencies of a route.
+type latencyWindow struct {
+	mu      sync.Mutex
+	samples []time.Duration
+	next    int
+}
+
+// add records a latency, replacing the oldest one once the window is full.
+func (w *latencyWindow) add(d time.Duration, size int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.samples) < size {
+		w.samples = append(w.samples, d)
+		return
+	}
+
+	w.samples[w.next] = d
+	w.next = (w.next + 1) % size
+}
+
+// timeout computes the timeout of the route from the percentile of its latencies.
+func (w *latencyWindow) timeout(opts AdaptiveTimeoutOptions) time.Duration {
+	w.mu.Lock()
+	if len(w.samples) < opts.MinSamples {
+		w.mu.Unlock()
+		return opts.Max
+	}
+
+	sorted := append([]time.Duration{}, w.samples...)
+	w.mu.Unlock()
+
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	i := int(math.Ceil(opts.Percentile*float64(len(sorted)))) - 1
+	i = max(0, min(i, len(sorted)-1))
+
+	return max(opts.Min, min(opts.Max, time.Duration(float64(sorted[i])*opts.Factor)))
+}
+
+// cancelBody releases the context of a request once its response body is closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+
+	return err
+}
+
+// AdaptiveTimeoutMiddleware creates a middleware bounding the time until the response headers of each request
+// by a timeout derived from the latencies recently observed on its route, such as twice their p99.
+// Slow but healthy routes get a longer timeout than fast ones, which fail fast.
+// Requests cut by the timeout are recorded with its value, so the timeout grows when a route slows down.
+// The timeout of the client still bounds the whole exchange.
+func AdaptiveTimeoutMiddleware(opts AdaptiveTimeoutOptions) Middleware {
+	if opts.Percentile <= 0 || opts.Percentile > 1 {
+		opts.Percentile = 0.99
+	}
+	if opts.Factor <= 0 {
+		opts.Factor = 2
+	}
+	if opts.Min <= 0 {
+		opts.Min = 100 * time.Millisecond
+	}
+	if opts.Max <= 0 {
+		opts.Max = 30 * time.Second
+	}
+	if opts.Window <= 0 {
+		opts.Window = 100
+	}
+	if opts.MinSamples <= 0 {
+		opts.MinSamples = 20
+	}
+	if opts.Route == nil {
+		opts.Route = func(req *http.Request) string {
+			return req.Method + " " + req.URL.Host + req.URL.Path
+		}
+	}
+
+	var routes sync.Map
+
+	return func(next Handler) Handler {
+		return func(req *http.Request) (*http.Response, error) {
+			v, _ := routes.LoadOrStore(opts.Route(req), &latencyWindow{})
+			window := v.(*latencyWindow)
+
+			timeout := window.timeout(opts)
+			ctx, cancel := context.WithCancel(req.Context())
+			timer := time.AfterFunc(timeout, cancel)
+
+			start := time.Now()
+			resp, err := next(req.WithContext(ctx))
+			elapsed := time.Since(start)
+
+			timedOut := !timer.Stop()
+
+			if err == nil || timedOut {
+				window.add(elapsed, opts.Window)
+			}
+
+			if err != nil && timedOut {
+				cancel()
+				return resp, fmt.Errorf("adaptive timeout of %s exceeded: %w", timeout, context.DeadlineExceeded)
+			}
+
+			if err != nil || resp == nil || resp.Body == nil {
+				cancel()
+				return resp, err
+			}
+
+			resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+
+			return resp, nil
+		}
+	}
+}
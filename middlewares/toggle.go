@@ -0,0 +1,71 @@
+package middlewares
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+// Toggle wraps a middleware which can be enabled, disabled and replaced at runtime, while requests are in flight.
+// A disabled toggle passes the requests directly to the next handler.
+type Toggle struct {
+	enabled atomic.Bool
+	current atomic.Pointer[toggleState]
+}
+
+// toggleState is a version of the middleware of a Toggle.
+type toggleState struct {
+	m Middleware
+}
+
+// toggleHandler is a handler built from a version of the middleware.
+type toggleHandler struct {
+	state   *toggleState
+	handler Handler
+}
+
+// NewToggle creates a Toggle running m when enabled.
+func NewToggle(m Middleware, enabled bool) *Toggle {
+	t := &Toggle{}
+	t.enabled.Store(enabled)
+	t.current.Store(&toggleState{m: m})
+
+	return t
+}
+
+// Enable makes the toggle run its middleware.
+func (t *Toggle) Enable() { t.enabled.Store(true) }
+
+// Disable makes the toggle skip its middleware.
+func (t *Toggle) Disable() { t.enabled.Store(false) }
+
+// SetEnabled enables or disables the toggle.
+func (t *Toggle) SetEnabled(enabled bool) { t.enabled.Store(enabled) }
+
+// Enabled reports whether the toggle runs its middleware.
+func (t *Toggle) Enabled() bool { return t.enabled.Load() }
+
+// Set replaces the middleware of the toggle, such as with a reconfigured one. Requests in flight complete with the previous one.
+func (t *Toggle) Set(m Middleware) { t.current.Store(&toggleState{m: m}) }
+
+// Middleware returns the middleware to add to the pipeline, which runs the current middleware of the toggle when it is enabled.
+func (t *Toggle) Middleware() Middleware {
+	return func(next Handler) Handler {
+		var built atomic.Pointer[toggleHandler]
+
+		return func(req *http.Request) (*http.Response, error) {
+			if !t.enabled.Load() {
+				return next(req)
+			}
+
+			state := t.current.Load()
+
+			h := built.Load()
+			if h == nil || h.state != state {
+				h = &toggleHandler{state: state, handler: state.m(next)}
+				built.Store(h)
+			}
+
+			return h.handler(req)
+		}
+	}
+}
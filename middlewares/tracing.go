@@ -0,0 +1,148 @@
+package middlewares
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// TraceFormat defines the header format used to propagate the trace context.
+type TraceFormat int
+
+const (
+	// TraceFormatW3C propagates the trace context using the W3C traceparent and tracestate headers.
+	TraceFormatW3C TraceFormat = iota
+	// TraceFormatB3 propagates the trace context using the multi header B3 format (X-B3-TraceId, X-B3-SpanId, X-B3-Sampled).
+	TraceFormatB3
+)
+
+// traceContextKey is the context key under which the TraceContext is stored.
+type traceContextKey struct{}
+
+// TraceContext represents the identifiers of the span a request belongs to.
+type TraceContext struct {
+	TraceID string
+	SpanID  string
+	Sampled bool
+	State   string
+}
+
+// ContextWithTrace returns a copy of ctx carrying the trace context.
+func ContextWithTrace(ctx context.Context, tc TraceContext) context.Context {
+	return context.WithValue(ctx, traceContextKey{}, tc)
+}
+
+// TraceFromContext returns the trace context stored in ctx, if any.
+func TraceFromContext(ctx context.Context) (TraceContext, bool) {
+	tc, ok := ctx.Value(traceContextKey{}).(TraceContext)
+	return tc, ok
+}
+
+// ExtractTrace reads the trace context from incoming headers.
+// Both the W3C and B3 (single and multi header) formats are recognized.
+func ExtractTrace(h http.Header) (TraceContext, bool) {
+	if tp := h.Get("traceparent"); tp != "" {
+		parts := strings.Split(tp, "-")
+		if len(parts) == 4 && len(parts[1]) == 32 && len(parts[2]) == 16 && len(parts[3]) == 2 {
+			flags, err := strconv.ParseUint(parts[3], 16, 8)
+			if err == nil {
+				return TraceContext{
+					TraceID: parts[1],
+					SpanID:  parts[2],
+					Sampled: flags&1 == 1,
+					State:   h.Get("tracestate"),
+				}, true
+			}
+		}
+	}
+
+	if b3 := h.Get("b3"); b3 != "" {
+		parts := strings.Split(b3, "-")
+		if len(parts) >= 2 {
+			return TraceContext{
+				TraceID: parts[0],
+				SpanID:  parts[1],
+				Sampled: len(parts) < 3 || parts[2] == "1" || parts[2] == "d",
+			}, true
+		}
+	}
+
+	if traceID := h.Get("X-B3-TraceId"); traceID != "" {
+		sampled := h.Get("X-B3-Sampled")
+		return TraceContext{
+			TraceID: traceID,
+			SpanID:  h.Get("X-B3-SpanId"),
+			Sampled: sampled == "" || sampled == "1" || h.Get("X-B3-Flags") == "1",
+		}, true
+	}
+
+	return TraceContext{}, false
+}
+
+// TraceMiddleware creates a middleware that propagates the trace context found in the request context using the given format.
+// When the request context has no trace context, a new trace is started. 64-bit B3 trace IDs are left-padded with zeros in W3C headers.
+// A new span ID is generated for every outgoing request, and headers already set on the request are left unchanged.
+func TraceMiddleware(format TraceFormat) Middleware {
+	return func(next Handler) Handler {
+		return func(req *http.Request) (*http.Response, error) {
+			parent, ok := TraceFromContext(req.Context())
+			if !ok {
+				parent = TraceContext{TraceID: randomHex(16), Sampled: true}
+			}
+
+			switch format {
+			case TraceFormatB3:
+				if req.Header.Get("X-B3-TraceId") != "" || req.Header.Get("b3") != "" {
+					break
+				}
+
+				req.Header.Set("X-B3-TraceId", parent.TraceID)
+				req.Header.Set("X-B3-SpanId", randomHex(8))
+				if parent.SpanID != "" {
+					req.Header.Set("X-B3-ParentSpanId", parent.SpanID)
+				}
+				req.Header.Set("X-B3-Sampled", sampledFlag(parent.Sampled, "1", "0"))
+			default:
+				if req.Header.Get("traceparent") != "" {
+					break
+				}
+
+				req.Header.Set("traceparent", fmt.Sprintf("00-%s-%s-%s", w3cTraceID(parent.TraceID), randomHex(8), sampledFlag(parent.Sampled, "01", "00")))
+				if parent.State != "" {
+					req.Header.Set("tracestate", parent.State)
+				}
+			}
+
+			return next(req)
+		}
+	}
+}
+
+// w3cTraceID left-pads the trace ID with zeros to the 32 hex digits of a W3C trace ID, as B3 also allows 16 hex digit trace IDs.
+func w3cTraceID(id string) string {
+	if len(id) >= 32 {
+		return id
+	}
+
+	return strings.Repeat("0", 32-len(id)) + id
+}
+
+// sampledFlag returns the header value matching the sampling decision.
+func sampledFlag(sampled bool, yes, no string) string {
+	if sampled {
+		return yes
+	}
+
+	return no
+}
+
+// randomHex returns n random bytes encoded as a lowercase hex string.
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,81 @@
+package middlewares
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// BodyTransformer rewrites the body of a response.
+// It can also update the response headers, for example to remove a Content-Encoding it has decoded.
+type BodyTransformer func(resp *http.Response, body []byte) ([]byte, error)
+
+// TransformResponseMiddleware creates a middleware that buffers the response body and replaces it with the one returned by transform.
+// The original body is closed and the Content-Length of the response is updated to match the new body.
+func TransformResponseMiddleware(transform BodyTransformer) Middleware {
+	return func(next Handler) Handler {
+		return func(req *http.Request) (*http.Response, error) {
+			resp, err := next(req)
+			if err != nil || resp == nil || resp.Body == nil {
+				return resp, err
+			}
+
+			body, err := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if err != nil {
+				return nil, err
+			}
+
+			body, err = transform(resp, body)
+			if err != nil {
+				return nil, err
+			}
+
+			ReplaceBody(resp, body)
+
+			return resp, nil
+		}
+	}
+}
+
+// ReplaceBody sets body as the body of the response and updates its Content-Length accordingly, creating its header if needed.
+// The previous body must already be consumed and closed.
+func ReplaceBody(resp *http.Response, body []byte) {
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	if resp.Header == nil {
+		resp.Header = http.Header{}
+	}
+	resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+}
+
+// StripBOM removes the UTF-8 byte order mark from the beginning of the body.
+func StripBOM(resp *http.Response, body []byte) ([]byte, error) {
+	return bytes.TrimPrefix(body, []byte("\xef\xbb\xbf")), nil
+}
+
+// GunzipBody decompresses gzip encoded bodies which were not decompressed by the transport, and removes their Content-Encoding header.
+func GunzipBody(resp *http.Response, body []byte) ([]byte, error) {
+	if !strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		return body, nil
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	decoded, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, err
+	}
+
+	resp.Header.Del("Content-Encoding")
+	resp.Uncompressed = true
+
+	return decoded, nil
+}
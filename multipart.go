@@ -0,0 +1,153 @@
+package swiftreq
+
+import (
+	"bytes"
+	"encoding"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// encodeMultipart encodes the struct v as a multipart/form-data body and returns it with its content type.
+// Fields tagged with form are written as form values: strings, numbers, booleans, encoding.TextMarshaler values, and slices of them.
+// Fields tagged with file are written as files: an io.Reader, a []byte, or a string holding the path of the file to upload.
+// The file name is given by the filename option of the tag (file:"document,filename=report.pdf"), the path,
+// or the Name method of the reader, such as *os.File. Nil readers, empty []byte values and empty paths are skipped.
+func encodeMultipart(v any) ([]byte, string, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		rv = rv.Elem()
+	}
+
+	if rv.Kind() != reflect.Struct {
+		return nil, "", fmt.Errorf("unsupported multipart type: %T", v)
+	}
+
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+
+	t := rv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		if name, ok := f.Tag.Lookup("form"); ok {
+			if err := writeFormField(w, name, rv.Field(i)); err != nil {
+				return nil, "", fmt.Errorf("field %s: %w", f.Name, err)
+			}
+		}
+
+		if tag, ok := f.Tag.Lookup("file"); ok {
+			if err := writeFileField(w, tag, rv.Field(i)); err != nil {
+				return nil, "", fmt.Errorf("field %s: %w", f.Name, err)
+			}
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, "", err
+	}
+
+	return body.Bytes(), w.FormDataContentType(), nil
+}
+
+// writeFormField writes the value, or each element of a slice, as a form value.
+func writeFormField(w *multipart.Writer, name string, v reflect.Value) error {
+	if v.Kind() == reflect.Slice && v.Type().Elem().Kind() != reflect.Uint8 {
+		for i := 0; i < v.Len(); i++ {
+			if err := writeFormField(w, name, v.Index(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	var value string
+	if tm, ok := v.Interface().(encoding.TextMarshaler); ok {
+		text, err := tm.MarshalText()
+		if err != nil {
+			return err
+		}
+		value = string(text)
+	} else {
+		switch v.Kind() {
+		case reflect.String, reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+			value = fmt.Sprint(v.Interface())
+		default:
+			return fmt.Errorf("unsupported form value type: %s", v.Type())
+		}
+	}
+
+	return w.WriteField(name, value)
+}
+
+// writeFileField writes the content of an io.Reader, a []byte or the file at a path as a form file.
+func writeFileField(w *multipart.Writer, tag string, v reflect.Value) error {
+	name, opts, _ := strings.Cut(tag, ",")
+	filename, _ := strings.CutPrefix(opts, "filename=")
+
+	if (v.Kind() == reflect.Interface || v.Kind() == reflect.Pointer || v.Kind() == reflect.Slice) && v.IsNil() {
+		return nil
+	}
+
+	var content io.Reader
+	switch val := v.Interface().(type) {
+	case []byte:
+		if len(val) == 0 {
+			return nil
+		}
+
+		content = bytes.NewReader(val)
+		if filename == "" {
+			filename = name
+		}
+	case string:
+		if val == "" {
+			return nil
+		}
+
+		f, err := os.Open(val)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		content = f
+		if filename == "" {
+			filename = filepath.Base(val)
+		}
+	case io.Reader:
+		content = val
+		if named, ok := val.(interface{ Name() string }); ok && filename == "" {
+			filename = filepath.Base(named.Name())
+		}
+		if filename == "" {
+			filename = name
+		}
+	default:
+		return fmt.Errorf("unsupported file type: %s", v.Type())
+	}
+
+	part, err := w.CreateFormFile(name, filename)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(part, content)
+
+	return err
+}
@@ -0,0 +1,185 @@
+package swiftreq
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// MultiStatus is a result type for batch endpoints answering 207 Multi-Status, which report a status per item:
+//
+//	results, err := swiftreq.Post[swiftreq.MultiStatus[User]](url, users).Do(ctx)
+//
+// The body is a JSON array of items, or an object holding it under "results", "items" or "responses".
+// Each item holds its "status", as a code or a status line such as "HTTP/1.1 404 Not Found",
+// its result under "body" or "data", and its failure under "error". Items without status are successful.
+type MultiStatus[T any] struct {
+	Items []ItemResult[T]
+}
+
+// ItemResult is the outcome of an item of a batch. Data is set for successful items and Error for failed ones.
+type ItemResult[T any] struct {
+	Index  int
+	Status int
+	Data   *T
+	Error  *ItemError
+}
+
+// ItemError is the failure of an item of a batch.
+type ItemError struct {
+	Index  int
+	Status int
+	// Body is the raw JSON describing the failure, the "error" of the item or the item itself.
+	Body json.RawMessage
+}
+
+// Error returns the index, the status and the body of the failed item.
+func (e *ItemError) Error() string {
+	return fmt.Sprintf("item %d failed with status %d: %s", e.Index, e.Status, e.Body)
+}
+
+// OK reports whether the item succeeded.
+func (r ItemResult[T]) OK() bool { return r.Error == nil }
+
+// Succeeded returns the results of the successful items, in order.
+func (m *MultiStatus[T]) Succeeded() []T {
+	var results []T
+	for _, item := range m.Items {
+		if item.OK() && item.Data != nil {
+			results = append(results, *item.Data)
+		}
+	}
+
+	return results
+}
+
+// Failed returns the failed items, in order.
+func (m *MultiStatus[T]) Failed() []ItemResult[T] {
+	var failed []ItemResult[T]
+	for _, item := range m.Items {
+		if !item.OK() {
+			failed = append(failed, item)
+		}
+	}
+
+	return failed
+}
+
+// Err joins the errors of the failed items, nil when every item succeeded.
+func (m *MultiStatus[T]) Err() error {
+	var errs []error
+	for _, item := range m.Items {
+		if item.Error != nil {
+			errs = append(errs, item.Error)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// multiStatusItem is an item of a multi-status body as sent on the wire.
+type multiStatusItem struct {
+	Status json.RawMessage `json:"status"`
+	Body   json.RawMessage `json:"body"`
+	Data   json.RawMessage `json:"data"`
+	Error  json.RawMessage `json:"error"`
+}
+
+// UnmarshalJSON decodes the items of a multi-status body.
+func (m *MultiStatus[T]) UnmarshalJSON(data []byte) error {
+	var raw []json.RawMessage
+
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		var envelope map[string]json.RawMessage
+		if err := json.Unmarshal(trimmed, &envelope); err != nil {
+			return err
+		}
+
+		for _, key := range []string{"results", "items", "responses"} {
+			if items, ok := envelope[key]; ok {
+				data = items
+				break
+			}
+		}
+	}
+
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	m.Items = make([]ItemResult[T], len(raw))
+	for i, itemData := range raw {
+		var item multiStatusItem
+		if err := json.Unmarshal(itemData, &item); err != nil {
+			return fmt.Errorf("item %d: %w", i, err)
+		}
+
+		status, err := parseItemStatus(item.Status)
+		if err != nil {
+			return fmt.Errorf("item %d: %w", i, err)
+		}
+
+		result := ItemResult[T]{Index: i, Status: status}
+
+		if !isNull(item.Error) || status >= http.StatusBadRequest {
+			body := item.Error
+			if isNull(body) {
+				body = itemData
+			}
+
+			result.Error = &ItemError{Index: i, Status: status, Body: body}
+			m.Items[i] = result
+			continue
+		}
+
+		value := item.Body
+		if isNull(value) {
+			value = item.Data
+		}
+
+		if !isNull(value) {
+			result.Data = new(T)
+			if err := json.Unmarshal(value, result.Data); err != nil {
+				return fmt.Errorf("item %d: %w", i, err)
+			}
+		}
+
+		m.Items[i] = result
+	}
+
+	return nil
+}
+
+// parseItemStatus parses the status of an item, a number or a string holding a status line. A missing status is 200 OK.
+func parseItemStatus(raw json.RawMessage) (int, error) {
+	if isNull(raw) {
+		return http.StatusOK, nil
+	}
+
+	var code int
+	if err := json.Unmarshal(raw, &code); err == nil {
+		return code, nil
+	}
+
+	var line string
+	if err := json.Unmarshal(raw, &line); err != nil {
+		return 0, fmt.Errorf("invalid status %s", raw)
+	}
+
+	for _, field := range strings.Fields(line) {
+		if code, err := strconv.Atoi(field); err == nil {
+			return code, nil
+		}
+	}
+
+	return 0, fmt.Errorf("invalid status %q", line)
+}
+
+// isNull reports whether a raw JSON value is missing or null.
+func isNull(raw json.RawMessage) bool {
+	return len(raw) == 0 || string(raw) == "null"
+}
@@ -0,0 +1,197 @@
+package swiftreq
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// NamingStrategy converts the name of a Go struct field, such as UserID, into the key used on the wire.
+// Fields with a name in their json tag keep it.
+type NamingStrategy func(field string) string
+
+// SnakeCase converts field names to snake_case keys: UserID becomes user_id.
+func SnakeCase(field string) string { return delimitWords(field, '_') }
+
+// KebabCase converts field names to kebab-case keys: UserID becomes user-id.
+func KebabCase(field string) string { return delimitWords(field, '-') }
+
+// CamelCase converts field names to camelCase keys: UserID becomes userId.
+func CamelCase(field string) string {
+	words := strings.Split(delimitWords(field, '_'), "_")
+	for i := 1; i < len(words); i++ {
+		if words[i] != "" {
+			words[i] = strings.ToUpper(words[i][:1]) + words[i][1:]
+		}
+	}
+
+	return strings.Join(words, "")
+}
+
+// delimitWords splits a mixed caps name into lowercase words joined by sep. Acronyms are kept as one word: HTTPServer becomes http_server.
+func delimitWords(name string, sep rune) string {
+	runes := []rune(name)
+
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i > 0 {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				b.WriteRune(sep)
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+
+	return b.String()
+}
+
+var (
+	jsonMarshalerType   = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+)
+
+// jsonField describes a field of a struct as seen by encoding/json.
+type jsonField struct {
+	// key is the key used by encoding/json, the tag name or the field name.
+	key string
+	// wire is the key used on the wire.
+	wire string
+	typ  reflect.Type
+}
+
+// jsonFields lists the fields of the struct type t, including the promoted fields of embedded structs.
+// Without naming strategy, the wire key of an untagged field is its name.
+func jsonFields(t reflect.Type, naming NamingStrategy) []jsonField {
+	var fields []jsonField
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name, _, _ := strings.Cut(tag, ",")
+
+		ft := f.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			fields = append(fields, jsonFields(ft, naming)...)
+			continue
+		}
+
+		if !f.IsExported() {
+			continue
+		}
+
+		field := jsonField{key: name, wire: name, typ: f.Type}
+		if name == "" {
+			field.key = f.Name
+			field.wire = f.Name
+			if naming != nil {
+				field.wire = naming(f.Name)
+			}
+		}
+
+		fields = append(fields, field)
+	}
+
+	return fields
+}
+
+// customJSON reports whether values of type t are encoded by their own json.Marshaler or json.Unmarshaler.
+func customJSON(t reflect.Type) bool {
+	return t.Implements(jsonMarshalerType) || t.Implements(jsonUnmarshalerType) ||
+		reflect.PointerTo(t).Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonUnmarshalerType)
+}
+
+// jsonRewriter rewrites generic JSON values, guided by the Go type they are decoded into or encoded from.
+// It converts the keys of struct fields between the wire and encoding/json, and the registered time layouts to RFC 3339 when decoding.
+type jsonRewriter struct {
+	naming  NamingStrategy
+	toWire  bool
+	layouts []string
+}
+
+// rewrite returns the value v for the type t. Only the keys of struct fields are renamed; map keys are kept.
+func (w jsonRewriter) rewrite(v any, t reflect.Type) any {
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if t == nil {
+		return v
+	}
+
+	if t == timeType && !w.toWire && len(w.layouts) > 0 {
+		return convertTime(v, w.layouts)
+	}
+
+	if customJSON(t) {
+		return v
+	}
+
+	switch val := v.(type) {
+	case map[string]any:
+		switch t.Kind() {
+		case reflect.Struct:
+			fields := jsonFields(t, w.naming)
+			out := make(map[string]any, len(val))
+			for k, x := range val {
+				f, ok := findJSONField(fields, k, w.toWire)
+				if !ok {
+					out[k] = x
+					continue
+				}
+
+				if w.toWire {
+					out[f.wire] = w.rewrite(x, f.typ)
+				} else {
+					out[f.key] = w.rewrite(x, f.typ)
+				}
+			}
+			return out
+		case reflect.Map:
+			for k, x := range val {
+				val[k] = w.rewrite(x, t.Elem())
+			}
+		}
+	case []any:
+		if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+			for i, x := range val {
+				val[i] = w.rewrite(x, t.Elem())
+			}
+		}
+	}
+
+	return v
+}
+
+// findJSONField returns the field of a key: an encoding/json key when encoding, a wire key when decoding.
+// Wire keys are matched case-insensitively when there is no exact match, like encoding/json does.
+func findJSONField(fields []jsonField, key string, toWire bool) (jsonField, bool) {
+	for _, f := range fields {
+		if (toWire && f.key == key) || (!toWire && f.wire == key) {
+			return f, true
+		}
+	}
+
+	if toWire {
+		return jsonField{}, false
+	}
+
+	for _, f := range fields {
+		if strings.EqualFold(f.wire, key) {
+			return f, true
+		}
+	}
+
+	return jsonField{}, false
+}
@@ -0,0 +1,29 @@
+package swiftreq
+
+import (
+	"context"
+	"sync"
+)
+
+// OnceRequest executes a request a single time and returns its result to every caller.
+type OnceRequest[T any] struct {
+	req  *Request[T]
+	once sync.Once
+	resp *T
+	err  error
+}
+
+// Once wraps the request so that it is executed only once, e.g. for configuration or discovery documents.
+func Once[T any](req *Request[T]) *OnceRequest[T] {
+	return &OnceRequest[T]{req: req}
+}
+
+// Do executes the request on the first call and returns the same response and error on every call.
+// Concurrent callers wait for the first execution to complete. The request runs with the context of the first caller.
+func (o *OnceRequest[T]) Do(ctx context.Context) (*T, error) {
+	o.once.Do(func() {
+		o.resp, o.err = o.req.Do(ctx)
+	})
+
+	return o.resp, o.err
+}
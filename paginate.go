@@ -0,0 +1,171 @@
+package swiftreq
+
+import (
+	"context"
+	"iter"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// NextPageFunc returns the request of the page following page, or nil when page is the last one.
+type NextPageFunc[T any] func(page []T, meta *ResponseMeta) *Request[[]T]
+
+// List returns an iterator over the items of a paginated endpoint.
+// The first page is fetched with req, and the following ones are fetched lazily with the requests returned by next.
+// Iteration stops at the first error, which is yielded with the zero value of T, or when the loop breaks.
+//
+//	for post, err := range swiftreq.List(ctx, swiftreq.Get[[]Post](url), next) {
+//		...
+//	}
+func List[T any](ctx context.Context, req *Request[[]T], next NextPageFunc[T]) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for req != nil {
+			page, meta, err := req.DoWithResponse(ctx)
+			if err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+
+			for _, item := range *page {
+				if !yield(item, nil) {
+					return
+				}
+			}
+
+			req = next(*page, meta)
+		}
+	}
+}
+
+// PaginateOptions configures Paginate.
+type PaginateOptions struct {
+	// MaxPages is the number of pages after which the iteration stops, even if there is a next page. Zero fetches every page.
+	MaxPages int
+}
+
+// Paginate returns an iterator over the pages of an endpoint paginated with Link headers, as GitHub-style APIs are (RFC 8288).
+// The first page is fetched with req, and the following ones with a copy of req sent to the URL of the rel="next" link of the response,
+// until a response has no next link or MaxPages pages were fetched.
+// Iteration stops at the first error, which is yielded with a nil page, or when the loop breaks.
+//
+//	for page, err := range swiftreq.Paginate(ctx, swiftreq.Get[[]Repo](url), swiftreq.PaginateOptions{MaxPages: 10}) {
+//		...
+//	}
+func Paginate[T any](ctx context.Context, req *Request[[]T], opts PaginateOptions) iter.Seq2[[]T, error] {
+	return func(yield func([]T, error) bool) {
+		for pages := 0; req != nil && (opts.MaxPages <= 0 || pages < opts.MaxPages); pages++ {
+			page, meta, err := req.DoWithResponse(ctx)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+
+			var items []T
+			if page != nil {
+				items = *page
+			}
+
+			if !yield(items, nil) {
+				return
+			}
+
+			next, ok := NextLink(meta)
+			if !ok {
+				return
+			}
+
+			following := *req
+			following.url = next
+			following.queryParameters = nil
+			following.headers = req.headers.Clone()
+			req = &following
+		}
+	}
+}
+
+// NextLink returns the URL of the rel="next" link in the Link headers of the response, resolved against its final URL.
+func NextLink(meta *ResponseMeta) (string, bool) {
+	if meta == nil {
+		return "", false
+	}
+
+	for _, link := range parseLinks(meta.Header) {
+		if link.rel != "next" {
+			continue
+		}
+
+		target, err := url.Parse(link.target)
+		if err != nil {
+			return "", false
+		}
+
+		if meta.FinalURL != nil {
+			target = meta.FinalURL.ResolveReference(target)
+		}
+
+		return target.String(), true
+	}
+
+	return "", false
+}
+
+// link is a target of a Link header with one of its relation types.
+type link struct {
+	target string
+	rel    string
+}
+
+// parseLinks parses the Link headers, such as `<https://api.example.com/items?page=2>; rel="next", <...>; rel="last"`.
+// A link with several relation types, such as rel="next last", is returned once per type.
+func parseLinks(header http.Header) []link {
+	var links []link
+
+	for _, value := range header.Values("Link") {
+		for _, part := range splitLinks(value) {
+			target, params, ok := strings.Cut(part, ";")
+			target = strings.TrimSpace(target)
+			if !ok || !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+			target = target[1 : len(target)-1]
+
+			for _, param := range strings.Split(params, ";") {
+				name, value, _ := strings.Cut(param, "=")
+				if !strings.EqualFold(strings.TrimSpace(name), "rel") {
+					continue
+				}
+
+				for _, rel := range strings.Fields(strings.Trim(strings.TrimSpace(value), `"`)) {
+					links = append(links, link{target: target, rel: strings.ToLower(rel)})
+				}
+			}
+		}
+	}
+
+	return links
+}
+
+// splitLinks splits a Link header value on the commas separating its links, ignoring the commas within URLs and quoted parameters.
+func splitLinks(value string) []string {
+	var parts []string
+	var inURL, inQuotes bool
+	start := 0
+
+	for i, c := range value {
+		switch {
+		case c == '<' && !inQuotes:
+			inURL = true
+		case c == '>' && !inQuotes:
+			inURL = false
+		case c == '"' && !inURL:
+			inQuotes = !inQuotes
+		case c == ',' && !inURL && !inQuotes:
+			parts = append(parts, value[start:i])
+			start = i + 1
+		}
+	}
+
+	return append(parts, value[start:])
+}
@@ -0,0 +1,215 @@
+package swiftreq
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Patch creates a new HTTP PATCH request with the specified payload.
+// JSONPatch and MergePatch payloads are sent with their application/json-patch+json and application/merge-patch+json content types.
+func Patch[T any](url string, payload interface{}) *Request[T] {
+	return newDefaultRequest[T]().
+		WithMethod("PATCH").
+		WithURL(url).
+		WithPayload(payload)
+}
+
+// contentTyper is implemented by payloads which are sent with a specific content type.
+type contentTyper interface {
+	ContentType() string
+}
+
+// PatchOperation is an operation of a JSON Patch document (RFC 6902).
+type PatchOperation struct {
+	Op    string
+	Path  string
+	From  string
+	Value any
+}
+
+// MarshalJSON encodes the operation with the members required by its type.
+func (o PatchOperation) MarshalJSON() ([]byte, error) {
+	m := map[string]any{"op": o.Op, "path": o.Path}
+
+	switch o.Op {
+	case "add", "replace", "test":
+		m["value"] = o.Value
+	case "move", "copy":
+		m["from"] = o.From
+	}
+
+	return json.Marshal(m)
+}
+
+// JSONPatch is a JSON Patch document (RFC 6902), built with its fluent methods:
+//
+//	patch := swiftreq.JSONPatch{}.Replace("/title", "New title").Remove("/draft")
+type JSONPatch []PatchOperation
+
+// ContentType returns application/json-patch+json.
+func (p JSONPatch) ContentType() string { return "application/json-patch+json" }
+
+// Add appends an operation adding the value at path.
+func (p JSONPatch) Add(path string, value any) JSONPatch {
+	return append(p, PatchOperation{Op: "add", Path: path, Value: value})
+}
+
+// Remove appends an operation removing the value at path.
+func (p JSONPatch) Remove(path string) JSONPatch {
+	return append(p, PatchOperation{Op: "remove", Path: path})
+}
+
+// Replace appends an operation replacing the value at path.
+func (p JSONPatch) Replace(path string, value any) JSONPatch {
+	return append(p, PatchOperation{Op: "replace", Path: path, Value: value})
+}
+
+// Move appends an operation moving the value at from to path.
+func (p JSONPatch) Move(from, path string) JSONPatch {
+	return append(p, PatchOperation{Op: "move", Path: path, From: from})
+}
+
+// Copy appends an operation copying the value at from to path.
+func (p JSONPatch) Copy(from, path string) JSONPatch {
+	return append(p, PatchOperation{Op: "copy", Path: path, From: from})
+}
+
+// Test appends an operation checking that the value at path equals value.
+func (p JSONPatch) Test(path string, value any) JSONPatch {
+	return append(p, PatchOperation{Op: "test", Path: path, Value: value})
+}
+
+// JSONPointer builds a JSON Pointer (RFC 6901) from its reference tokens, escaping "~" and "/".
+func JSONPointer(tokens ...string) string {
+	var b strings.Builder
+	for _, t := range tokens {
+		b.WriteString("/")
+		b.WriteString(strings.NewReplacer("~", "~0", "/", "~1").Replace(t))
+	}
+
+	return b.String()
+}
+
+// MergePatch is a JSON Merge Patch document (RFC 7396). A nil value removes the member.
+type MergePatch map[string]any
+
+// ContentType returns application/merge-patch+json.
+func (p MergePatch) ContentType() string { return "application/merge-patch+json" }
+
+// DiffMergePatch returns the merge patch turning the JSON encoding of old into the one of new.
+func DiffMergePatch(old, new any) (MergePatch, error) {
+	o, n, err := jsonObjects(old, new)
+	if err != nil {
+		return nil, err
+	}
+
+	return diffMerge(o, n), nil
+}
+
+// DiffJSONPatch returns the JSON Patch operations turning the JSON encoding of old into the one of new.
+// Objects are compared member by member; arrays and other values are replaced as a whole.
+func DiffJSONPatch(old, new any) (JSONPatch, error) {
+	o, n, err := jsonObjects(old, new)
+	if err != nil {
+		return nil, err
+	}
+
+	return diffJSON(JSONPatch{}, "", o, n), nil
+}
+
+// jsonObjects returns the generic JSON encodings of old and new.
+func jsonObjects(old, new any) (any, any, error) {
+	var o, n any
+	for _, v := range []struct {
+		in  any
+		out *any
+	}{{old, &o}, {new, &n}} {
+		data, err := json.Marshal(v.in)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if *v.out, err = decodeGeneric(data); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return o, n, nil
+}
+
+// diffMerge returns the merge patch of two generic JSON objects.
+func diffMerge(old, new any) MergePatch {
+	o, _ := old.(map[string]any)
+	n, _ := new.(map[string]any)
+
+	patch := MergePatch{}
+	for k := range o {
+		if _, ok := n[k]; !ok {
+			patch[k] = nil
+		}
+	}
+
+	for k, nv := range n {
+		ov, ok := o[k]
+		switch {
+		case !ok:
+			patch[k] = nv
+		case reflect.DeepEqual(ov, nv):
+		case isObject(ov) && isObject(nv):
+			patch[k] = diffMerge(ov, nv)
+		default:
+			patch[k] = nv
+		}
+	}
+
+	return patch
+}
+
+// diffJSON appends the operations turning old into new at path.
+func diffJSON(patch JSONPatch, path string, old, new any) JSONPatch {
+	if reflect.DeepEqual(old, new) {
+		return patch
+	}
+
+	if !isObject(old) || !isObject(new) {
+		return patch.Replace(path, new)
+	}
+
+	o, n := old.(map[string]any), new.(map[string]any)
+
+	keys := make([]string, 0, len(o)+len(n))
+	for k := range o {
+		keys = append(keys, k)
+	}
+	for k := range n {
+		if _, ok := o[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		ov, inOld := o[k]
+		nv, inNew := n[k]
+		member := path + JSONPointer(k)
+
+		switch {
+		case !inNew:
+			patch = patch.Remove(member)
+		case !inOld:
+			patch = patch.Add(member, nv)
+		default:
+			patch = diffJSON(patch, member, ov, nv)
+		}
+	}
+
+	return patch
+}
+
+// isObject reports whether the generic JSON value is an object.
+func isObject(v any) bool {
+	_, ok := v.(map[string]any)
+	return ok
+}
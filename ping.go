@@ -0,0 +1,65 @@
+package swiftreq
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"time"
+
+	"github.com/liviudnicoara/swiftreq/middlewares"
+)
+
+// PingResult holds the outcome and the phase timings of a ping.
+// The DNS, Connect and TLS phases are zero when a pooled connection was reused.
+type PingResult struct {
+	Reachable  bool
+	StatusCode int
+	Reused     bool
+
+	DNS     time.Duration
+	Connect time.Duration
+	TLS     time.Duration
+	// TTFB is the time from the start of the request to the first byte of the response.
+	TTFB  time.Duration
+	Total time.Duration
+}
+
+// Ping sends a HEAD request to url with the client of the executor, bypassing the middlewares, and measures the phases of the request.
+// The URL is reachable when any response is received, whatever its status code.
+// The result is returned along with the error when the request fails, with the timings of the phases which completed.
+func (re *RequestExecutor) Ping(ctx context.Context, url string) (*PingResult, error) {
+	result := &PingResult{}
+
+	var start, dnsStart, connectStart, tlsStart time.Time
+	trace := &httptrace.ClientTrace{
+		GotConn:              func(info httptrace.GotConnInfo) { result.Reused = info.Reused },
+		DNSStart:             func(httptrace.DNSStartInfo) { dnsStart = time.Now() },
+		DNSDone:              func(httptrace.DNSDoneInfo) { result.DNS = time.Since(dnsStart) },
+		ConnectStart:         func(string, string) { connectStart = time.Now() },
+		ConnectDone:          func(string, string, error) { result.Connect = time.Since(connectStart) },
+		TLSHandshakeStart:    func() { tlsStart = time.Now() },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { result.TLS = time.Since(tlsStart) },
+		GotFirstResponseByte: func() { result.TTFB = time.Since(start) },
+	}
+
+	req, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, trace), http.MethodHead, url, nil)
+	if err != nil {
+		return result, &Error{Message: "could not create request " + url, Cause: err}
+	}
+
+	start = time.Now()
+	resp, err := re.httpClient().Do(req)
+	result.Total = time.Since(start)
+
+	if err != nil {
+		return result, &Error{Message: "failed to ping " + url, Cause: classifyTransportError(req.Context(), err), Method: req.Method, URL: url}
+	}
+
+	middlewares.DrainBody(resp)
+
+	result.Reachable = true
+	result.StatusCode = resp.StatusCode
+
+	return result, nil
+}
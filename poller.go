@@ -0,0 +1,109 @@
+package swiftreq
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const (
+	// pollJitter is the fraction of the interval by which each wait is randomly shortened or extended.
+	pollJitter = 0.1
+
+	// maxPollBackoff bounds the wait after consecutive failures, as a multiple of the interval.
+	maxPollBackoff = 8
+
+	// defaultPollInterval replaces the intervals which are not positive, which would make the poller spin.
+	defaultPollInterval = time.Second
+)
+
+// PollFunc is a job run by a Poller. A returned error counts as a failure and delays the next run.
+type PollFunc func(ctx context.Context, re *RequestExecutor) error
+
+// Poll creates a PollFunc which executes req with the executor of the poller and passes the response to handler.
+// Each run sends a copy of req, which is left bound to its own executor. Request errors are returned without calling handler.
+// As methods cannot have type parameters, typed requests are scheduled with re.Every(interval, swiftreq.Poll(req, handler)).
+func Poll[T any](req *Request[T], handler func(resp *T) error) PollFunc {
+	return func(ctx context.Context, re *RequestExecutor) error {
+		run := *req
+		run.re = re
+
+		resp, err := run.Do(ctx)
+		if err != nil {
+			return err
+		}
+
+		return handler(resp)
+	}
+}
+
+// Poller runs a job on a recurring interval until it is stopped.
+type Poller struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+	once   sync.Once
+}
+
+// Every runs job right away and then on every interval, until Stop is called.
+// Waits are randomized by 10% to spread the load of many pollers. After a failure the wait is doubled, up to 8 intervals,
+// and it is reset by the next successful run. Failures are logged with the executor logger.
+// Intervals which are not positive are replaced by one second. Requests are scheduled with Poll.
+func (re *RequestExecutor) Every(interval time.Duration, job PollFunc) *Poller {
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &Poller{cancel: cancel, done: make(chan struct{})}
+
+	go func() {
+		defer close(p.done)
+
+		failures := 0
+		for {
+			if err := job(ctx, re); err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+
+				re.Logger.Warn("Polling failed", "Error", err, "Failures", failures+1)
+				failures++
+			} else {
+				failures = 0
+			}
+
+			timer := time.NewTimer(pollWait(interval, failures))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+		}
+	}()
+
+	return p
+}
+
+// Stop stops the poller and waits for the running job, if any, to return. The context of the job is canceled.
+func (p *Poller) Stop() {
+	p.once.Do(p.cancel)
+	<-p.done
+}
+
+// pollWait returns the jittered wait before the next run.
+func pollWait(interval time.Duration, failures int) time.Duration {
+	wait := interval
+	for i := 0; i < failures && wait < interval*maxPollBackoff; i++ {
+		wait *= 2
+	}
+
+	if wait > interval*maxPollBackoff {
+		wait = interval * maxPollBackoff
+	}
+
+	jitter := (rand.Float64()*2 - 1) * pollJitter * float64(wait)
+
+	return wait + time.Duration(jitter)
+}
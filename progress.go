@@ -0,0 +1,69 @@
+package swiftreq
+
+import (
+	"io"
+	"net/http"
+)
+
+// ProgressFunc receives the number of bytes transferred so far and the total size of the body, or -1 when it is unknown.
+type ProgressFunc func(transferred, total int64)
+
+// WithProgress calls progress as the request body is sent, then as the response body is received.
+// The count starts again from zero for the response body, and for the request body when it is sent again on a retry.
+// Uploads are reported from the goroutine of the transport.
+func (r *Request[T]) WithProgress(progress ProgressFunc) *Request[T] {
+	r.progress = progress
+	return r
+}
+
+// progressBody reports the bytes read from the body to a ProgressFunc.
+type progressBody struct {
+	io.ReadCloser
+	progress    ProgressFunc
+	total       int64
+	transferred int64
+}
+
+func (b *progressBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.transferred += int64(n)
+		b.progress(b.transferred, b.total)
+	}
+
+	return n, err
+}
+
+// withUploadProgress reports the progress of the body of req, including the bodies recreated for retries.
+func withUploadProgress(req *http.Request, progress ProgressFunc) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return
+	}
+
+	total := req.ContentLength
+	if total <= 0 {
+		total = -1
+	}
+
+	req.Body = &progressBody{ReadCloser: req.Body, progress: progress, total: total}
+
+	if getBody := req.GetBody; getBody != nil {
+		req.GetBody = func() (io.ReadCloser, error) {
+			body, err := getBody()
+			if err != nil {
+				return nil, err
+			}
+
+			return &progressBody{ReadCloser: body, progress: progress, total: total}, nil
+		}
+	}
+}
+
+// withDownloadProgress reports the progress of the body of res.
+func withDownloadProgress(res *http.Response, progress ProgressFunc) {
+	if res.Body == nil || res.Body == http.NoBody {
+		return
+	}
+
+	res.Body = &progressBody{ReadCloser: res.Body, progress: progress, total: res.ContentLength}
+}
@@ -0,0 +1,65 @@
+// Package protocodec provides a swiftreq.Codec sending and receiving protobuf messages.
+// Register it with RequestExecutor.RegisterCodec, then select it with Request.WithCodec or by the application/x-protobuf content type.
+package protocodec
+
+import (
+	"fmt"
+	"reflect"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// ContentType is the media type of protobuf bodies.
+const ContentType = "application/x-protobuf"
+
+// Codec encodes and decodes protobuf messages in their binary wire format.
+// Payloads and result types have to be generated message types, such as pb.User or *pb.User.
+type Codec struct {
+	// MarshalOptions and UnmarshalOptions tune the encoding, such as with Deterministic or DiscardUnknown.
+	MarshalOptions   proto.MarshalOptions
+	UnmarshalOptions proto.UnmarshalOptions
+}
+
+// Marshal returns the wire encoding of the message v.
+func (c Codec) Marshal(v any) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		if rv := reflect.ValueOf(v); rv.Kind() == reflect.Struct {
+			ptr := reflect.New(rv.Type())
+			ptr.Elem().Set(rv)
+			m, ok = ptr.Interface().(proto.Message)
+		}
+	}
+
+	if !ok {
+		return nil, fmt.Errorf("protocodec: %T is not a proto.Message", v)
+	}
+
+	return c.MarshalOptions.Marshal(m)
+}
+
+// Unmarshal parses the wire-encoded data into the message pointed to by v.
+// A pointer to a nil message pointer receives a new message.
+func (c Codec) Unmarshal(data []byte, v any) error {
+	if m, ok := v.(proto.Message); ok {
+		return c.UnmarshalOptions.Unmarshal(data, m)
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Pointer && rv.Elem().Kind() == reflect.Pointer {
+		if rv.Elem().IsNil() {
+			rv.Elem().Set(reflect.New(rv.Elem().Type().Elem()))
+		}
+
+		if m, ok := rv.Elem().Interface().(proto.Message); ok {
+			return c.UnmarshalOptions.Unmarshal(data, m)
+		}
+	}
+
+	return fmt.Errorf("protocodec: %T is not a pointer to a proto.Message", v)
+}
+
+// ContentTypes returns the protobuf media types.
+func (Codec) ContentTypes() []string {
+	return []string{ContentType, "application/protobuf"}
+}
@@ -0,0 +1,72 @@
+package protocodec_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/liviudnicoara/swiftreq"
+	"github.com/liviudnicoara/swiftreq/protocodec"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+var _ swiftreq.Codec = protocodec.Codec{}
+
+func Test_Codec(t *testing.T) {
+	// arrange
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		var in wrapperspb.StringValue
+		if r.Header.Get("Content-Type") != protocodec.ContentType || proto.Unmarshal(body, &in) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		out, _ := proto.Marshal(wrapperspb.String("hello " + in.GetValue()))
+		w.Header().Set("Content-Type", protocodec.ContentType)
+		_, _ = w.Write(out)
+	}))
+	defer server.Close()
+
+	re := swiftreq.NewRequestExecutor(http.Client{}).RegisterCodec("protobuf", protocodec.Codec{})
+
+	t.Run("MessagePointer", func(t *testing.T) {
+		// act
+		resp, err := swiftreq.Post[*wrapperspb.StringValue](server.URL, wrapperspb.String("proto")).
+			WithCodec("protobuf").
+			WithRequestExecutor(re).
+			Do(context.Background())
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, "hello proto", (*resp).GetValue())
+	})
+
+	t.Run("MessageValue", func(t *testing.T) {
+		// act
+		resp, err := swiftreq.Post[wrapperspb.StringValue](server.URL, wrapperspb.String("value")).
+			WithHeaders(map[string]string{"Content-Type": protocodec.ContentType}).
+			WithRequestExecutor(re).
+			Do(context.Background())
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, "hello value", resp.GetValue())
+	})
+
+	t.Run("NotAMessage", func(t *testing.T) {
+		// act
+		_, err := swiftreq.Post[*wrapperspb.StringValue](server.URL, map[string]string{"value": "json"}).
+			WithCodec("protobuf").
+			WithRequestExecutor(re).
+			Do(context.Background())
+
+		// assert
+		assert.ErrorContains(t, err, "is not a proto.Message")
+	})
+}
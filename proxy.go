@@ -0,0 +1,79 @@
+package swiftreq
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// ProxyRules selects the proxy of each request by destination host and scheme, following the HTTP_PROXY, HTTPS_PROXY and NO_PROXY conventions.
+type ProxyRules struct {
+	// HTTP and HTTPS are the URLs of the proxies of http and https requests. Requests are sent directly when the proxy of their scheme is empty.
+	HTTP  string
+	HTTPS string
+	// NoProxy lists the destinations reached directly: host names, which also match their subdomains, ".example.com" suffixes,
+	// IP addresses and CIDR ranges, each with an optional port. "*" reaches every destination directly.
+	// As with NO_PROXY, requests to localhost and loopback addresses are always sent directly.
+	NoProxy []string
+	// Hosts route the requests of matching destinations, checked in order before the other rules.
+	Hosts []HostProxy
+}
+
+// HostProxy routes the requests to the hosts matching Pattern, in the path.Match syntax such as "*.corp.example.com", through Proxy.
+// An empty Proxy sends the requests directly.
+type HostProxy struct {
+	Pattern string
+	Proxy   string
+}
+
+// WithProxyRules routes the requests of the RequestExecutor through the proxies selected by the rules, replacing the environment based proxy.
+// An invalid proxy URL makes the requests routed through it fail.
+func (re *RequestExecutor) WithProxyRules(rules ProxyRules) *RequestExecutor {
+	proxy := rules.proxyFunc()
+
+	re.updateTransport(func(t *http.Transport) {
+		t.Proxy = func(req *http.Request) (*url.URL, error) {
+			return proxy(req.URL)
+		}
+	})
+
+	return re
+}
+
+// proxyFunc returns the function selecting the proxy URL of a destination, nil when it is reached directly.
+func (rules ProxyRules) proxyFunc() func(u *url.URL) (*url.URL, error) {
+	schemeProxy := (&httpproxy.Config{
+		HTTPProxy:  rules.HTTP,
+		HTTPSProxy: rules.HTTPS,
+		NoProxy:    strings.Join(rules.NoProxy, ","),
+	}).ProxyFunc()
+
+	hosts := append([]HostProxy{}, rules.Hosts...)
+
+	return func(u *url.URL) (*url.URL, error) {
+		host := strings.ToLower(u.Hostname())
+
+		for _, h := range hosts {
+			if ok, _ := path.Match(strings.ToLower(h.Pattern), host); !ok {
+				continue
+			}
+
+			if h.Proxy == "" {
+				return nil, nil
+			}
+
+			proxy, err := url.Parse(h.Proxy)
+			if err != nil || proxy.Host == "" {
+				return nil, fmt.Errorf("invalid proxy address %q for %s", h.Proxy, h.Pattern)
+			}
+
+			return proxy, nil
+		}
+
+		return schemeProxy(u)
+	}
+}
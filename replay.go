@@ -0,0 +1,190 @@
+package swiftreq
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/liviudnicoara/swiftreq/middlewares"
+)
+
+// ReplayOptions configures the replay of an archive.
+type ReplayOptions struct {
+	// Hosts maps recorded hosts to the hosts receiving the replayed requests, such as "api.example.com" to "staging.example.com".
+	// A target may include a scheme, such as "http://localhost:8080", to change the scheme too. Unmapped hosts are replayed as recorded.
+	Hosts map[string]string
+	// Speed scales the recorded pacing: 1 keeps the original spacing of the requests and 2 replays them twice as fast.
+	// With 0 the requests are sent as fast as Concurrency allows.
+	Speed float64
+	// Concurrency bounds the number of requests in flight. It defaults to 10.
+	Concurrency int
+	// Filter selects the entries to replay. All the entries are replayed when it is nil.
+	Filter func(e *HAREntry) bool
+}
+
+// ReplayResult is the outcome of a replayed entry. StatusCode is 0 when no response was received.
+type ReplayResult struct {
+	Entry      *HAREntry
+	StatusCode int
+	Duration   time.Duration
+	Err        error
+}
+
+// replaySkippedHeaders are recorded headers which are set by the transport, or which only apply to the recorded connection.
+var replaySkippedHeaders = map[string]bool{
+	"Host":              true,
+	"Content-Length":    true,
+	"Connection":        true,
+	"Keep-Alive":        true,
+	"Proxy-Connection":  true,
+	"Transfer-Encoding": true,
+	"Te":                true,
+	"Upgrade":           true,
+}
+
+// ReplayHAR sends the requests recorded in the archive through the executor, middlewares included, in the order of the archive.
+// Unsuccessful status codes are reported in the results and are not errors.
+// When ctx is done, the entries which were not sent yet are reported with its error.
+func (re *RequestExecutor) ReplayHAR(ctx context.Context, har *HAR, opts ReplayOptions) []ReplayResult {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 10
+	}
+
+	var entries []*HAREntry
+	for i := range har.Log.Entries {
+		if e := &har.Log.Entries[i]; opts.Filter == nil || opts.Filter(e) {
+			entries = append(entries, e)
+		}
+	}
+
+	results := make([]ReplayResult, len(entries))
+	sem := make(chan struct{}, opts.Concurrency)
+	start := time.Now()
+
+	var wg sync.WaitGroup
+	for i, e := range entries {
+		results[i].Entry = e
+
+		if err := replayWait(ctx, start, entries[0], e, opts.Speed); err != nil {
+			results[i].Err = err
+			continue
+		}
+
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			results[i].Err = ctx.Err()
+			continue
+		}
+
+		wg.Add(1)
+		go func(r *ReplayResult) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			re.replayEntry(ctx, r, opts.Hosts)
+		}(&results[i])
+	}
+
+	wg.Wait()
+
+	return results
+}
+
+// replayWait waits until the entry is due, according to its recorded offset from the first entry scaled by speed.
+func replayWait(ctx context.Context, start time.Time, first, e *HAREntry, speed float64) error {
+	if speed <= 0 || first.StartedDateTime.IsZero() || e.StartedDateTime.IsZero() {
+		return ctx.Err()
+	}
+
+	offset := time.Duration(float64(e.StartedDateTime.Sub(first.StartedDateTime)) / speed)
+	wait := time.Until(start.Add(offset))
+	if wait <= 0 {
+		return ctx.Err()
+	}
+
+	t := time.NewTimer(wait)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// replayEntry sends the request of the entry and records its outcome in r.
+func (re *RequestExecutor) replayEntry(ctx context.Context, r *ReplayResult, hosts map[string]string) {
+	req, err := newReplayRequest(ctx, &r.Entry.Request, hosts)
+	if err != nil {
+		r.Err = &Error{Message: "could not create request " + r.Entry.Request.URL, Cause: err}
+		return
+	}
+
+	begin := time.Now()
+	res, err := re.handler()(req)
+	if err == nil && res != nil {
+		r.StatusCode = res.StatusCode
+	}
+	middlewares.DrainBody(res)
+	r.Duration = time.Since(begin)
+
+	if err != nil {
+		r.Err = &Error{Message: "failed to replay request " + req.URL.String(), Cause: classifyTransportError(req.Context(), err), Method: req.Method, URL: req.URL.String()}
+	}
+}
+
+// newReplayRequest rebuilds the recorded request, remapping its host.
+func newReplayRequest(ctx context.Context, hr *HARRequest, hosts map[string]string) (*http.Request, error) {
+	u, err := url.Parse(hr.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	target, ok := hosts[u.Host]
+	if !ok {
+		target, ok = hosts[u.Hostname()]
+	}
+
+	if ok {
+		if scheme, host, found := strings.Cut(target, "://"); found {
+			u.Scheme, u.Host = scheme, host
+		} else {
+			u.Host = target
+		}
+	}
+
+	var body *strings.Reader
+	if hr.PostData != nil {
+		body = strings.NewReader(hr.PostData.Text)
+	}
+
+	var req *http.Request
+	if body != nil {
+		req, err = http.NewRequestWithContext(ctx, hr.Method, u.String(), body)
+	} else {
+		req, err = http.NewRequestWithContext(ctx, hr.Method, u.String(), nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, h := range hr.Headers {
+		name := http.CanonicalHeaderKey(h.Name)
+		if strings.HasPrefix(name, ":") || replaySkippedHeaders[name] {
+			continue
+		}
+
+		req.Header.Add(name, h.Value)
+	}
+
+	if hr.PostData != nil && hr.PostData.MimeType != "" && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", hr.PostData.MimeType)
+	}
+
+	return req, nil
+}
@@ -3,25 +3,46 @@ package swiftreq
 import (
 	"bytes"
 	"context"
-	"encoding/json"
+	"encoding/base64"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
-	"strconv"
 	"strings"
+
+	"github.com/liviudnicoara/swiftreq/middlewares"
 )
 
 // Request represents an HTTP request with fluent methods for customization.
 type Request[T any] struct {
 	re              *RequestExecutor
-	headers         map[string]string
+	headers         http.Header
+	cookies         []*http.Cookie
 	httpMethod      string
 	url             string
 	payload         interface{}
+	multipart       bool
+	rawPayload      func() io.Reader
+	rawContentType  string
 	queryParameters url.Values
+	accept          string
+	codec           decoder
+	codecName       string
+	jsonCodec       jsonCodec
+	validators      []func(T) error
+	hooks           []ResponseHook[T]
+	priority        *middlewares.Priority
+	cacheBypass     bool
+	logger          middlewares.Logger
+	logArgs         []any
+	maybe           bool
+	absentStatus    []int
+	progress        ProgressFunc
 }
 
+// ResponseHook runs on a successfully decoded response. It can modify the response, and returning an error fails the call.
+type ResponseHook[T any] func(ctx context.Context, resp *T, meta *ResponseMeta) error
+
 // Get creates a new HTTP GET request.
 func Get[T any](url string) *Request[T] {
 	return newDefaultRequest[T]().
@@ -61,8 +82,8 @@ func newDefaultRequest[T any]() *Request[T] {
 func newRequest[T any](re *RequestExecutor) *Request[T] {
 	return &Request[T]{
 		re: re,
-		headers: map[string]string{
-			"Content-Type": "application/json",
+		headers: http.Header{
+			"Content-Type": {"application/json"},
 		},
 	}
 }
@@ -82,6 +103,44 @@ func (r *Request[T]) WithURL(url string) *Request[T] {
 // WithPayload sets the payload for the request.
 func (r *Request[T]) WithPayload(payload interface{}) *Request[T] {
 	r.payload = payload
+	r.rawPayload = nil
+	return r
+}
+
+// WithRawPayload sends the content of the reader as the body of the request, with the content type if not empty.
+// The reader is sent as is, without encoding; it is consumed by the first execution of the request.
+func (r *Request[T]) WithRawPayload(body io.Reader, contentType string) *Request[T] {
+	r.payload = nil
+	r.multipart = false
+	r.rawPayload = func() io.Reader { return body }
+	r.rawContentType = contentType
+	return r
+}
+
+// WithBytesPayload sends the bytes as the body of the request, with the content type if not empty.
+// The bytes are sent as is, without encoding, and can be sent again on retries and new executions of the request.
+func (r *Request[T]) WithBytesPayload(body []byte, contentType string) *Request[T] {
+	r.payload = nil
+	r.multipart = false
+	r.rawPayload = func() io.Reader { return bytes.NewReader(body) }
+	r.rawContentType = contentType
+	return r
+}
+
+// WithMultipartPayload sets a struct as payload, encoded as multipart/form-data according to the form and file tags of its fields:
+//
+//	type Upload struct {
+//		Title    string    `form:"title"`
+//		Tags     []string  `form:"tag"`
+//		Document io.Reader `file:"document,filename=report.pdf"`
+//		Avatar   string    `file:"avatar"` // path of the file to upload
+//	}
+//
+// The body is built in memory when the request is executed.
+func (r *Request[T]) WithMultipartPayload(payload interface{}) *Request[T] {
+	r.payload = payload
+	r.multipart = true
+	r.rawPayload = nil
 	return r
 }
 
@@ -91,9 +150,165 @@ func (r *Request[T]) WithRequestExecutor(re *RequestExecutor) *Request[T] {
 	return r
 }
 
+// WithPriority sets the priority of the request, used to shed the low priority requests first under load, see RequestExecutor.WithLoadShedding.
+func (r *Request[T]) WithPriority(p middlewares.Priority) *Request[T] {
+	r.priority = &p
+	return r
+}
+
+// WithCacheBypass sends the request even if its response is cached, and caches the fresh response in place of the previous one.
+func (r *Request[T]) WithCacheBypass() *Request[T] {
+	r.cacheBypass = true
+	return r
+}
+
+// WithLogger sets the logger used by the middlewares for this request, instead of the logger of the RequestExecutor.
+func (r *Request[T]) WithLogger(logger middlewares.Logger) *Request[T] {
+	r.logger = logger
+	return r
+}
+
+// WithLogAttrs adds attributes to every log line of the middlewares for this request, such as "UserID", id.
+// Attributes can also be carried by the context, see middlewares.ContextWithLogAttrs.
+func (r *Request[T]) WithLogAttrs(args ...any) *Request[T] {
+	r.logArgs = append(r.logArgs, args...)
+	return r
+}
+
+// WithSession sends the request through the Session, with its cookies, access token and default headers.
+// The URL of the request can be relative to the base URL of the session.
+func (r *Request[T]) WithSession(s *Session) *Request[T] {
+	r.re = s.re
+	return r
+}
+
+// WithCookies adds the cookies to the request, in addition to the cookies of the jar of the RequestExecutor for its URL.
+// Only their name and value are sent.
+func (r *Request[T]) WithCookies(cookies ...*http.Cookie) *Request[T] {
+	r.cookies = append(r.cookies, cookies...)
+	return r
+}
+
 // WithHeaders sets the headers for the request.
+// Headers are merged into the ones already set: a key present in headers replaces its previous values, other keys are kept.
 func (r *Request[T]) WithHeaders(headers map[string]string) *Request[T] {
-	r.headers = headers
+	for k, v := range headers {
+		r.headers.Set(k, v)
+	}
+
+	return r
+}
+
+// WithHeader adds a header value to the request, keeping the values already set for the same key.
+func (r *Request[T]) WithHeader(key, value string) *Request[T] {
+	r.headers.Add(key, value)
+	return r
+}
+
+// WithBasicAuth sets the Authorization header of the request to the basic authentication of the user and password.
+// The authorization middleware of an executor adds its own Authorization header, so use one or the other.
+func (r *Request[T]) WithBasicAuth(username, password string) *Request[T] {
+	r.headers.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(username+":"+password)))
+	return r
+}
+
+// WithBearerToken sets the Authorization header of the request to the bearer token.
+// Tokens which expire are better refreshed by the authorization of the executor, see RequestExecutor.WithAuthorization.
+func (r *Request[T]) WithBearerToken(token string) *Request[T] {
+	r.headers.Set("Authorization", "Bearer "+token)
+	return r
+}
+
+// WithAccept sets the Accept header for the request and decodes the response with the decoder matching the media type,
+// regardless of the Content-Type returned by the server.
+func (r *Request[T]) WithAccept(mediaType string) *Request[T] {
+	r.accept = mediaType
+	r.codec = codecFor(mediaType)
+	return r
+}
+
+// WithCodec encodes the payload and decodes the response with the named codec, and sets the Accept header to its media type.
+// The codecs registered on the executor are looked up first, then the built-in "json" and "xml" ones, see RequestExecutor.RegisterCodec.
+// Without it, payloads are encoded with the codec matching the Content-Type header of the request, JSON by default.
+func (r *Request[T]) WithCodec(name string) *Request[T] {
+	r.codecName = name
+	return r
+}
+
+// selectedCodec returns the codec selected with WithCodec, or nil when none is.
+func (r *Request[T]) selectedCodec() (Codec, error) {
+	if r.codecName == "" {
+		return nil, nil
+	}
+
+	if c, ok := r.re.codecNamed(r.codecName); ok {
+		return c, nil
+	}
+
+	switch strings.ToLower(r.codecName) {
+	case "json":
+		return r.jsonCodec, nil
+	case "xml":
+		return xmlCodec{}, nil
+	default:
+		return nil, fmt.Errorf("unknown codec %q", r.codecName)
+	}
+}
+
+// AcceptJSON requests a JSON response and decodes it as JSON.
+func (r *Request[T]) AcceptJSON() *Request[T] {
+	return r.WithAccept("application/json")
+}
+
+// AcceptXML requests an XML response and decodes it as XML.
+func (r *Request[T]) AcceptXML() *Request[T] {
+	return r.WithAccept("application/xml")
+}
+
+// WithNamingStrategy converts the keys of JSON payloads and responses with the naming strategy, such as SnakeCase,
+// so that struct fields without json tags match the keys used on the wire.
+func (r *Request[T]) WithNamingStrategy(naming NamingStrategy) *Request[T] {
+	r.jsonCodec.naming = naming
+	return r
+}
+
+// WithUseNumber decodes the numbers of JSON responses held by interface values, such as map[string]any results, as json.Number
+// instead of float64, so that large integers are kept without loss.
+func (r *Request[T]) WithUseNumber() *Request[T] {
+	r.jsonCodec.useNumber = true
+	return r
+}
+
+// WithTimeLayouts accepts the time layouts, such as "2006-01-02 15:04:05" or EpochMillis, for the time.Time fields of the JSON response
+// in addition to RFC 3339. Layouts are tried in order.
+func (r *Request[T]) WithTimeLayouts(layouts ...string) *Request[T] {
+	r.jsonCodec.timeLayouts = append(append([]string{}, r.jsonCodec.timeLayouts...), layouts...)
+	return r
+}
+
+// AcceptHTML requests an HTML response and extracts the fields of the result struct with the CSS selectors of their html tags.
+func (r *Request[T]) AcceptHTML() *Request[T] {
+	return r.WithAccept("text/html")
+}
+
+// WithValidator adds a validation function which runs on the decoded response.
+// When it returns an error, Do fails with a ValidationError.
+func (r *Request[T]) WithValidator(validate func(T) error) *Request[T] {
+	r.validators = append(r.validators, validate)
+	return r
+}
+
+// WithStructValidator validates the decoded response with a struct tag based validator, such as *validator.Validate from go-playground/validator.
+func (r *Request[T]) WithStructValidator(v StructValidator) *Request[T] {
+	return r.WithValidator(func(t T) error {
+		return v.Struct(t)
+	})
+}
+
+// WithResponseHook adds a hook which runs after the response is decoded and validated, in the order hooks were added.
+// When it returns an error, Do fails with a ValidationError wrapping it.
+func (r *Request[T]) WithResponseHook(hook ResponseHook[T]) *Request[T] {
+	r.hooks = append(r.hooks, hook)
 	return r
 }
 
@@ -113,26 +328,186 @@ func (r *Request[T]) WithQueryParameters(params map[string]string) *Request[T] {
 	return r
 }
 
+// WithQueryParameter adds a query parameter value to the request, keeping the values already set for the same key.
+func (r *Request[T]) WithQueryParameter(key, value string) *Request[T] {
+	if r.queryParameters == nil {
+		r.queryParameters = url.Values{}
+	}
+
+	r.queryParameters.Add(key, value)
+	return r
+}
+
 // Do executes the HTTP request and returns the response.
 func (r *Request[T]) Do(ctx context.Context) (*T, error) {
-	ok, u, err := isValidURL(r.url)
+	resp, _, err := r.DoWithResponse(ctx)
+	return resp, err
+}
+
+// DoWithResponse executes the HTTP request and returns the response along with its metadata.
+// The metadata is returned whenever a response was received, including for unsuccessful status codes.
+func (r *Request[T]) DoWithResponse(ctx context.Context) (*T, *ResponseMeta, error) {
+	req, res, meta, err := r.send(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if stream, ok := r.stream(res); ok {
+		return stream, meta, nil
+	}
+
+	defer middlewares.DrainBody(res)
+
+	responseData, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, meta, r.fail(&Error{
+			Message: "failed to read response body for url request " + r.url,
+			Cause:   classifyTransportError(req.Context(), err),
+		}, req, res, responseData)
+	}
+
+	meta.Trailer = res.Trailer
+
+	if r.absent(res.StatusCode, responseData) {
+		return nil, meta, nil
+	}
+
+	if res.StatusCode >= http.StatusBadRequest {
+		return nil, meta, r.fail(&Error{
+			Message:    fmt.Sprintf("error calling %s", req.URL),
+			Cause:      classifyStatusError(res.StatusCode, responseData),
+			StatusCode: res.StatusCode,
+		}, req, res, responseData)
+	}
+
+	contentType := res.Header.Get("Content-Type")
+	responseObject, err := r.decode(contentType, responseData)
+	if err != nil {
+		return nil, meta, r.fail(&Error{
+			Message:    "error decoding response for request " + r.url,
+			Cause:      &DecodeError{ContentType: contentType, Err: err},
+			StatusCode: res.StatusCode,
+		}, req, res, responseData)
+	}
+
+	for _, validate := range r.validators {
+		if err := validate(responseObject); err != nil {
+			return nil, meta, r.fail(&Error{
+				Message:    "invalid response for request " + r.url,
+				Cause:      &ValidationError{Err: err},
+				StatusCode: res.StatusCode,
+			}, req, res, responseData)
+		}
+	}
+
+	for _, hook := range r.hooks {
+		if err := hook(ctx, &responseObject, meta); err != nil {
+			return nil, meta, r.fail(&Error{
+				Message:    "response hook failed for request " + r.url,
+				Cause:      &ValidationError{Err: err},
+				StatusCode: res.StatusCode,
+			}, req, res, responseData)
+		}
+	}
+
+	return &responseObject, meta, nil
+}
+
+// send builds the request and sends it through the middlewares of the executor.
+// The caller is responsible for closing the body of the returned response.
+func (r *Request[T]) send(ctx context.Context) (*http.Request, *http.Response, *ResponseMeta, error) {
+	stats := &middlewares.RetryStats{}
+	ctx = middlewares.ContextWithRetryStats(ctx, stats)
+
+	req, err := r.buildRequest(ctx)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	res, err := r.re.handler()(req)
+	if err != nil {
+		middlewares.DrainBody(res)
+		return req, nil, nil, r.fail(&Error{
+			Message: "failed to make request " + r.url,
+			Cause:   classifyTransportError(req.Context(), err),
+		}, req, nil, nil)
+	}
+
+	if res == nil {
+		return req, nil, nil, r.fail(&Error{
+			Message: fmt.Sprintf("calling %s returned empty response", req.URL),
+		}, req, nil, nil)
+	}
+
+	if r.progress != nil {
+		withDownloadProgress(res, r.progress)
+	}
+
+	return req, res, newResponseMeta(res, stats), nil
+}
+
+// fail completes the error of a failed call with the details of the exchange, and reports it to the error reporters of the executor.
+// res and body may be nil when no response was received or read.
+func (r *Request[T]) fail(e *Error, req *http.Request, res *http.Response, body []byte) *Error {
+	e.Method = req.Method
+	e.URL = req.URL.String()
+
+	if res != nil {
+		e.header = res.Header
+		e.body = body
+	}
+
+	if stats := middlewares.RetryStatsFromContext(req.Context()); stats != nil {
+		e.Attempts = max(1, stats.Attempts)
+		e.Backoff = stats.Backoff
+	}
+
+	r.re.withDump(e, req, res, body)
+	r.re.reportError(req.Context(), e)
+
+	return e
+}
+
+// buildRequest creates the HTTP request with its URL, query parameters, body and headers.
+func (r *Request[T]) buildRequest(ctx context.Context) (*http.Request, error) {
+	ok, u, err := isValidURL(r.re.resolveURL(r.url))
 	if !ok {
 		return nil, err
 	}
 
+	c, err := r.selectedCodec()
+	if err != nil {
+		return nil, &Error{
+			Message: "could not select codec for request " + r.url,
+			Cause:   err,
+		}
+	}
+
 	if r.httpMethod == "GET" {
 		q := u.Query()
 
-		for k, v := range r.queryParameters {
-			q.Set(k, strings.Join(v, ","))
+		for k, vs := range r.queryParameters {
+			q.Del(k)
+			for _, v := range vs {
+				q.Add(k, v)
+			}
 		}
 
 		u.RawQuery = q.Encode()
 	}
 
 	var body []byte
-	if r.payload != nil {
-		body, err = json.Marshal(r.payload)
+	var contentType string
+	if r.payload != nil && r.multipart {
+		body, contentType, err = encodeMultipart(r.payload)
+		if err != nil {
+			return nil, &Error{
+				Message: fmt.Sprintf("could not encode multipart body for request %s", r.url),
+				Cause:   err,
+			}
+		}
+	} else if r.payload != nil {
+		body, contentType, err = r.marshalPayload(c)
 		if err != nil {
 			return nil, &Error{
 				Message: fmt.Sprintf("could not marshal body for request %s. Body:\n %+v", r.url, r.payload),
@@ -141,13 +516,26 @@ func (r *Request[T]) Do(ctx context.Context) (*T, error) {
 		}
 	}
 
-	buff := bytes.NewBuffer(body)
+	var buff io.Reader = bytes.NewBuffer(body)
+	if r.rawPayload != nil {
+		buff = r.rawPayload()
+		contentType = r.rawContentType
+	}
 
-	if err != nil {
-		return nil, &Error{
-			Message: fmt.Sprintf("could not create body buffer for request %s. Body:\n %+v", r.url, r.payload),
-			Cause:   err,
-		}
+	if r.priority != nil {
+		ctx = middlewares.ContextWithPriority(ctx, *r.priority)
+	}
+
+	if r.cacheBypass {
+		ctx = middlewares.ContextWithCacheBypass(ctx)
+	}
+
+	if r.logger != nil {
+		ctx = middlewares.ContextWithLogger(ctx, r.logger)
+	}
+
+	if len(r.logArgs) > 0 {
+		ctx = middlewares.ContextWithLogAttrs(ctx, r.logArgs...)
 	}
 
 	req, err := http.NewRequestWithContext(ctx, r.httpMethod, u.String(), buff)
@@ -158,87 +546,65 @@ func (r *Request[T]) Do(ctx context.Context) (*T, error) {
 		}
 	}
 
-	for k, v := range r.headers {
-		req.Header.Set(k, v)
+	for k, vs := range r.headers {
+		for _, v := range vs {
+			req.Header.Add(k, v)
+		}
 	}
 
-	res, err := r.re.pipeline(req)
-	if err != nil {
-		return nil, &Error{
-			Message: "failed to make request " + r.url,
-			Cause:   err,
-		}
+	for _, c := range r.cookies {
+		req.AddCookie(c)
 	}
 
-	if res == nil {
-		return nil, &Error{
-			Message: fmt.Sprintf("calling %s returned empty response", u.String()),
-		}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
 	}
 
-	responseData, err := io.ReadAll(res.Body)
-	if err != nil {
-		return nil, &Error{
-			Message: "failed to read response body for url request " + r.url,
-			Cause:   err,
-		}
+	if c != nil && len(c.ContentTypes()) > 0 {
+		req.Header.Set("Accept", c.ContentTypes()[0])
+	} else if r.accept != "" {
+		req.Header.Set("Accept", r.accept)
 	}
 
-	defer res.Body.Close()
+	if r.progress != nil {
+		withUploadProgress(req, r.progress)
+	}
 
-	if res.StatusCode >= http.StatusBadRequest {
-		return nil, &Error{
-			Message:    fmt.Sprintf("error calling %s", u.String()),
-			Cause:      fmt.Errorf("%s", responseData),
-			StatusCode: res.StatusCode,
-		}
+	return req, nil
+}
+
+// marshalPayload encodes the payload with the selected codec, otherwise with the codec matching the Content-Type header of the request:
+// a registered one, XML for XML media types, and JSON by default.
+// It returns the content type to send, which is empty when the Content-Type header of the request is kept.
+func (r *Request[T]) marshalPayload(c Codec) ([]byte, string, error) {
+	if ct, ok := r.payload.(contentTyper); ok {
+		body, err := r.jsonCodec.Marshal(r.payload)
+		return body, ct.ContentType(), err
 	}
 
-	var responseObject T
-	contentType := res.Header.Get("Content-Type")
-	if strings.Contains(contentType, "application/json") || contentType == "" {
-		err = json.Unmarshal(responseData, &responseObject)
+	header := r.headers.Get("Content-Type")
 
-		if err != nil {
-			return nil, &Error{
-				Message:    "error unmarshaling response for request " + r.url,
-				Cause:      err,
-				StatusCode: res.StatusCode,
-			}
-		}
-	} else {
-		dataAsString := string(responseData)
-		var parseErr error
-		switch any(responseObject).(type) {
-		case string:
-			responseObject = any(dataAsString).(T)
-
-		case int:
-			data, err := strconv.Atoi(dataAsString)
-			responseObject = any(data).(T)
-			parseErr = err
-		case float64:
-			data, err := strconv.ParseFloat(dataAsString, 64)
-			responseObject = any(data).(T)
-			parseErr = err
-		case float32:
-			data, err := strconv.ParseFloat(dataAsString, 32)
-			responseObject = any(data).(T)
-			parseErr = err
-		default:
-			parseErr = fmt.Errorf("unsupported conversion type: %T", responseObject)
+	if c != nil {
+		body, err := c.Marshal(r.payload)
+		if err != nil || handles(c, header) || len(c.ContentTypes()) == 0 {
+			return body, "", err
 		}
 
-		if parseErr != nil {
-			return nil, &Error{
-				Message:    "error converting response for request " + r.url,
-				Cause:      parseErr,
-				StatusCode: res.StatusCode,
-			}
-		}
+		return body, c.ContentTypes()[0], nil
+	}
+
+	if registered, ok := r.re.codecFor(header); ok {
+		body, err := registered.Marshal(r.payload)
+		return body, "", err
+	}
+
+	if isXML(header) {
+		body, err := xmlCodec{}.Marshal(r.payload)
+		return body, "", err
 	}
 
-	return &responseObject, nil
+	body, err := r.jsonCodec.Marshal(r.payload)
+	return body, "", err
 }
 
 // isValidURL checks if the given URL is valid and parses it.
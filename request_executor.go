@@ -1,13 +1,23 @@
 package swiftreq
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
+	"net/url"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/liviudnicoara/swiftreq/auth"
 	"github.com/liviudnicoara/swiftreq/middlewares"
 	"github.com/patrickmn/go-cache"
+	"golang.org/x/oauth2"
 )
 
 // defaultMinWaitRetry and defaultMaxWaitRetry define default values for minimum and maximum wait time between retries.
@@ -32,153 +42,924 @@ func SetDefault(re *RequestExecutor) {
 }
 
 // RequestExecutor is a struct representing an HTTP client with middleware support.
+// It is safe to configure a RequestExecutor while requests are in flight: the configuration methods are serialized,
+// and requests use the client and pipeline as they were when the request started.
 type RequestExecutor struct {
-	client       http.Client
-	middlewares  []middlewares.Middleware
-	pipeline     middlewares.Handler
-	cacheEnabled bool
-	retryEnabled bool
-	authEnabled  bool
+	mu            sync.Mutex
+	client        atomic.Value
+	middlewares   []middlewares.Middleware
+	pipeline      atomic.Value
+	cacheEnabled  bool
+	cache         *cache.Cache
+	tenantTokens  *middlewares.TenantTokenRefresher
+	retryEnabled  bool
+	authEnabled   bool
+	traceEnabled  bool
+	outbox        *middlewares.Outbox
+	retryIndex    int
+	retryHandler  middlewares.RetryHandler
+	attemptHeader string
+	retryTimeouts RetryTimeouts
+	baseURL       string
+
+	cacheIdentity middlewares.IdentityFunc
+	toggles       map[string]*middlewares.Toggle
+	debugDumps    atomic.Bool
+	reporters     atomic.Value
+	codecs        atomic.Value
 
 	MinWaitRetry time.Duration
 	MaxWaitRetry time.Duration
 
-	Logger *slog.Logger
+	Logger middlewares.Logger
 }
 
 // newDefaultRequestExecutor creates a new default RequestExecutor with default settings.
+// The settings can be tuned through environment variables, unless SWIFTREQ_IGNORE_ENV is set.
 func newDefaultRequestExecutor() *RequestExecutor {
 	client := http.Client{Timeout: 30 * time.Second}
-	return NewRequestExecutor(client)
+	re := NewRequestExecutor(client)
+
+	if ignoreEnvironment() {
+		return re.withoutEnvironment()
+	}
+
+	return re.WithEnvironment()
 }
 
 // NewRequestExecutor creates a new RequestExecutor with the provided http.Client.
+// The RequestExecutor starts without middlewares: nothing is logged, retried or cached unless configured.
 func NewRequestExecutor(client http.Client) *RequestExecutor {
 	re := &RequestExecutor{
-		client: client,
-
 		MinWaitRetry: defaultMinWaitRetry,
 		MaxWaitRetry: defaultMaxWaitRetry,
 		Logger:       slog.Default(),
 	}
 
-	re.pipeline = re.do()
+	re.client.Store(&client)
+	re.buildPipeline()
+
+	return re
+}
+
+// WithBaseURL sets the base URL of the requests built with a relative URL, such as Get[User]("/users/1").
+// The relative URL is appended to the base URL, so that its path is kept: with "https://api.example.com/v1",
+// "/users/1" is sent to "https://api.example.com/v1/users/1". Absolute URLs are sent unchanged.
+func (re *RequestExecutor) WithBaseURL(baseURL string) *RequestExecutor {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+
+	re.baseURL = strings.TrimRight(baseURL, "/")
+
+	return re
+}
+
+// resolveURL resolves a relative URL against the base URL, when one is set.
+func (re *RequestExecutor) resolveURL(u string) string {
+	re.mu.Lock()
+	baseURL := re.baseURL
+	re.mu.Unlock()
+
+	if baseURL == "" {
+		return u
+	}
+
+	if parsed, err := url.Parse(u); err != nil || parsed.IsAbs() || parsed.Host != "" {
+		return u
+	}
+
+	if u == "" {
+		return baseURL
+	}
+
+	return baseURL + "/" + strings.TrimLeft(u, "/")
+}
+
+// WithCookieJar stores the cookies set by the responses in the jar, and sends them with the following requests to the same sites.
+// A nil jar uses a new in-memory jar, see net/http/cookiejar.
+func (re *RequestExecutor) WithCookieJar(jar http.CookieJar) *RequestExecutor {
+	if jar == nil {
+		jar = newCookieJar()
+	}
+
+	re.updateClient(func(c *http.Client) {
+		c.Jar = jar
+	})
 
 	return re
 }
 
 // WithTimeout sets the timeout for the RequestExecutor.
 func (re *RequestExecutor) WithTimeout(timeout time.Duration) *RequestExecutor {
-	re.client.Timeout = timeout
+	re.updateClient(func(c *http.Client) {
+		c.Timeout = timeout
+	})
+
+	return re
+}
+
+// TransportTimeouts defines the timeouts of the phases of a request, unlike the client timeout which bounds the whole exchange.
+// Zero values keep the current setting of the transport.
+type TransportTimeouts struct {
+	// Dial bounds the time to establish the TCP connection.
+	Dial time.Duration
+	// TLSHandshake bounds the time of the TLS handshake.
+	TLSHandshake time.Duration
+	// ResponseHeader bounds the time to wait for the response headers once the request is written.
+	ResponseHeader time.Duration
+	// IdleConn is how long an idle keep-alive connection is kept in the pool.
+	IdleConn time.Duration
+}
+
+// WithTransportTimeouts sets the dial, TLS handshake, response header and idle connection timeouts of the transport.
+// They apply when the client uses an *http.Transport, or the default transport, which is then cloned.
+func (re *RequestExecutor) WithTransportTimeouts(timeouts TransportTimeouts) *RequestExecutor {
+	re.updateTransport(func(t *http.Transport) {
+		if timeouts.Dial > 0 {
+			t.DialContext = (&net.Dialer{Timeout: timeouts.Dial, KeepAlive: 30 * time.Second}).DialContext
+		}
+
+		if timeouts.TLSHandshake > 0 {
+			t.TLSHandshakeTimeout = timeouts.TLSHandshake
+		}
+
+		if timeouts.ResponseHeader > 0 {
+			t.ResponseHeaderTimeout = timeouts.ResponseHeader
+		}
+
+		if timeouts.IdleConn > 0 {
+			t.IdleConnTimeout = timeouts.IdleConn
+		}
+	})
+
+	return re
+}
+
+// WithMaxRedirects limits the number of redirects followed by the client. The request fails once the limit is exceeded.
+// With a limit of zero, redirects are not followed and the redirect response is returned.
+func (re *RequestExecutor) WithMaxRedirects(max int) *RequestExecutor {
+	re.updateClient(func(c *http.Client) {
+		c.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			if max <= 0 {
+				return http.ErrUseLastResponse
+			}
+
+			if len(via) > max {
+				return fmt.Errorf("stopped after %d redirects", max)
+			}
+
+			return nil
+		}
+	})
+
+	return re
+}
+
+// WithKeepAlives enables or disables the reuse of connections between requests.
+// Disabling keep-alives opens a new connection for every request, which some load balancers require.
+func (re *RequestExecutor) WithKeepAlives(enabled bool) *RequestExecutor {
+	re.updateTransport(func(t *http.Transport) {
+		t.DisableKeepAlives = !enabled
+	})
+
+	return re
+}
+
+// WithVerifyPeerCertificate sets a callback making custom trust decisions on the certificates presented by servers,
+// such as pinning or certificate transparency checks. Returning an error aborts the handshake.
+// It runs after the standard verification, with the verified chains. For a private PKI replacing the standard verification,
+// set InsecureSkipVerify in the TLS configuration of the transport: verifiedChains is then nil and the callback must verify rawCerts.
+func (re *RequestExecutor) WithVerifyPeerCertificate(verify func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error) *RequestExecutor {
+	re.updateTransport(func(t *http.Transport) {
+		if t.TLSClientConfig == nil {
+			t.TLSClientConfig = &tls.Config{}
+		} else {
+			t.TLSClientConfig = t.TLSClientConfig.Clone()
+		}
+
+		t.TLSClientConfig.VerifyPeerCertificate = verify
+	})
+
 	return re
 }
 
 // WithMiddleware adds a single middleware to the RequestExecutor.
 func (re *RequestExecutor) WithMiddleware(handler middlewares.Middleware) *RequestExecutor {
-	re.middlewares = append(re.middlewares, handler)
-	re.pipeline = re.do()
+	re.mu.Lock()
+	defer re.mu.Unlock()
 
-	for _, h := range re.middlewares {
-		re.pipeline = h(re.pipeline)
-	}
+	re.addMiddlewares(handler)
 
 	return re
 }
 
 // WithMiddlewares adds multiple middlewares to the RequestExecutor.
 func (re *RequestExecutor) WithMiddlewares(handlers ...middlewares.Middleware) *RequestExecutor {
-	re.middlewares = append(re.middlewares, handlers...)
-	re.pipeline = re.do()
+	re.mu.Lock()
+	defer re.mu.Unlock()
+
+	re.addMiddlewares(handlers...)
 
-	for _, h := range re.middlewares {
-		re.pipeline = h(re.pipeline)
-	}
 	return re
 }
 
 // AddLogging adds logging middleware to the RequestExecutor.
-func (re *RequestExecutor) AddLogging(logger *slog.Logger) *RequestExecutor {
+func (re *RequestExecutor) AddLogging(logger middlewares.Logger) *RequestExecutor {
+	return re.AddLoggingWithOptions(logger, middlewares.LogOptions{Level: slog.LevelInfo})
+}
+
+// AddLoggingWithOptions adds logging middleware to the RequestExecutor with the specified level and sampling options.
+func (re *RequestExecutor) AddLoggingWithOptions(logger middlewares.Logger, opts middlewares.LogOptions) *RequestExecutor {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+
 	re.Logger = logger
-	re.middlewares = append(re.middlewares, middlewares.LoggerMiddleware(logger))
+	re.addMiddlewares(middlewares.LoggerMiddlewareWithOptions(logger, opts))
+
 	return re
 }
 
 // AddPerformanceMonitor adds performance monitoring middleware to the RequestExecutor.
-func (re *RequestExecutor) AddPerformanceMonitor(threshold time.Duration, logger *slog.Logger) *RequestExecutor {
+func (re *RequestExecutor) AddPerformanceMonitor(threshold time.Duration, logger middlewares.Logger) *RequestExecutor {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+
 	re.Logger = logger
-	re.middlewares = append(re.middlewares, middlewares.PerformanceMiddleware(threshold, logger))
+	re.addMiddlewares(middlewares.PerformanceMiddleware(threshold, logger))
+
 	return re
 }
 
+// CacheOption configures the caching middleware, see RequestExecutor.AddCaching.
+type CacheOption func(opts *middlewares.CacheOptions)
+
+// CacheKey sets the function computing the cache key of a request, for example to ignore tracing query parameters
+// or to vary the cached responses on a header. The responses remain partitioned by caller, see RequestExecutor.WithCacheIdentity.
+func CacheKey(key middlewares.KeyFunc) CacheOption {
+	return func(opts *middlewares.CacheOptions) {
+		opts.Key = key
+	}
+}
+
+// CacheStale serves the expired responses for up to stale while a single request refreshes them,
+// instead of making the concurrent requests wait for the refresh.
+func CacheStale(stale time.Duration) CacheOption {
+	return func(opts *middlewares.CacheOptions) {
+		opts.Stale = stale
+	}
+}
+
 // AddCaching adds caching middleware to the RequestExecutor with the specified TTL.
-func (re *RequestExecutor) AddCaching(ttl time.Duration) *RequestExecutor {
+func (re *RequestExecutor) AddCaching(ttl time.Duration, opts ...CacheOption) *RequestExecutor {
+	return re.addCaching(cache.New(ttl, 2*ttl), middlewares.CacheOptions{TTL: ttl}, opts)
+}
+
+// AddHTTPCaching adds caching middleware to the RequestExecutor which caches responses as long as their Cache-Control or Expires headers allow.
+// Responses without caching directives are cached for defaultTTL, or not at all when it is zero.
+func (re *RequestExecutor) AddHTTPCaching(defaultTTL time.Duration, opts ...CacheOption) *RequestExecutor {
+	return re.addCaching(cache.New(cache.NoExpiration, time.Minute), middlewares.CacheOptions{TTL: defaultTTL, CacheControl: true}, opts)
+}
+
+// addCaching adds the caching middleware with the options, unless caching is already enabled.
+func (re *RequestExecutor) addCaching(c *cache.Cache, cacheOpts middlewares.CacheOptions, opts []CacheOption) *RequestExecutor {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+
 	if re.cacheEnabled {
 		return re
 	}
 
-	c := cache.New(ttl, 2*ttl)
+	cacheOpts.Identity = re.identity
+	for _, opt := range opts {
+		opt(&cacheOpts)
+	}
 
-	re.WithMiddleware(middlewares.CachingMiddleware(c, ttl))
+	re.addMiddlewares(middlewares.CachingMiddlewareWithOptions(c, cacheOpts))
 	re.cacheEnabled = true
+	re.cache = c
+
+	return re
+}
+
+// InvalidateCache removes the cached responses of the URLs matching urlPattern, such as "https://api.example.com/users/*",
+// and returns the number of responses removed. The pattern has the syntax of path.Match. It removes nothing when caching is not enabled.
+func (re *RequestExecutor) InvalidateCache(urlPattern string) int {
+	re.mu.Lock()
+	c := re.cache
+	re.mu.Unlock()
+
+	if c == nil {
+		return 0
+	}
+
+	return middlewares.InvalidateCache(c, urlPattern)
+}
+
+// WithCacheIdentity sets the function identifying the caller of a request, so cached responses are only served to the same caller.
+// By default the identity is derived from the access token when authorization is enabled, or from the Authorization header otherwise.
+func (re *RequestExecutor) WithCacheIdentity(identity middlewares.IdentityFunc) *RequestExecutor {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+
+	re.cacheIdentity = identity
 
 	return re
 }
 
+// identity returns the identity used to partition the cached responses of a request.
+func (re *RequestExecutor) identity(req *http.Request) string {
+	re.mu.Lock()
+	identity := re.cacheIdentity
+	re.mu.Unlock()
+
+	if identity != nil {
+		return identity(req)
+	}
+
+	return middlewares.AuthorizationIdentity(req)
+}
+
 // WithExponentialRetry adds exponential retry middleware to the RequestExecutor with the specified retry count.
 func (re *RequestExecutor) WithExponentialRetry(retry int) *RequestExecutor {
-	if re.retryEnabled {
-		return re
-	}
+	return re.withRetry(middlewares.RetryHandler{
+		MinWait:    re.MinWaitRetry,
+		MaxWait:    re.MaxWaitRetry,
+		RetryCount: retry,
+		Backoff:    middlewares.ExponentialBackoffTime,
+	})
+}
 
-	rh := middlewares.RetryHandler{
+// WithLinearRetry adds linear retry middleware to the RequestExecutor with the specified retry count.
+func (re *RequestExecutor) WithLinearRetry(retry int) *RequestExecutor {
+	return re.withRetry(middlewares.RetryHandler{
+		MinWait:    re.MinWaitRetry,
+		MaxWait:    re.MaxWaitRetry,
+		RetryCount: retry,
+		Backoff:    middlewares.LinearJitterBackoffTime,
+	})
+}
+
+// WithTransportRetry adds exponential retry middleware to the RequestExecutor which retries only network and transport failures.
+// Responses are returned as received, including 5xx ones.
+func (re *RequestExecutor) WithTransportRetry(retry int) *RequestExecutor {
+	return re.withRetry(middlewares.RetryHandler{
 		MinWait:    re.MinWaitRetry,
 		MaxWait:    re.MaxWaitRetry,
 		RetryCount: retry,
 		Backoff:    middlewares.ExponentialBackoffTime,
+		CheckRetry: middlewares.TransportErrorRetryPolicy,
+	})
+}
+
+// withRetry adds the retry middleware to the RequestExecutor, unless retry is already enabled.
+func (re *RequestExecutor) withRetry(rh middlewares.RetryHandler) *RequestExecutor {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+
+	if re.retryEnabled {
+		return re
 	}
 
-	re.WithMiddleware(middlewares.RetryMiddleware(rh))
-	re.retryEnabled = true
+	re.setRetry(rh)
 
 	return re
 }
 
-// WithLinearRetry adds linear retry middleware to the RequestExecutor with the specified retry count.
-func (re *RequestExecutor) WithLinearRetry(retry int) *RequestExecutor {
+// setRetry adds the retry middleware, or replaces it in place when retry is enabled. The caller must hold re.mu.
+func (re *RequestExecutor) setRetry(rh middlewares.RetryHandler) {
+	rh.AttemptHeader = re.attemptHeader
+	rh.AttemptTimeout = re.retryTimeouts.Attempt
+	rh.MaxElapsedTime = re.retryTimeouts.MaxElapsed
+	re.retryHandler = rh
+
 	if re.retryEnabled {
-		return re
+		re.middlewares[re.retryIndex] = middlewares.RetryMiddleware(rh)
+		re.buildPipeline()
+		return
 	}
 
-	rh := middlewares.RetryHandler{
-		MinWait:    re.MinWaitRetry,
-		MaxWait:    re.MaxWaitRetry,
-		RetryCount: retry,
-		Backoff:    middlewares.LinearJitterBackoffTime,
+	re.retryIndex = len(re.middlewares)
+	re.addMiddlewares(middlewares.RetryMiddleware(rh))
+	re.retryEnabled = true
+}
+
+// WithAttemptHeader sends the number of each attempt of the retried requests in the header, such as X-Attempt, starting at 1.
+func (re *RequestExecutor) WithAttemptHeader(header string) *RequestExecutor {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+
+	re.attemptHeader = header
+
+	if re.retryEnabled {
+		re.setRetry(re.retryHandler)
 	}
 
-	re.WithMiddleware(middlewares.RetryMiddleware(rh))
-	re.retryEnabled = true
+	return re
+}
+
+// RetryTimeouts bounds the retried requests. Zero values leave them unbounded.
+type RetryTimeouts struct {
+	// Attempt bounds each attempt until its response headers are received. Attempts cut by it are retried.
+	Attempt time.Duration
+	// MaxElapsed bounds the time spent over all the attempts and the waits between them. Requests cut by it fail with a TimeoutError.
+	MaxElapsed time.Duration
+}
+
+// WithRetryTimeouts sets the timeouts of the retried requests, see RetryTimeouts. They apply once retry is enabled.
+// The client timeout, see WithTimeout, still bounds each attempt, body included.
+func (re *RequestExecutor) WithRetryTimeouts(timeouts RetryTimeouts) *RequestExecutor {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+
+	re.retryTimeouts = timeouts
+
+	if re.retryEnabled {
+		re.setRetry(re.retryHandler)
+	}
 
 	return re
 }
 
 // WithAuthorization adds authorization middleware to the RequestExecutor with the specified schema and authorization function.
 func (re *RequestExecutor) WithAuthorization(schema string, authorize middlewares.AuthorizeFunc) *RequestExecutor {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+
 	if re.authEnabled {
 		return re
 	}
 
-	tr := middlewares.NewTokenRefresher(schema, authorize, re.Logger)
+	re.useTokenRefresher(middlewares.NewTokenRefresher(schema, authorize, re.Logger))
 
-	re.WithMiddleware(middlewares.AuthorizeMiddleware(tr))
-	re.retryEnabled = true
+	return re
+}
+
+// WithSharedAuthorization adds authorization middleware to the RequestExecutor using the token shared under key by every executor.
+// Only the first executor configured with the key authenticates, see middlewares.SharedTokenRefresher.
+func (re *RequestExecutor) WithSharedAuthorization(key, schema string, authorize middlewares.AuthorizeFunc) *RequestExecutor {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+
+	if re.authEnabled {
+		return re
+	}
+
+	re.useTokenRefresher(middlewares.SharedTokenRefresher(key, schema, authorize, re.Logger))
 
 	return re
 }
 
+// WithTokenRefresher adds authorization middleware to the RequestExecutor using tokens of an existing TokenRefresher.
+func (re *RequestExecutor) WithTokenRefresher(tr *middlewares.TokenRefresher) *RequestExecutor {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+
+	if re.authEnabled {
+		return re
+	}
+
+	re.useTokenRefresher(tr)
+
+	return re
+}
+
+// WithTenantAuthorization adds authorization middleware to the RequestExecutor using a token per tenant.
+// The tenant of a request is read from its context, see middlewares.ContextWithTenant.
+func (re *RequestExecutor) WithTenantAuthorization(schema string, authorize middlewares.TenantAuthorizeFunc) *RequestExecutor {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+
+	if re.authEnabled {
+		return re
+	}
+
+	tr := middlewares.NewTenantTokenRefresher(schema, authorize, re.Logger)
+
+	re.addMiddlewares(middlewares.TenantAuthorizeMiddleware(tr))
+	re.authEnabled = true
+	re.tenantTokens = tr
+
+	if re.cacheIdentity == nil {
+		re.cacheIdentity = middlewares.TenantIdentity
+	}
+
+	return re
+}
+
+// RemoveTenantToken stops refreshing the token of the tenant added by WithTenantAuthorization, such as when the tenant is offboarded.
+// The token is obtained again if a later request is made on behalf of the tenant.
+func (re *RequestExecutor) RemoveTenantToken(tenant string) {
+	re.mu.Lock()
+	tr := re.tenantTokens
+	re.mu.Unlock()
+
+	if tr != nil {
+		tr.Remove(tenant)
+	}
+}
+
+// useTokenRefresher adds the authorization middleware of the TokenRefresher. The caller must hold re.mu.
+func (re *RequestExecutor) useTokenRefresher(tr *middlewares.TokenRefresher) {
+	re.addMiddlewares(middlewares.AuthorizeMiddleware(tr))
+	re.authEnabled = true
+
+	if re.cacheIdentity == nil {
+		re.cacheIdentity = middlewares.TokenIdentity(tr)
+	}
+}
+
+// WithTokenSource adds authorization middleware to the RequestExecutor, adding Bearer tokens obtained from the oauth2.TokenSource.
+func (re *RequestExecutor) WithTokenSource(ts oauth2.TokenSource) *RequestExecutor {
+	return re.WithAuthorization("Bearer", auth.TokenSourceAuthorizer(ts))
+}
+
+// WithNegotiate adds Negotiate (SPNEGO) authentication middleware to the RequestExecutor, using the provider to obtain the Kerberos tokens.
+func (re *RequestExecutor) WithNegotiate(provider middlewares.TicketProvider, opts middlewares.NegotiateOptions) *RequestExecutor {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+
+	if re.authEnabled {
+		return re
+	}
+
+	re.addMiddlewares(middlewares.NegotiateMiddleware(provider, opts, re.Logger))
+	re.authEnabled = true
+
+	return re
+}
+
+// WithToggle adds a middleware to the RequestExecutor which can be enabled, disabled and replaced at runtime under name, see Toggle.
+// When the name is already used, the previous toggle stays in the pipeline but can no longer be looked up.
+func (re *RequestExecutor) WithToggle(name string, m middlewares.Middleware, enabled bool) *RequestExecutor {
+	t := middlewares.NewToggle(m, enabled)
+
+	re.mu.Lock()
+	defer re.mu.Unlock()
+
+	if re.toggles == nil {
+		re.toggles = map[string]*middlewares.Toggle{}
+	}
+
+	re.toggles[name] = t
+	re.addMiddlewares(t.Middleware())
+
+	return re
+}
+
+// Toggle returns the toggle added under name, or nil when there is none.
+func (re *RequestExecutor) Toggle(name string) *middlewares.Toggle {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+
+	return re.toggles[name]
+}
+
+// SetToggles enables or disables the toggles by name, such as from a reloaded configuration. Unknown names are logged and ignored.
+func (re *RequestExecutor) SetToggles(states map[string]bool) {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+
+	for name, enabled := range states {
+		t, ok := re.toggles[name]
+		if !ok {
+			re.Logger.Warn("Unknown middleware toggle", "Name", name)
+			continue
+		}
+
+		t.SetEnabled(enabled)
+	}
+}
+
+// ErrorReporter receives the errors of the requests which failed, for example to forward them to an error tracker such as Sentry.
+type ErrorReporter func(ctx context.Context, err *Error)
+
+// OnErrorReport registers a reporter called for every request which fails, once its retries are exhausted.
+// The reporter runs synchronously, before the error is returned to the caller, with the context of the request.
+func (re *RequestExecutor) OnErrorReport(report ErrorReporter) *RequestExecutor {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+
+	reporters, _ := re.reporters.Load().([]ErrorReporter)
+	re.reporters.Store(append(append([]ErrorReporter{}, reporters...), report))
+
+	return re
+}
+
+// RegisterCodec registers the codec under the name, for Request.WithCodec, and for the payloads and responses of its content types.
+// Registered codecs take precedence over the built-in JSON and XML ones, and of two codecs declaring a content type the latest registered is used.
+func (re *RequestExecutor) RegisterCodec(name string, c Codec) *RequestExecutor {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+
+	codecs, _ := re.codecs.Load().([]namedCodec)
+	registered := make([]namedCodec, 0, len(codecs)+1)
+	for _, nc := range codecs {
+		if nc.name != name {
+			registered = append(registered, nc)
+		}
+	}
+	re.codecs.Store(append(registered, namedCodec{name: name, codec: c}))
+
+	return re
+}
+
+// codecNamed returns the codec registered under the name.
+func (re *RequestExecutor) codecNamed(name string) (Codec, bool) {
+	codecs, _ := re.codecs.Load().([]namedCodec)
+	for _, nc := range codecs {
+		if nc.name == name {
+			return nc.codec, true
+		}
+	}
+
+	return nil, false
+}
+
+// codecFor returns the latest registered codec declaring the media type.
+func (re *RequestExecutor) codecFor(mediaType string) (Codec, bool) {
+	if mediaType == "" {
+		return nil, false
+	}
+
+	codecs, _ := re.codecs.Load().([]namedCodec)
+	for i := len(codecs) - 1; i >= 0; i-- {
+		if handles(codecs[i].codec, mediaType) {
+			return codecs[i].codec, true
+		}
+	}
+
+	return nil, false
+}
+
+// reportError calls the error reporters with the error of a failed request.
+func (re *RequestExecutor) reportError(ctx context.Context, err *Error) {
+	reporters, _ := re.reporters.Load().([]ErrorReporter)
+	for _, report := range reporters {
+		report(ctx, err)
+	}
+}
+
+// WithDebugDumps enables or disables the dump of the request and response on the errors returned by the requests, see Error.Dump.
+// Credentials are redacted and bodies are truncated.
+func (re *RequestExecutor) WithDebugDumps(enabled bool) *RequestExecutor {
+	re.debugDumps.Store(enabled)
+	return re
+}
+
+// WithExpvar adds middleware to the RequestExecutor which publishes request counters under the expvar map namespace.
+func (re *RequestExecutor) WithExpvar(namespace string) *RequestExecutor {
+	return re.WithMiddleware(middlewares.ExpvarMiddleware(namespace))
+}
+
+// WithBandwidthLimit adds middleware to the RequestExecutor which limits the transfer of request and response bodies to bytesPerSec,
+// shared by all the requests of the executor.
+func (re *RequestExecutor) WithBandwidthLimit(bytesPerSec int64) *RequestExecutor {
+	return re.WithMiddleware(middlewares.BandwidthMiddleware(bytesPerSec))
+}
+
+// WithRequestCompression adds middleware to the RequestExecutor which compresses request bodies with zstd or gzip.
+func (re *RequestExecutor) WithRequestCompression(opts middlewares.CompressionOptions) *RequestExecutor {
+	return re.WithMiddleware(middlewares.CompressionMiddleware(opts))
+}
+
+// WithAdaptiveTimeouts adds middleware to the RequestExecutor which derives the timeout of each route from its observed latencies.
+// Keep the client timeout above the maximum adaptive timeout, as it still bounds the whole exchange.
+func (re *RequestExecutor) WithAdaptiveTimeouts(opts middlewares.AdaptiveTimeoutOptions) *RequestExecutor {
+	return re.WithMiddleware(middlewares.AdaptiveTimeoutMiddleware(opts))
+}
+
+// WithRateLimit adds middleware to the RequestExecutor which sends at most rps requests per second on average, in bursts of up to burst requests.
+// Requests wait for their turn, bounded by their context.
+func (re *RequestExecutor) WithRateLimit(rps float64, burst int) *RequestExecutor {
+	return re.WithRateLimiter(middlewares.NewRateLimiter(rps, burst), middlewares.RateLimitOptions{})
+}
+
+// WithRateLimiter adds middleware to the RequestExecutor which throttles the requests with a rate limiter, which may be shared with other executors.
+// Requests rejected because of RateLimitOptions.MaxWait report a RateLimitError.
+func (re *RequestExecutor) WithRateLimiter(l *middlewares.RateLimiter, opts middlewares.RateLimitOptions) *RequestExecutor {
+	return re.WithMiddleware(middlewares.RateLimitMiddleware(l, opts))
+}
+
+// WithCircuitBreaker adds middleware to the RequestExecutor which fails fast while a host keeps failing, see middlewares.CircuitBreakerMiddleware.
+// Rejected requests report a CircuitOpenError. Configure it before the retries so that each attempt is counted.
+func (re *RequestExecutor) WithCircuitBreaker(opts middlewares.CircuitBreakerOptions) *RequestExecutor {
+	return re.WithMiddleware(middlewares.CircuitBreakerMiddleware(opts))
+}
+
+// WithHedging adds middleware to the RequestExecutor which sends a duplicate of slow requests and keeps the first response,
+// see middlewares.HedgingMiddleware. Configure it before the retries, so that each attempt is hedged.
+func (re *RequestExecutor) WithHedging(opts middlewares.HedgeOptions) *RequestExecutor {
+	return re.WithMiddleware(middlewares.HedgingMiddleware(opts))
+}
+
+// WithFaultInjection adds middleware to the RequestExecutor which injects latency, dropped connections and error status codes
+// into the matching requests, see middlewares.FaultInjectionMiddleware. Configure it before the retries and the circuit breaker,
+// so that they handle the injected faults. Dropped requests report a ConnectionError.
+func (re *RequestExecutor) WithFaultInjection(faults ...middlewares.Fault) *RequestExecutor {
+	return re.WithMiddleware(middlewares.FaultInjectionMiddleware(faults...))
+}
+
+// WithStallTimeout adds middleware to the RequestExecutor which aborts requests receiving no bytes for the idle window, see middlewares.StallWatchdogMiddleware.
+// Failed requests report a StallError. It is independent of the client timeout, which may stay unset for long downloads.
+func (re *RequestExecutor) WithStallTimeout(idle time.Duration) *RequestExecutor {
+	return re.WithMiddleware(middlewares.StallWatchdogMiddleware(idle))
+}
+
+// WithLoadShedding adds middleware to the RequestExecutor which rejects low priority requests while the load is high,
+// see Request.WithPriority. Configure it after rate limiting and circuit breaking, so that it runs before them.
+func (re *RequestExecutor) WithLoadShedding(opts middlewares.LoadShedOptions) *RequestExecutor {
+	return re.WithMiddleware(middlewares.LoadSheddingMiddleware(opts))
+}
+
+// WithCanary adds middleware to the RequestExecutor which sends a percentage of the requests to alternate base URLs, see middlewares.Canary.
+func (re *RequestExecutor) WithCanary(c *middlewares.Canary) *RequestExecutor {
+	return re.WithMiddleware(middlewares.CanaryMiddleware(c))
+}
+
+// WithAcceptEncoding controls the encodings advertised in the Accept-Encoding header of the requests, and whether responses are decompressed.
+// With Passthrough, response bodies are returned compressed, with their Content-Encoding header.
+func (re *RequestExecutor) WithAcceptEncoding(opts middlewares.AcceptEncodingOptions) *RequestExecutor {
+	if opts.Passthrough {
+		re.updateTransport(func(t *http.Transport) {
+			t.DisableCompression = true
+		})
+	}
+
+	return re.WithMiddleware(middlewares.AcceptEncodingMiddleware(opts))
+}
+
+// OutboxOption configures the outbox, see RequestExecutor.WithOutbox.
+type OutboxOption func(o *middlewares.Outbox)
+
+// OutboxHeaders sets the filter selecting the request headers stored in the outbox.
+// By default the headers carrying credentials are not stored, see middlewares.SkipCredentialHeaders.
+func OutboxHeaders(filter middlewares.OutboxHeaderFilter) OutboxOption {
+	return func(o *middlewares.Outbox) {
+		o.WithHeaderFilter(filter)
+	}
+}
+
+// WithOutbox adds middleware to the RequestExecutor which stores mutating requests in the store when the server is unreachable,
+// and replays them in order once it is reachable again, waiting between MinWaitRetry and MaxWaitRetry between attempts.
+// Middlewares added before the outbox, such as authorization, also run for the replayed requests.
+// The headers carrying credentials, such as Authorization, are not stored unless configured with OutboxHeaders.
+func (re *RequestExecutor) WithOutbox(store middlewares.OutboxStore, opts ...OutboxOption) *RequestExecutor {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+
+	if re.outbox != nil {
+		return re
+	}
+
+	outbox := middlewares.NewOutbox(store, re.MinWaitRetry, re.MaxWaitRetry, re.Logger)
+	for _, opt := range opts {
+		opt(outbox)
+	}
+
+	re.addMiddlewares(middlewares.OutboxMiddleware(outbox))
+	re.outbox = outbox
+
+	return re
+}
+
+// CloseOutbox stops replaying the requests stored by the outbox added by WithOutbox, and waits for the replay to end.
+// The requests left in the store are replayed by the next executor using it.
+func (re *RequestExecutor) CloseOutbox() {
+	re.mu.Lock()
+	outbox := re.outbox
+	re.mu.Unlock()
+
+	if outbox != nil {
+		outbox.Close()
+	}
+}
+
+// WithTracePropagation adds middleware to the RequestExecutor which propagates the trace context of the request context in the specified format.
+// Use middlewares.ContextWithTrace to attach an incoming trace context to the request context.
+func (re *RequestExecutor) WithTracePropagation(format middlewares.TraceFormat) *RequestExecutor {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+
+	if re.traceEnabled {
+		return re
+	}
+
+	re.addMiddlewares(middlewares.TraceMiddleware(format))
+	re.traceEnabled = true
+
+	return re
+}
+
+// derive creates a RequestExecutor with a copy of the client and of the middlewares of re.
+// The copy shares the transport, and so the connection pool, and the state of the middlewares, such as tokens and caches.
+func (re *RequestExecutor) derive() *RequestExecutor {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+
+	child := &RequestExecutor{
+		middlewares:   append([]middlewares.Middleware{}, re.middlewares...),
+		cacheEnabled:  re.cacheEnabled,
+		cache:         re.cache,
+		tenantTokens:  re.tenantTokens,
+		retryEnabled:  re.retryEnabled,
+		authEnabled:   re.authEnabled,
+		traceEnabled:  re.traceEnabled,
+		outbox:        re.outbox,
+		retryIndex:    re.retryIndex,
+		retryHandler:  re.retryHandler,
+		attemptHeader: re.attemptHeader,
+		retryTimeouts: re.retryTimeouts,
+		baseURL:       re.baseURL,
+		cacheIdentity: re.cacheIdentity,
+		toggles:       make(map[string]*middlewares.Toggle, len(re.toggles)),
+
+		MinWaitRetry: re.MinWaitRetry,
+		MaxWaitRetry: re.MaxWaitRetry,
+		Logger:       re.Logger,
+	}
+
+	for name, t := range re.toggles {
+		child.toggles[name] = t
+	}
+
+	client := *re.httpClient()
+	child.client.Store(&client)
+	child.debugDumps.Store(re.debugDumps.Load())
+
+	if reporters := re.reporters.Load(); reporters != nil {
+		child.reporters.Store(reporters)
+	}
+
+	if codecs := re.codecs.Load(); codecs != nil {
+		child.codecs.Store(codecs)
+	}
+
+	child.buildPipeline()
+
+	return child
+}
+
+// addMiddlewares appends the middlewares and rebuilds the pipeline. The caller must hold re.mu.
+func (re *RequestExecutor) addMiddlewares(handlers ...middlewares.Middleware) {
+	re.middlewares = append(re.middlewares, handlers...)
+	re.buildPipeline()
+}
+
+// buildPipeline wraps the http.Client call with the middlewares and publishes the resulting handler.
+// The first middleware is the innermost one.
+func (re *RequestExecutor) buildPipeline() {
+	pipeline := re.do()
+
+	for _, h := range re.middlewares {
+		pipeline = h(pipeline)
+	}
+
+	re.pipeline.Store(pipeline)
+}
+
+// handler returns the current pipeline of the RequestExecutor.
+func (re *RequestExecutor) handler() middlewares.Handler {
+	return re.pipeline.Load().(middlewares.Handler)
+}
+
+// httpClient returns the current http.Client of the RequestExecutor.
+func (re *RequestExecutor) httpClient() *http.Client {
+	return re.client.Load().(*http.Client)
+}
+
+// updateClient applies update to a copy of the http.Client and publishes it, so in-flight requests keep using the previous one.
+func (re *RequestExecutor) updateClient(update func(c *http.Client)) {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+
+	c := *re.httpClient()
+	update(&c)
+	re.client.Store(&c)
+}
+
+// updateTransport applies update to a copy of the transport of the client.
+// Clients using a custom http.RoundTripper are left unchanged and a warning is logged.
+func (re *RequestExecutor) updateTransport(update func(t *http.Transport)) {
+	re.updateClient(func(c *http.Client) {
+		var t *http.Transport
+		switch rt := c.Transport.(type) {
+		case nil:
+			t = http.DefaultTransport.(*http.Transport).Clone()
+		case *http.Transport:
+			t = rt.Clone()
+		default:
+			re.Logger.Warn("Transport settings ignored, the client does not use an *http.Transport")
+			return
+		}
+
+		update(t)
+		c.Transport = t
+	})
+}
+
 // do returns a function that executes the HTTP request using the RequestExecutor's http.Client.
-func (re *RequestExecutor) do() func(req *http.Request) (*http.Response, error) {
+func (re *RequestExecutor) do() middlewares.Handler {
 	return func(req *http.Request) (*http.Response, error) {
-		return re.client.Do(req)
+		return re.httpClient().Do(req)
 	}
 }
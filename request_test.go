@@ -1,19 +1,47 @@
 package swiftreq_test
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
 	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"expvar"
 	"fmt"
 	"io"
+	"log/slog"
+	"mime/multipart"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/klauspost/compress/zstd"
 	"github.com/liviudnicoara/swiftreq"
+	"github.com/liviudnicoara/swiftreq/middlewares"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
 )
 
 var (
@@ -30,6 +58,22 @@ type TestResponse struct {
 	Name string
 }
 
+type upperText string
+
+func (u *upperText) UnmarshalText(text []byte) error {
+	*u = upperText(strings.ToUpper(string(text)))
+	return nil
+}
+
+type binaryPayload struct {
+	Length int
+}
+
+func (b *binaryPayload) UnmarshalBinary(data []byte) error {
+	b.Length = len(data)
+	return nil
+}
+
 func TestMain(m *testing.M) {
 	fmt.Println("mocking server")
 	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -38,6 +82,28 @@ func TestMain(m *testing.M) {
 			mockGetEndpoint(w, r)
 		case "/error":
 			mockErrorEndpoint(w, r)
+		case "/server-error":
+			mockServerErrorEndpoint(w, r)
+		case "/headers":
+			mockHeadersEndpoint(w, r)
+		case "/query":
+			mockQueryEndpoint(w, r)
+		case "/xml":
+			mockXMLEndpoint(w, r)
+		case "/pages":
+			mockPagesEndpoint(w, r)
+		case "/bom":
+			mockBOMEndpoint(w, r)
+		case "/echo-text":
+			mockEchoTextEndpoint(w, r)
+		case "/binary":
+			mockBinaryEndpoint(w, r)
+		case "/redirect":
+			http.Redirect(w, r, "/redirect/1", http.StatusFound)
+		case "/redirect/1":
+			http.Redirect(w, r, "/", http.StatusMovedPermanently)
+		case "/text":
+			mockTextEndpoint(w, r)
 		case "/timeout":
 			mockTimeoutEndpoint(w, r)
 		case "/post":
@@ -68,6 +134,76 @@ func mockErrorEndpoint(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(m)
 }
 
+func mockServerErrorEndpoint(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(http.StatusInternalServerError)
+	w.Write([]byte("internal error"))
+}
+
+func mockTextEndpoint(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("not a number"))
+}
+
+func mockHeadersEndpoint(w http.ResponseWriter, r *http.Request) {
+	m := make(map[string]string)
+	for k := range r.Header {
+		m[k] = r.Header.Get(k)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(m)
+}
+
+func mockQueryEndpoint(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(r.URL.Query())
+}
+
+func mockPagesEndpoint(w http.ResponseWriter, r *http.Request) {
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	items := []int{}
+	if page < 3 {
+		items = append(items, page*2+1, page*2+2)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(items)
+}
+
+func mockBOMEndpoint(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("\xef\xbb\xbf{\"id\":1,\"name\":\"mock\"}"))
+}
+
+func mockXMLEndpoint(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Accept") != "application/xml" {
+		w.WriteHeader(http.StatusNotAcceptable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("<TestResponse><ID>1</ID><Name>mock</Name></TestResponse>"))
+}
+
+func mockEchoTextEndpoint(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(r.URL.Query().Get("value")))
+}
+
+func mockBinaryEndpoint(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte{0x01, 0x02, 0x03})
+}
+
 func mockGetEndpoint(w http.ResponseWriter, r *http.Request) {
 	idString := r.URL.Query().Get("id")
 
@@ -225,3 +361,4599 @@ func Test_Put(t *testing.T) {
 		assert.Nil(t, resp)
 	})
 }
+
+func Test_ErrorKinds(t *testing.T) {
+	t.Run("ClientError", func(t *testing.T) {
+		// act
+		_, err := swiftreq.Get[TestResponse](server.URL + "/error").Do(context.Background())
+
+		// assert
+		var ce *swiftreq.ClientError
+		assert.True(t, errors.As(err, &ce))
+		assert.Equal(t, http.StatusBadRequest, ce.StatusCode)
+	})
+
+	t.Run("ServerError", func(t *testing.T) {
+		// act
+		_, err := swiftreq.Get[string](server.URL + "/server-error").Do(context.Background())
+
+		// assert
+		var se *swiftreq.ServerError
+		assert.True(t, errors.As(err, &se))
+		assert.Equal(t, http.StatusInternalServerError, se.StatusCode)
+		assert.Equal(t, "internal error", string(se.Body))
+	})
+
+	t.Run("TimeoutError", func(t *testing.T) {
+		// arrange
+		re := swiftreq.NewRequestExecutor(http.Client{Timeout: 100 * time.Millisecond})
+
+		// act
+		_, err := swiftreq.Get[TestResponse](server.URL + "/timeout").WithRequestExecutor(re).Do(context.Background())
+
+		// assert
+		var te *swiftreq.TimeoutError
+		assert.True(t, errors.As(err, &te))
+	})
+
+	t.Run("ConnectionError", func(t *testing.T) {
+		// act
+		_, err := swiftreq.Get[TestResponse]("http://127.0.0.1:1").Do(context.Background())
+
+		// assert
+		var ce *swiftreq.ConnectionError
+		assert.True(t, errors.As(err, &ce))
+	})
+
+	t.Run("CanceledError", func(t *testing.T) {
+		// arrange
+		shutdown := errors.New("shutting down")
+		ctx, cancel := context.WithCancelCause(context.Background())
+		time.AfterFunc(50*time.Millisecond, func() { cancel(shutdown) })
+
+		// act
+		_, err := swiftreq.Get[TestResponse](server.URL + "/timeout").Do(ctx)
+
+		// assert
+		var ce *swiftreq.CanceledError
+		assert.True(t, errors.As(err, &ce))
+		assert.Equal(t, shutdown, ce.Cause)
+		assert.ErrorIs(t, err, shutdown)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Contains(t, err.Error(), "shutting down")
+	})
+
+	t.Run("DeadlineCause", func(t *testing.T) {
+		// arrange
+		slow := errors.New("upstream too slow")
+		ctx, cancel := context.WithTimeoutCause(context.Background(), 50*time.Millisecond, slow)
+		defer cancel()
+
+		// act
+		_, err := swiftreq.Get[TestResponse](server.URL + "/timeout").Do(ctx)
+
+		// assert
+		var te *swiftreq.TimeoutError
+		assert.True(t, errors.As(err, &te))
+		assert.Equal(t, slow, te.Cause)
+		assert.ErrorIs(t, err, slow)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("CanceledDuringRetryBackoff", func(t *testing.T) {
+		// arrange
+		re := swiftreq.NewRequestExecutor(*http.DefaultClient)
+		re.MinWaitRetry = time.Second
+		re.MaxWaitRetry = time.Second
+		re.WithExponentialRetry(3)
+
+		shutdown := errors.New("shutting down")
+		ctx, cancel := context.WithCancelCause(context.Background())
+		time.AfterFunc(50*time.Millisecond, func() { cancel(shutdown) })
+
+		// act
+		_, err := swiftreq.Get[string](server.URL + "/server-error").WithRequestExecutor(re).Do(ctx)
+
+		// assert
+		var ce *swiftreq.CanceledError
+		assert.True(t, errors.As(err, &ce))
+		assert.ErrorIs(t, err, shutdown)
+		assert.Contains(t, err.Error(), "giving up after 1 attempt(s): context canceled: shutting down")
+	})
+
+	t.Run("DecodeError", func(t *testing.T) {
+		// act
+		_, err := swiftreq.Get[int](server.URL + "/text").Do(context.Background())
+
+		// assert
+		var de *swiftreq.DecodeError
+		assert.True(t, errors.As(err, &de))
+		assert.Equal(t, "text/plain", de.ContentType)
+	})
+}
+
+func Test_TransportRetry(t *testing.T) {
+	t.Run("ServerErrorNotRetried", func(t *testing.T) {
+		// arrange
+		var calls int
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			mockServerErrorEndpoint(w, r)
+		}))
+		defer s.Close()
+
+		re := swiftreq.NewRequestExecutor(*http.DefaultClient)
+		re.MinWaitRetry = time.Millisecond
+		re.WithTransportRetry(3)
+
+		// act
+		_, err := swiftreq.Get[string](s.URL).WithRequestExecutor(re).Do(context.Background())
+
+		// assert
+		var se *swiftreq.ServerError
+		assert.True(t, errors.As(err, &se))
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("TransportErrorRetried", func(t *testing.T) {
+		// arrange
+		re := swiftreq.NewRequestExecutor(*http.DefaultClient)
+		re.MinWaitRetry = time.Millisecond
+		re.WithTransportRetry(2)
+
+		// act
+		_, err := swiftreq.Get[string]("http://127.0.0.1:1").WithRequestExecutor(re).Do(context.Background())
+
+		// assert
+		assert.Contains(t, err.Error(), "giving up after 2 attempt(s)")
+	})
+}
+
+func Test_AuthorizationWithRetry(t *testing.T) {
+	// arrange
+	var calls int
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if calls == 1 {
+			mockServerErrorEndpoint(w, r)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer s.Close()
+
+	re := swiftreq.NewRequestExecutor(*http.DefaultClient).
+		WithAuthorization("Bearer", func() (string, time.Duration, error) {
+			return "token", time.Hour, nil
+		})
+	re.MinWaitRetry = time.Millisecond
+	re.MaxWaitRetry = time.Millisecond
+	re.WithExponentialRetry(2)
+
+	// act
+	resp, err := swiftreq.Get[string](s.URL).WithRequestExecutor(re).Do(context.Background())
+
+	// assert
+	assert.Nil(t, err)
+	assert.Equal(t, "ok", *resp)
+	assert.Equal(t, 2, calls)
+}
+
+func Test_TracePropagation(t *testing.T) {
+	t.Run("W3CPropagated", func(t *testing.T) {
+		// arrange
+		re := swiftreq.NewRequestExecutor(*http.DefaultClient).WithTracePropagation(middlewares.TraceFormatW3C)
+		tc := middlewares.TraceContext{TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", SpanID: "00f067aa0ba902b7", Sampled: true, State: "vendor=1"}
+		ctx := middlewares.ContextWithTrace(context.Background(), tc)
+
+		// act
+		resp, err := swiftreq.Get[map[string]string](server.URL + "/headers").WithRequestExecutor(re).Do(ctx)
+
+		// assert
+		assert.Nil(t, err)
+		assert.Regexp(t, "^00-4bf92f3577b34da6a3ce929d0e0e4736-[0-9a-f]{16}-01$", (*resp)["Traceparent"])
+		assert.NotContains(t, (*resp)["Traceparent"], tc.SpanID)
+		assert.Equal(t, "vendor=1", (*resp)["Tracestate"])
+	})
+
+	t.Run("B3Generated", func(t *testing.T) {
+		// arrange
+		re := swiftreq.NewRequestExecutor(*http.DefaultClient).WithTracePropagation(middlewares.TraceFormatB3)
+
+		// act
+		resp, err := swiftreq.Get[map[string]string](server.URL + "/headers").WithRequestExecutor(re).Do(context.Background())
+
+		// assert
+		assert.Nil(t, err)
+		assert.Regexp(t, "^[0-9a-f]{32}$", (*resp)["X-B3-Traceid"])
+		assert.Regexp(t, "^[0-9a-f]{16}$", (*resp)["X-B3-Spanid"])
+		assert.Equal(t, "1", (*resp)["X-B3-Sampled"])
+	})
+
+	t.Run("B3TraceIDPaddedForW3C", func(t *testing.T) {
+		// arrange
+		re := swiftreq.NewRequestExecutor(*http.DefaultClient).WithTracePropagation(middlewares.TraceFormatW3C)
+		tc, _ := middlewares.ExtractTrace(http.Header{"B3": {"a3ce929d0e0e4736-00f067aa0ba902b7-1"}})
+		ctx := middlewares.ContextWithTrace(context.Background(), tc)
+
+		// act
+		resp, err := swiftreq.Get[map[string]string](server.URL + "/headers").WithRequestExecutor(re).Do(ctx)
+
+		// assert
+		assert.Nil(t, err)
+		assert.Regexp(t, "^00-0000000000000000a3ce929d0e0e4736-[0-9a-f]{16}-01$", (*resp)["Traceparent"])
+	})
+}
+
+func Test_ExtractTrace(t *testing.T) {
+	tests := []struct {
+		name    string
+		flags   string
+		ok      bool
+		sampled bool
+	}{
+		{name: "Sampled", flags: "01", ok: true, sampled: true},
+		{name: "NotSampled", flags: "00", ok: true},
+		{name: "OtherFlagsSampled", flags: "03", ok: true, sampled: true},
+		{name: "OtherFlagsNotSampled", flags: "02", ok: true},
+		{name: "InvalidFlags", flags: "zz"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// arrange
+			h := http.Header{"Traceparent": {"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-" + tt.flags}}
+
+			// act
+			tc, ok := middlewares.ExtractTrace(h)
+
+			// assert
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.sampled, tc.Sampled)
+		})
+	}
+}
+
+func Test_Accept(t *testing.T) {
+	t.Run("XML", func(t *testing.T) {
+		// act
+		resp, err := swiftreq.Get[TestResponse](server.URL + "/xml").AcceptXML().Do(context.Background())
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, 1, resp.ID)
+		assert.Equal(t, "mock", resp.Name)
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		// act
+		resp, err := swiftreq.Get[map[string]string](server.URL + "/headers").AcceptJSON().Do(context.Background())
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, "application/json", (*resp)["Accept"])
+	})
+}
+
+func Test_XML(t *testing.T) {
+	// arrange
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req TestRequest
+		if err := xml.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+		fmt.Fprintf(w, "<TestResponse><ID>%d</ID><Name>%s</Name></TestResponse>", req.ID, r.Header.Get("Content-Type"))
+	}))
+	defer s.Close()
+
+	t.Run("WithCodec", func(t *testing.T) {
+		// act
+		resp, err := swiftreq.Post[TestResponse](s.URL, TestRequest{ID: 3}).WithCodec("xml").Do(context.Background())
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, 3, resp.ID)
+		assert.Equal(t, "application/xml", resp.Name)
+	})
+
+	t.Run("ContentTypeHeader", func(t *testing.T) {
+		// act
+		resp, err := swiftreq.Post[TestResponse](s.URL, TestRequest{ID: 4}).
+			WithHeaders(map[string]string{"Content-Type": "text/xml"}).
+			Do(context.Background())
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, 4, resp.ID)
+		assert.Equal(t, "text/xml", resp.Name)
+	})
+
+	t.Run("UnknownCodec", func(t *testing.T) {
+		// act
+		_, err := swiftreq.Post[TestResponse](s.URL, TestRequest{ID: 5}).WithCodec("yaml").Do(context.Background())
+
+		// assert
+		assert.ErrorContains(t, err, `unknown codec "yaml"`)
+	})
+}
+
+// formCodec encodes and decodes map[string]string values as URL-encoded forms.
+type formCodec struct{}
+
+func (formCodec) Marshal(v any) ([]byte, error) {
+	values := url.Values{}
+	for k, val := range v.(map[string]string) {
+		values.Set(k, val)
+	}
+	return []byte(values.Encode()), nil
+}
+
+func (formCodec) Unmarshal(data []byte, v any) error {
+	values, err := url.ParseQuery(string(data))
+	if err != nil {
+		return err
+	}
+
+	m := map[string]string{}
+	for k := range values {
+		m[k] = values.Get(k)
+	}
+	*(v.(*map[string]string)) = m
+	return nil
+}
+
+func (formCodec) ContentTypes() []string { return []string{"application/x-www-form-urlencoded"} }
+
+func Test_RegisterCodec(t *testing.T) {
+	// arrange
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		values, _ := url.ParseQuery(string(body))
+		values.Set("content-type", r.Header.Get("Content-Type"))
+		values.Set("accept", r.Header.Get("Accept"))
+
+		w.Header().Set("Content-Type", "application/x-www-form-urlencoded")
+		_, _ = w.Write([]byte(values.Encode()))
+	}))
+	defer s.Close()
+
+	re := swiftreq.NewRequestExecutor(http.Client{}).RegisterCodec("form", formCodec{})
+
+	t.Run("WithCodec", func(t *testing.T) {
+		// act
+		resp, err := swiftreq.Post[map[string]string](s.URL, map[string]string{"name": "mock"}).
+			WithCodec("form").
+			WithRequestExecutor(re).
+			Do(context.Background())
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, "mock", (*resp)["name"])
+		assert.Equal(t, "application/x-www-form-urlencoded", (*resp)["content-type"])
+		assert.Equal(t, "application/x-www-form-urlencoded", (*resp)["accept"])
+	})
+
+	t.Run("ByContentType", func(t *testing.T) {
+		// act
+		resp, err := swiftreq.Post[map[string]string](s.URL, map[string]string{"name": "mock"}).
+			WithHeaders(map[string]string{"Content-Type": "application/x-www-form-urlencoded"}).
+			WithRequestExecutor(re).
+			Do(context.Background())
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, "mock", (*resp)["name"])
+		assert.Equal(t, "", (*resp)["accept"])
+	})
+
+	t.Run("NotRegistered", func(t *testing.T) {
+		// act
+		_, err := swiftreq.Post[map[string]string](s.URL, map[string]string{"name": "mock"}).WithCodec("form").Do(context.Background())
+
+		// assert
+		assert.ErrorContains(t, err, `unknown codec "form"`)
+	})
+}
+
+func Test_AdditiveSetters(t *testing.T) {
+	t.Run("WithHeader", func(t *testing.T) {
+		// act
+		resp, err := swiftreq.Get[map[string]string](server.URL+"/headers").
+			WithHeader("X-First", "1").
+			WithHeader("X-Second", "2").
+			Do(context.Background())
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, "1", (*resp)["X-First"])
+		assert.Equal(t, "2", (*resp)["X-Second"])
+		assert.Equal(t, "application/json", (*resp)["Content-Type"])
+	})
+
+	t.Run("WithQueryParameter", func(t *testing.T) {
+		// act
+		resp, err := swiftreq.Get[map[string][]string](server.URL+"/query?page=1").
+			WithQueryParameters(map[string]string{"id": "1"}).
+			WithQueryParameter("id", "2").
+			WithQueryParameter("tag", "a").
+			Do(context.Background())
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"1", "2"}, (*resp)["id"])
+		assert.Equal(t, []string{"a"}, (*resp)["tag"])
+		assert.Equal(t, []string{"1"}, (*resp)["page"])
+	})
+}
+
+func Test_WithHeaders(t *testing.T) {
+	t.Run("Merge", func(t *testing.T) {
+		// act
+		resp, err := swiftreq.Get[map[string]string](server.URL+"/headers").
+			WithHeader("X-First", "1").
+			WithHeaders(map[string]string{"X-Second": "2"}).
+			WithHeaders(map[string]string{"X-First": "3"}).
+			Do(context.Background())
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, "3", (*resp)["X-First"])
+		assert.Equal(t, "2", (*resp)["X-Second"])
+		assert.Equal(t, "application/json", (*resp)["Content-Type"])
+	})
+}
+
+func Test_ConnectionReuse(t *testing.T) {
+	t.Run("RetriedResponsesDrained", func(t *testing.T) {
+		// arrange
+		var conns int
+		s := httptest.NewUnstartedServer(http.HandlerFunc(mockServerErrorEndpoint))
+		s.Config.ConnState = func(c net.Conn, state http.ConnState) {
+			if state == http.StateNew {
+				conns++
+			}
+		}
+		s.Start()
+		defer s.Close()
+
+		re := swiftreq.NewRequestExecutor(http.Client{Transport: &http.Transport{}})
+		re.MinWaitRetry = time.Millisecond
+		re.WithExponentialRetry(3)
+
+		// act
+		_, err := swiftreq.Get[string](s.URL).WithRequestExecutor(re).Do(context.Background())
+		_, _ = swiftreq.Get[string](s.URL).WithRequestExecutor(re).Do(context.Background())
+
+		// assert
+		assert.NotNil(t, err)
+		assert.Equal(t, 1, conns)
+	})
+}
+
+func Test_Logging(t *testing.T) {
+	t.Run("Sampling", func(t *testing.T) {
+		// arrange
+		var buf strings.Builder
+		logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+		re := swiftreq.NewRequestExecutor(*http.DefaultClient).
+			AddLoggingWithOptions(logger, middlewares.LogOptions{Level: slog.LevelDebug, SampleRate: 2})
+
+		// act
+		for i := 0; i < 4; i++ {
+			_, _ = swiftreq.Get[TestResponse](server.URL).WithRequestExecutor(re).Do(context.Background())
+		}
+
+		// assert
+		assert.Equal(t, 2, strings.Count(buf.String(), "level=DEBUG msg=\"Executing request\""))
+		assert.Equal(t, 2, strings.Count(buf.String(), "level=DEBUG msg=\"Executed request\""))
+	})
+
+	t.Run("ErrorsOnly", func(t *testing.T) {
+		// arrange
+		var buf strings.Builder
+		logger := slog.New(slog.NewTextHandler(&buf, nil))
+		re := swiftreq.NewRequestExecutor(*http.DefaultClient).
+			AddLoggingWithOptions(logger, middlewares.LogOptions{DisableSuccess: true})
+
+		// act
+		_, _ = swiftreq.Get[TestResponse](server.URL).WithRequestExecutor(re).Do(context.Background())
+		_, _ = swiftreq.Get[TestResponse]("http://127.0.0.1:1").WithRequestExecutor(re).Do(context.Background())
+
+		// assert
+		assert.NotContains(t, buf.String(), "Executing request")
+		assert.NotContains(t, buf.String(), "Executed request")
+		assert.Equal(t, 1, strings.Count(buf.String(), "level=ERROR"))
+	})
+
+	t.Run("LoggedBeforeAndAfterCall", func(t *testing.T) {
+		// arrange
+		var buf strings.Builder
+		logger := slog.New(slog.NewTextHandler(&buf, nil))
+		re := swiftreq.NewRequestExecutor(*http.DefaultClient).AddLogging(logger)
+
+		var before string
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			before = buf.String()
+		}))
+		defer s.Close()
+
+		// act
+		_, _ = swiftreq.Get[[]byte](s.URL).WithRequestExecutor(re).Do(context.Background())
+
+		// assert
+		assert.Contains(t, before, `level=INFO msg="Executing request"`)
+		assert.NotContains(t, before, "Executed request")
+		assert.Contains(t, buf.String(), `level=INFO msg="Executed request"`)
+	})
+
+	t.Run("EmptyResponse", func(t *testing.T) {
+		// arrange
+		var buf strings.Builder
+		logger := slog.New(slog.NewTextHandler(&buf, nil))
+		re := swiftreq.NewRequestExecutor(*http.DefaultClient).
+			WithMiddleware(func(next middlewares.Handler) middlewares.Handler {
+				return func(r *http.Request) (*http.Response, error) {
+					return nil, nil
+				}
+			}).
+			AddLogging(logger)
+
+		// act
+		_, err := swiftreq.Get[TestResponse](server.URL).WithRequestExecutor(re).Do(context.Background())
+
+		// assert
+		assert.ErrorContains(t, err, "returned empty response")
+		assert.Contains(t, buf.String(), `msg="Executed request"`)
+		assert.NotContains(t, buf.String(), "StatusCode")
+	})
+
+	t.Run("RequestScopedLogger", func(t *testing.T) {
+		// arrange
+		var executorBuf, requestBuf strings.Builder
+		re := swiftreq.NewRequestExecutor(*http.DefaultClient).
+			AddLogging(slog.New(slog.NewTextHandler(&executorBuf, nil)))
+
+		ctx := middlewares.ContextWithLogAttrs(context.Background(), "TraceID", "t-1")
+
+		// act
+		_, _ = swiftreq.Get[TestResponse](server.URL).
+			WithRequestExecutor(re).
+			WithLogger(slog.New(slog.NewTextHandler(&requestBuf, nil))).
+			WithLogAttrs("UserID", 42).
+			Do(ctx)
+		_, _ = swiftreq.Get[TestResponse](server.URL).WithRequestExecutor(re).Do(ctx)
+
+		// assert
+		assert.Contains(t, requestBuf.String(), `msg="Executed request"`)
+		assert.Contains(t, requestBuf.String(), "TraceID=t-1 UserID=42")
+		assert.Equal(t, 1, strings.Count(executorBuf.String(), "Executed request"))
+		assert.Contains(t, executorBuf.String(), "TraceID=t-1")
+		assert.NotContains(t, executorBuf.String(), "UserID")
+	})
+
+	t.Run("AttrsWithCustomLogger", func(t *testing.T) {
+		// arrange
+		logger := &recordingLogger{}
+		re := swiftreq.NewRequestExecutor(*http.DefaultClient).AddLogging(logger)
+
+		// act
+		_, _ = swiftreq.Get[TestResponse](server.URL).WithRequestExecutor(re).WithLogAttrs("UserID", 42).Do(context.Background())
+
+		// assert
+		assert.Equal(t, []any{"URL", server.URL, "Method", "GET", "StatusCode", http.StatusOK, "UserID", 42}, logger.args)
+	})
+}
+
+// recordingLogger records the arguments of the last log line.
+type recordingLogger struct {
+	args []any
+}
+
+func (l *recordingLogger) Debug(msg string, args ...any) { l.args = args }
+func (l *recordingLogger) Info(msg string, args ...any)  { l.args = args }
+func (l *recordingLogger) Warn(msg string, args ...any)  { l.args = args }
+func (l *recordingLogger) Error(msg string, args ...any) { l.args = args }
+
+func Test_WhenFunc(t *testing.T) {
+	t.Run("AppliedOnlyOnMatch", func(t *testing.T) {
+		// arrange
+		tag := func(next middlewares.Handler) middlewares.Handler {
+			return func(req *http.Request) (*http.Response, error) {
+				req.Header.Set("X-Tagged", "yes")
+				return next(req)
+			}
+		}
+		re := swiftreq.NewRequestExecutor(*http.DefaultClient).
+			WithMiddleware(middlewares.WhenFunc(middlewares.And(middlewares.MethodIs("GET"), middlewares.PathMatches("/headers")), tag))
+
+		// act
+		matched, err := swiftreq.Get[map[string]string](server.URL + "/headers").WithRequestExecutor(re).Do(context.Background())
+		skipped, _ := swiftreq.Post[map[string]string](server.URL+"/headers", nil).WithRequestExecutor(re).Do(context.Background())
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, "yes", (*matched)["X-Tagged"])
+		assert.Empty(t, (*skipped)["X-Tagged"])
+	})
+}
+
+func Test_Route(t *testing.T) {
+	t.Run("AppliedOnlyOnRoute", func(t *testing.T) {
+		// arrange
+		tag := func(next middlewares.Handler) middlewares.Handler {
+			return func(req *http.Request) (*http.Response, error) {
+				req.Header.Add("X-Route", "reports")
+				return next(req)
+			}
+		}
+		re := swiftreq.NewRequestExecutor(*http.DefaultClient)
+		re.Route("GET", "/headers/*").Use(tag)
+
+		// act
+		matched, err := swiftreq.Get[map[string]string](server.URL + "/headers").WithRequestExecutor(re).Do(context.Background())
+		skipped, _ := swiftreq.Post[map[string]string](server.URL+"/headers", nil).WithRequestExecutor(re).Do(context.Background())
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, "reports", (*matched)["X-Route"])
+		assert.NotContains(t, *skipped, "X-Route")
+	})
+}
+
+func Test_TransformResponse(t *testing.T) {
+	t.Run("StripBOM", func(t *testing.T) {
+		// arrange
+		re := swiftreq.NewRequestExecutor(*http.DefaultClient).
+			WithMiddleware(middlewares.TransformResponseMiddleware(middlewares.StripBOM))
+
+		// act
+		resp, err := swiftreq.Get[TestResponse](server.URL + "/bom").WithRequestExecutor(re).Do(context.Background())
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, 1, resp.ID)
+		assert.Equal(t, "mock", resp.Name)
+	})
+
+	t.Run("ResponseWithoutHeader", func(t *testing.T) {
+		// arrange
+		re := swiftreq.NewRequestExecutor(*http.DefaultClient).
+			WithMiddleware(func(next middlewares.Handler) middlewares.Handler {
+				return func(r *http.Request) (*http.Response, error) {
+					return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("\xef\xbb\xbf\"ok\"")), Request: r}, nil
+				}
+			}).
+			WithMiddleware(middlewares.TransformResponseMiddleware(middlewares.StripBOM))
+
+		// act
+		resp, err := swiftreq.Get[string](server.URL).WithRequestExecutor(re).Do(context.Background())
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, "ok", *resp)
+	})
+}
+
+func Test_ConcurrentConfiguration(t *testing.T) {
+	t.Run("ConfigureWhileInFlight", func(t *testing.T) {
+		// arrange
+		re := swiftreq.NewRequestExecutor(*http.DefaultClient)
+		done := make(chan struct{})
+
+		// act
+		go func() {
+			defer close(done)
+			for i := 0; i < 10; i++ {
+				re.WithTimeout(time.Duration(i+1) * time.Second)
+				re.WithMiddleware(middlewares.PerformanceMiddleware(time.Second, slog.Default()))
+			}
+		}()
+
+		for i := 0; i < 10; i++ {
+			_, err := swiftreq.Get[TestResponse](server.URL).WithRequestExecutor(re).Do(context.Background())
+			assert.Nil(t, err)
+		}
+		<-done
+	})
+}
+
+func Test_WithEnvironment(t *testing.T) {
+	t.Run("Timeout", func(t *testing.T) {
+		// arrange
+		t.Setenv(swiftreq.EnvTimeout, "100ms")
+		re := swiftreq.NewRequestExecutor(*http.DefaultClient).WithEnvironment()
+
+		// act
+		_, err := swiftreq.Get[TestResponse](server.URL + "/timeout").WithRequestExecutor(re).Do(context.Background())
+
+		// assert
+		var te *swiftreq.TimeoutError
+		assert.True(t, errors.As(err, &te))
+	})
+
+	t.Run("Retries", func(t *testing.T) {
+		// arrange
+		t.Setenv(swiftreq.EnvRetries, "1")
+		re := swiftreq.NewRequestExecutor(*http.DefaultClient)
+		re.MinWaitRetry = time.Millisecond
+		re.WithEnvironment()
+
+		// act
+		_, err := swiftreq.Get[string]("http://127.0.0.1:1").WithRequestExecutor(re).Do(context.Background())
+
+		// assert
+		assert.Contains(t, err.Error(), "giving up after 1 attempt(s)")
+	})
+
+	t.Run("NegativeRetriesLogged", func(t *testing.T) {
+		// arrange
+		var buf strings.Builder
+		t.Setenv(swiftreq.EnvRetries, "-1")
+		re := swiftreq.NewRequestExecutor(*http.DefaultClient)
+		re.Logger = slog.New(slog.NewTextHandler(&buf, nil))
+
+		// act
+		re.WithEnvironment()
+
+		// assert
+		assert.Contains(t, buf.String(), `level=WARN msg="Invalid environment variable" Name=SWIFTREQ_RETRIES Value=-1`)
+	})
+
+	t.Run("IgnoredByDefaultExecutor", func(t *testing.T) {
+		if os.Getenv("SWIFTREQ_TEST_IGNORE_ENV") == "1" {
+			// act
+			_, err := swiftreq.Get[TestResponse](server.URL + "/timeout").Do(context.Background())
+
+			// assert
+			assert.Nil(t, err)
+			return
+		}
+
+		// arrange
+		cmd := exec.Command(os.Args[0], "-test.run=^Test_WithEnvironment$/^IgnoredByDefaultExecutor$")
+		cmd.Env = append(os.Environ(), "SWIFTREQ_TEST_IGNORE_ENV=1", swiftreq.EnvIgnore+"=true", swiftreq.EnvTimeout+"=50ms")
+
+		// act
+		out, err := cmd.CombinedOutput()
+
+		// assert
+		assert.Nil(t, err, string(out))
+	})
+}
+
+func Test_WithValidator(t *testing.T) {
+	t.Run("Valid", func(t *testing.T) {
+		// act
+		resp, err := swiftreq.Get[TestResponse](server.URL).
+			WithQueryParameter("id", "1").
+			WithValidator(func(r TestResponse) error {
+				if r.ID == 0 {
+					return errors.New("missing id")
+				}
+				return nil
+			}).
+			Do(context.Background())
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, 1, resp.ID)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		// act
+		resp, err := swiftreq.Get[TestResponse](server.URL).
+			WithValidator(func(r TestResponse) error {
+				if r.ID == 0 {
+					return errors.New("missing id")
+				}
+				return nil
+			}).
+			Do(context.Background())
+
+		// assert
+		var ve *swiftreq.ValidationError
+		assert.True(t, errors.As(err, &ve))
+		assert.Contains(t, err.Error(), "missing id")
+		assert.Nil(t, resp)
+	})
+}
+
+func Test_ResultTypes(t *testing.T) {
+	t.Run("Map", func(t *testing.T) {
+		// act
+		resp, err := swiftreq.Get[map[string]any](server.URL).WithQueryParameter("id", "1").Do(context.Background())
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, float64(1), (*resp)["id"])
+		assert.Equal(t, "mock", (*resp)["name"])
+	})
+
+	t.Run("Bytes", func(t *testing.T) {
+		// act
+		resp, err := swiftreq.Get[[]byte](server.URL).WithQueryParameter("id", "1").Do(context.Background())
+
+		// assert
+		assert.Nil(t, err)
+		assert.JSONEq(t, `{"id":1,"name":"mock"}`, string(*resp))
+	})
+
+	t.Run("RawBytes", func(t *testing.T) {
+		// act
+		resp, err := swiftreq.Get[swiftreq.RawBytes](server.URL + "/text").Do(context.Background())
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, "not a number", string(*resp))
+	})
+}
+
+func Test_Stream(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		// act
+		resp, err := swiftreq.Get[io.ReadCloser](server.URL + "/text").Do(context.Background())
+
+		// assert
+		assert.Nil(t, err)
+		body, _ := io.ReadAll(*resp)
+		(*resp).Close()
+		assert.Equal(t, "not a number", string(body))
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		// act
+		resp, err := swiftreq.Get[io.ReadCloser](server.URL + "/error").Do(context.Background())
+
+		// assert
+		assert.Contains(t, err.Error(), "custom endpoint error")
+		assert.Nil(t, resp)
+	})
+}
+
+func Test_TextDecoding(t *testing.T) {
+	echo := func(value string) string {
+		return server.URL + "/echo-text?" + url.Values{"value": {value}}.Encode()
+	}
+
+	t.Run("Bool", func(t *testing.T) {
+		// act
+		resp, err := swiftreq.Get[bool](echo("true")).Do(context.Background())
+
+		// assert
+		assert.Nil(t, err)
+		assert.True(t, *resp)
+	})
+
+	t.Run("Int64", func(t *testing.T) {
+		// act
+		resp, err := swiftreq.Get[int64](echo("9007199254740993\n")).Do(context.Background())
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, int64(9007199254740993), *resp)
+	})
+
+	t.Run("Uint8Overflow", func(t *testing.T) {
+		// act
+		_, err := swiftreq.Get[uint8](echo("300")).Do(context.Background())
+
+		// assert
+		var de *swiftreq.DecodeError
+		assert.True(t, errors.As(err, &de))
+	})
+
+	t.Run("Time", func(t *testing.T) {
+		// act
+		resp, err := swiftreq.Get[time.Time](echo("2024-01-02T03:04:05Z")).Do(context.Background())
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), *resp)
+	})
+}
+
+func Test_UnmarshalerDecoding(t *testing.T) {
+	t.Run("TextUnmarshaler", func(t *testing.T) {
+		// act
+		resp, err := swiftreq.Get[upperText](server.URL + "/text").Do(context.Background())
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, upperText("NOT A NUMBER"), *resp)
+	})
+
+	t.Run("PointerTextUnmarshaler", func(t *testing.T) {
+		// act
+		resp, err := swiftreq.Get[*upperText](server.URL + "/text").Do(context.Background())
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, upperText("NOT A NUMBER"), **resp)
+	})
+
+	t.Run("BinaryUnmarshaler", func(t *testing.T) {
+		// act
+		resp, err := swiftreq.Get[binaryPayload](server.URL + "/binary").Do(context.Background())
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, 3, resp.Length)
+	})
+}
+
+func Test_DoWithResponse(t *testing.T) {
+	t.Run("Redirects", func(t *testing.T) {
+		// act
+		resp, meta, err := swiftreq.Get[TestResponse](server.URL + "/redirect").DoWithResponse(context.Background())
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, "mock", resp.Name)
+		assert.Equal(t, http.StatusOK, meta.StatusCode)
+		assert.Equal(t, "application/json", meta.Header.Get("Content-Type"))
+		assert.Equal(t, server.URL+"/", meta.FinalURL.String())
+		if assert.Len(t, meta.Redirects, 2) {
+			assert.Equal(t, server.URL+"/redirect", meta.Redirects[0].String())
+			assert.Equal(t, server.URL+"/redirect/1", meta.Redirects[1].String())
+		}
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		// act
+		resp, meta, err := swiftreq.Get[TestResponse](server.URL + "/error").DoWithResponse(context.Background())
+
+		// assert
+		assert.NotNil(t, err)
+		assert.Nil(t, resp)
+		assert.Equal(t, http.StatusBadRequest, meta.StatusCode)
+		assert.Empty(t, meta.Redirects)
+	})
+
+	t.Run("Trailers", func(t *testing.T) {
+		// arrange
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Trailer", "X-Checksum")
+			_, _ = w.Write([]byte("ok"))
+			w.Header().Set("X-Checksum", "abc")
+			w.Header().Set(http.TrailerPrefix+"X-Status", "done")
+		}))
+		defer s.Close()
+
+		// act
+		resp, meta, err := swiftreq.Get[string](s.URL).DoWithResponse(context.Background())
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, "ok", *resp)
+		assert.Equal(t, "abc", meta.Trailer.Get("X-Checksum"))
+		assert.Equal(t, "done", meta.Trailer.Get("X-Status"))
+	})
+}
+
+func Test_DoStream(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		// act
+		body, err := swiftreq.Get[TestResponse](server.URL + "/text").DoStream(context.Background())
+
+		// assert
+		assert.Nil(t, err)
+		defer body.Close()
+		data, _ := io.ReadAll(body)
+		assert.NotEmpty(t, data)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		// act
+		body, err := swiftreq.Get[TestResponse](server.URL + "/error").DoStream(context.Background())
+
+		// assert
+		assert.Nil(t, body)
+		var ce *swiftreq.ClientError
+		assert.True(t, errors.As(err, &ce))
+	})
+}
+
+func Test_WithProgress(t *testing.T) {
+	// arrange
+	payload := bytes.Repeat([]byte("x"), 200_000)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)/2))
+		_, _ = w.Write(body[:len(body)/2])
+	}))
+	defer s.Close()
+
+	var mu sync.Mutex
+	var reports [][2]int64
+
+	// act
+	resp, err := swiftreq.Post[[]byte](s.URL, nil).
+		WithBytesPayload(payload, "application/octet-stream").
+		WithProgress(func(transferred, total int64) {
+			mu.Lock()
+			defer mu.Unlock()
+			reports = append(reports, [2]int64{transferred, total})
+		}).
+		Do(context.Background())
+
+	// assert
+	assert.Nil(t, err)
+	assert.Len(t, *resp, 100_000)
+	assert.Contains(t, reports, [2]int64{200_000, 200_000})
+	assert.Equal(t, [2]int64{100_000, 100_000}, reports[len(reports)-1])
+}
+
+func Test_DownloadToFile(t *testing.T) {
+	// arrange
+	payload := bytes.Repeat([]byte("0123456789"), 100_000)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/broken" {
+			w.Header().Set("Content-Length", strconv.Itoa(len(payload)))
+			_, _ = w.Write(payload[:1000])
+			return
+		}
+		_, _ = w.Write(payload)
+	}))
+	defer s.Close()
+
+	dir := t.TempDir()
+
+	t.Run("Success", func(t *testing.T) {
+		// arrange
+		path := filepath.Join(dir, "export.bin")
+
+		// act
+		n, err := swiftreq.Get[[]byte](s.URL).DownloadToFile(context.Background(), path)
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, int64(len(payload)), n)
+		data, _ := os.ReadFile(path)
+		assert.Equal(t, payload, data)
+	})
+
+	t.Run("BrokenTransfer", func(t *testing.T) {
+		// arrange
+		path := filepath.Join(dir, "broken.bin")
+
+		// act
+		_, err := swiftreq.Get[[]byte](s.URL+"/broken").DownloadToFile(context.Background(), path)
+
+		// assert
+		var ce *swiftreq.ConnectionError
+		assert.True(t, errors.As(err, &ce))
+		entries, _ := os.ReadDir(dir)
+		assert.Len(t, entries, 1)
+	})
+}
+
+func Test_Negotiate(t *testing.T) {
+	t.Run("ChallengeAnswered", func(t *testing.T) {
+		// arrange
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "Negotiate dGlja2V0" {
+				w.Header().Set("WWW-Authenticate", "Negotiate")
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			mockPostEndpoint(w, r)
+		}))
+		defer s.Close()
+
+		var spn string
+		provider := middlewares.TicketProviderFunc(func(ctx context.Context, target string) ([]byte, error) {
+			spn = target
+			return []byte("ticket"), nil
+		})
+		re := swiftreq.NewRequestExecutor(*http.DefaultClient).WithNegotiate(provider, middlewares.NegotiateOptions{})
+
+		// act
+		resp, err := swiftreq.Post[TestResponse](s.URL, TestRequest{ID: 7}).WithRequestExecutor(re).Do(context.Background())
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, 7, resp.ID)
+		assert.Equal(t, "HTTP/127.0.0.1", spn)
+	})
+
+	t.Run("EmptyResponse", func(t *testing.T) {
+		// arrange
+		provider := middlewares.TicketProviderFunc(func(ctx context.Context, target string) ([]byte, error) {
+			return []byte("ticket"), nil
+		})
+		re := swiftreq.NewRequestExecutor(*http.DefaultClient).
+			WithMiddleware(func(next middlewares.Handler) middlewares.Handler {
+				return func(r *http.Request) (*http.Response, error) {
+					return nil, nil
+				}
+			}).
+			WithNegotiate(provider, middlewares.NegotiateOptions{})
+
+		// act
+		_, err := swiftreq.Get[TestResponse](server.URL).WithRequestExecutor(re).Do(context.Background())
+
+		// assert
+		assert.ErrorContains(t, err, "returned empty response")
+	})
+}
+
+func Test_WithTokenSource(t *testing.T) {
+	t.Run("BearerAdded", func(t *testing.T) {
+		// arrange
+		re := swiftreq.NewRequestExecutor(*http.DefaultClient).
+			WithTokenSource(oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "abc"}))
+
+		// act
+		resp, err := swiftreq.Get[map[string]string](server.URL + "/headers").WithRequestExecutor(re).Do(context.Background())
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, "Bearer abc", (*resp)["Authorization"])
+	})
+}
+
+func Test_WithJWTExpiry(t *testing.T) {
+	t.Run("LifeSpanFromExp", func(t *testing.T) {
+		// arrange
+		exp := time.Now().Add(time.Hour).Unix()
+		payload := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"sub":"user","exp":%d}`, exp)))
+		jwt := "eyJhbGciOiJIUzI1NiJ9." + payload + ".c2lnbmF0dXJl"
+		authorize := middlewares.WithJWTExpiry(func() (string, time.Duration, error) {
+			return jwt, 0, nil
+		})
+
+		// act
+		token, lifeSpan, err := authorize()
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, jwt, token)
+		assert.InDelta(t, time.Hour.Seconds(), lifeSpan.Seconds(), 2)
+	})
+}
+
+func Test_SharedAuthorization(t *testing.T) {
+	t.Run("AuthenticatesOnce", func(t *testing.T) {
+		// arrange
+		var logins int
+		authorize := func() (string, time.Duration, error) {
+			logins++
+			return "shared", time.Hour, nil
+		}
+		first := swiftreq.NewRequestExecutor(*http.DefaultClient).WithSharedAuthorization("issuer|client", "Bearer", authorize)
+		second := swiftreq.NewRequestExecutor(*http.DefaultClient).WithSharedAuthorization("issuer|client", "Bearer", authorize)
+
+		// act
+		r1, err1 := swiftreq.Get[map[string]string](server.URL + "/headers").WithRequestExecutor(first).Do(context.Background())
+		r2, err2 := swiftreq.Get[map[string]string](server.URL + "/headers").WithRequestExecutor(second).Do(context.Background())
+
+		// assert
+		assert.Nil(t, err1)
+		assert.Nil(t, err2)
+		assert.Equal(t, "Bearer shared", (*r1)["Authorization"])
+		assert.Equal(t, "Bearer shared", (*r2)["Authorization"])
+		assert.Equal(t, 1, logins)
+	})
+
+	t.Run("SlowKeyDoesNotBlockOthers", func(t *testing.T) {
+		// arrange
+		release := make(chan struct{})
+		defer close(release)
+		go swiftreq.NewRequestExecutor(*http.DefaultClient).WithSharedAuthorization("slow-issuer|client", "Bearer", func() (string, time.Duration, error) {
+			<-release
+			return "slow", time.Hour, nil
+		})
+		time.Sleep(10 * time.Millisecond)
+
+		// act
+		done := make(chan struct{})
+		go func() {
+			swiftreq.NewRequestExecutor(*http.DefaultClient).WithSharedAuthorization("other-issuer|client", "Bearer", func() (string, time.Duration, error) {
+				return "other", time.Hour, nil
+			})
+			close(done)
+		}()
+
+		// assert
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("shared authorization blocked by another key")
+		}
+	})
+}
+
+func Test_TenantAuthorization(t *testing.T) {
+	t.Run("TokenPerTenant", func(t *testing.T) {
+		// arrange
+		re := swiftreq.NewRequestExecutor(*http.DefaultClient).
+			WithTenantAuthorization("Bearer", func(tenant string) (string, time.Duration, error) {
+				return "token-" + tenant, time.Hour, nil
+			})
+
+		// act
+		acme, err := swiftreq.Get[map[string]string](server.URL + "/headers").WithRequestExecutor(re).
+			Do(middlewares.ContextWithTenant(context.Background(), "acme"))
+		globex, _ := swiftreq.Get[map[string]string](server.URL + "/headers").WithRequestExecutor(re).
+			Do(middlewares.ContextWithTenant(context.Background(), "globex"))
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, "Bearer token-acme", (*acme)["Authorization"])
+		assert.Equal(t, "Bearer token-globex", (*globex)["Authorization"])
+	})
+
+	t.Run("SlowTenantDoesNotBlockOthers", func(t *testing.T) {
+		// arrange
+		release := make(chan struct{})
+		defer close(release)
+		re := swiftreq.NewRequestExecutor(*http.DefaultClient).
+			WithTenantAuthorization("Bearer", func(tenant string) (string, time.Duration, error) {
+				if tenant == "slow" {
+					<-release
+				}
+				return "token-" + tenant, time.Hour, nil
+			})
+		go swiftreq.Get[map[string]string](server.URL + "/headers").WithRequestExecutor(re).
+			Do(middlewares.ContextWithTenant(context.Background(), "slow"))
+		time.Sleep(10 * time.Millisecond)
+
+		// act
+		ctx, cancel := context.WithTimeout(middlewares.ContextWithTenant(context.Background(), "acme"), time.Second)
+		defer cancel()
+		acme, err := swiftreq.Get[map[string]string](server.URL + "/headers").WithRequestExecutor(re).Do(ctx)
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, "Bearer token-acme", (*acme)["Authorization"])
+	})
+
+	t.Run("RemovedTenantIsAuthorizedAgain", func(t *testing.T) {
+		// arrange
+		var logins atomic.Int32
+		re := swiftreq.NewRequestExecutor(*http.DefaultClient).
+			WithTenantAuthorization("Bearer", func(tenant string) (string, time.Duration, error) {
+				return fmt.Sprintf("token-%s-%d", tenant, logins.Add(1)), time.Hour, nil
+			})
+		ctx := middlewares.ContextWithTenant(context.Background(), "acme")
+		_, _ = swiftreq.Get[map[string]string](server.URL + "/headers").WithRequestExecutor(re).Do(ctx)
+
+		// act
+		re.RemoveTenantToken("acme")
+		resp, err := swiftreq.Get[map[string]string](server.URL + "/headers").WithRequestExecutor(re).Do(ctx)
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, "Bearer token-acme-2", (*resp)["Authorization"])
+		assert.Equal(t, int32(2), logins.Load())
+	})
+}
+
+func Test_TokenRefresherStop(t *testing.T) {
+	// arrange
+	tr := middlewares.NewTokenRefresher("Bearer", func() (string, time.Duration, error) {
+		return "token", time.Hour, nil
+	}, slog.Default())
+
+	// act
+	token, err := tr.Get()
+	tr.Stop()
+	tr.Stop()
+	_, stoppedErr := tr.Get()
+
+	// assert
+	assert.Nil(t, err)
+	assert.Equal(t, "token", token)
+	assert.ErrorIs(t, stoppedErr, middlewares.ErrTokenRefresherStopped)
+}
+
+func Test_JWE(t *testing.T) {
+	t.Run("EncryptedWithJWKS", func(t *testing.T) {
+		// arrange
+		key, _ := rsa.GenerateKey(rand.Reader, 2048)
+		var contentType string
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/jwks" {
+				fmt.Fprintf(w, `{"keys":[{"kty":"RSA","kid":"k1","use":"enc","n":"%s","e":"AQAB"}]}`,
+					base64.RawURLEncoding.EncodeToString(key.N.Bytes()))
+				return
+			}
+
+			contentType = r.Header.Get("Content-Type")
+			token, _ := io.ReadAll(r.Body)
+			parts := strings.Split(string(token), ".")
+			decode := func(s string) []byte { b, _ := base64.RawURLEncoding.DecodeString(s); return b }
+			cek, _ := rsa.DecryptOAEP(sha256.New(), rand.Reader, key, decode(parts[1]), nil)
+			block, _ := aes.NewCipher(cek)
+			gcm, _ := cipher.NewGCM(block)
+			plain, err := gcm.Open(nil, decode(parts[2]), append(decode(parts[3]), decode(parts[4])...), []byte(parts[0]))
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(plain)
+		}))
+		defer s.Close()
+
+		re := swiftreq.NewRequestExecutor(*http.DefaultClient).
+			WithMiddleware(middlewares.JWEMiddleware(middlewares.JWEOptions{JWKS: middlewares.NewJWKS(s.URL+"/jwks", nil)}))
+
+		// act
+		resp, err := swiftreq.Post[TestRequest](s.URL, TestRequest{ID: 3}).WithRequestExecutor(re).Do(context.Background())
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, 3, resp.ID)
+		assert.Equal(t, "application/jose", contentType)
+	})
+}
+
+func Test_JWS(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	sign := func(payload []byte) string {
+		header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"PS256","kid":"k1"}`))
+		input := header + "." + base64.RawURLEncoding.EncodeToString(payload)
+		digest := sha256.Sum256([]byte(input))
+		sig, _ := rsa.SignPSS(rand.Reader, key, crypto.SHA256, digest[:], &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		return input + "." + base64.RawURLEncoding.EncodeToString(sig)
+	}
+	body := []byte(`{"ID":5}`)
+
+	tests := []struct {
+		name      string
+		signature string
+		body      string
+		valid     bool
+	}{
+		{name: "Detached", signature: strings.Replace(sign(body), "."+base64.RawURLEncoding.EncodeToString(body)+".", "..", 1), body: string(body), valid: true},
+		{name: "Embedded", body: sign(body), valid: true},
+		{name: "Tampered", signature: strings.Replace(sign(body), "."+base64.RawURLEncoding.EncodeToString(body)+".", "..", 1), body: `{"ID":6}`},
+		{name: "Missing", body: string(body)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// arrange
+			var calls atomic.Int32
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls.Add(1)
+				w.Header().Set("Content-Type", "application/json")
+				if tt.signature != "" {
+					w.Header().Set("x-jws-signature", tt.signature)
+				}
+				w.Write([]byte(tt.body))
+			}))
+			defer s.Close()
+
+			re := swiftreq.NewRequestExecutor(*http.DefaultClient).
+				WithMiddleware(middlewares.JWSVerifyMiddleware(middlewares.JWSOptions{Key: &key.PublicKey}))
+			re.MinWaitRetry = time.Millisecond
+			re.MaxWaitRetry = time.Millisecond
+			re.WithExponentialRetry(3)
+
+			// act
+			resp, err := swiftreq.Get[TestRequest](s.URL).WithRequestExecutor(re).Do(context.Background())
+
+			// assert
+			assert.Equal(t, int32(1), calls.Load())
+			if tt.valid {
+				assert.Nil(t, err)
+				assert.Equal(t, 5, resp.ID)
+				return
+			}
+
+			var signatureErr *swiftreq.SignatureError
+			assert.True(t, errors.As(err, &signatureErr))
+		})
+	}
+}
+
+func Test_JWSElliptic(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	body := []byte(`{"ID":5}`)
+	input := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"ES256"}`)) + "." + base64.RawURLEncoding.EncodeToString(body)
+	digest := sha256.Sum256([]byte(input))
+	r, sv, _ := ecdsa.Sign(rand.Reader, key, digest[:])
+	sig := append(r.FillBytes(make([]byte, 32)), sv.FillBytes(make([]byte, 32))...)
+
+	tests := []struct {
+		name  string
+		sig   []byte
+		valid bool
+	}{
+		{name: "Valid", sig: sig, valid: true},
+		{name: "TooLong", sig: append([]byte{0}, append(sig[:32:32], append([]byte{0}, sig[32:]...)...)...)},
+		{name: "TooShort", sig: sig[:63]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// arrange
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(input + "." + base64.RawURLEncoding.EncodeToString(tt.sig)))
+			}))
+			defer s.Close()
+
+			re := swiftreq.NewRequestExecutor(*http.DefaultClient).
+				WithMiddleware(middlewares.JWSVerifyMiddleware(middlewares.JWSOptions{Key: &key.PublicKey}))
+
+			// act
+			resp, err := swiftreq.Get[TestRequest](s.URL).WithRequestExecutor(re).Do(context.Background())
+
+			// assert
+			if tt.valid {
+				assert.Nil(t, err)
+				assert.Equal(t, 5, resp.ID)
+				return
+			}
+
+			var signatureErr *swiftreq.SignatureError
+			assert.True(t, errors.As(err, &signatureErr))
+		})
+	}
+}
+
+func Test_JWKS(t *testing.T) {
+	t.Run("FailedFetchBacksOff", func(t *testing.T) {
+		// arrange
+		var fetches atomic.Int32
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fetches.Add(1)
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer s.Close()
+		jwks := middlewares.NewJWKS(s.URL, nil)
+
+		// act
+		_, _, firstErr := jwks.Key("k1", "sig")
+		_, _, secondErr := jwks.Key("k1", "sig")
+
+		// assert
+		assert.ErrorContains(t, firstErr, "returned status 503")
+		assert.ErrorContains(t, secondErr, "returned status 503")
+		assert.Equal(t, int32(1), fetches.Load())
+	})
+
+	t.Run("UnknownKeyRefetchBounded", func(t *testing.T) {
+		// arrange
+		var fetches atomic.Int32
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fetches.Add(1)
+			w.Write([]byte(`{"keys":[{"kty":"EC","kid":"k1","crv":"P-256","x":"AQ","y":"AQ"}]}`))
+		}))
+		defer s.Close()
+		jwks := middlewares.NewJWKS(s.URL, nil)
+
+		// act
+		kid, _, err := jwks.Key("k1", "sig")
+		_, _, unknownErr := jwks.Key("k2", "sig")
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, "k1", kid)
+		assert.NotNil(t, unknownErr)
+		assert.Equal(t, int32(1), fetches.Load())
+	})
+}
+
+func Test_List(t *testing.T) {
+	next := func(page int) swiftreq.NextPageFunc[int] {
+		return func(items []int, meta *swiftreq.ResponseMeta) *swiftreq.Request[[]int] {
+			if len(items) == 0 {
+				return nil
+			}
+			page++
+			return swiftreq.Get[[]int](server.URL+"/pages").WithQueryParameter("page", strconv.Itoa(page))
+		}
+	}
+
+	t.Run("AllPages", func(t *testing.T) {
+		// arrange
+		var items []int
+
+		// act
+		for item, err := range swiftreq.List(context.Background(), swiftreq.Get[[]int](server.URL+"/pages"), next(0)) {
+			assert.Nil(t, err)
+			items = append(items, item)
+		}
+
+		// assert
+		assert.Equal(t, []int{1, 2, 3, 4, 5, 6}, items)
+	})
+
+	t.Run("EarlyBreak", func(t *testing.T) {
+		// arrange
+		var items []int
+
+		// act
+		for item := range swiftreq.List(context.Background(), swiftreq.Get[[]int](server.URL+"/pages"), next(0)) {
+			items = append(items, item)
+			if item == 3 {
+				break
+			}
+		}
+
+		// assert
+		assert.Equal(t, []int{1, 2, 3}, items)
+	})
+
+	t.Run("ErrorStops", func(t *testing.T) {
+		// arrange
+		var errs int
+
+		// act
+		for _, err := range swiftreq.List(context.Background(), swiftreq.Get[[]int](server.URL+"/error"), next(0)) {
+			assert.NotNil(t, err)
+			errs++
+		}
+
+		// assert
+		assert.Equal(t, 1, errs)
+	})
+}
+
+func Test_Paginate(t *testing.T) {
+	// arrange
+	var auth []string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = append(auth, r.Header.Get("Authorization"))
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		page = max(page, 1)
+
+		if page < 3 {
+			w.Header().Add("Link", fmt.Sprintf(`</items?page=%d&size=2>; rel="next", </items?page=3&size=2>; rel="last"`, page+1))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, "[%d,%d]", page*10, page*10+1)
+	}))
+	defer s.Close()
+
+	t.Run("FollowsNextLinks", func(t *testing.T) {
+		// arrange
+		auth = nil
+		var pages [][]int
+
+		// act
+		for page, err := range swiftreq.Paginate(context.Background(), swiftreq.Get[[]int](s.URL+"/items").WithBearerToken("token"), swiftreq.PaginateOptions{}) {
+			assert.Nil(t, err)
+			pages = append(pages, page)
+		}
+
+		// assert
+		assert.Equal(t, [][]int{{10, 11}, {20, 21}, {30, 31}}, pages)
+		assert.Equal(t, []string{"Bearer token", "Bearer token", "Bearer token"}, auth)
+	})
+
+	t.Run("MaxPages", func(t *testing.T) {
+		// arrange
+		var pages int
+
+		// act
+		for _, err := range swiftreq.Paginate(context.Background(), swiftreq.Get[[]int](s.URL+"/items"), swiftreq.PaginateOptions{MaxPages: 2}) {
+			assert.Nil(t, err)
+			pages++
+		}
+
+		// assert
+		assert.Equal(t, 2, pages)
+	})
+
+	t.Run("NextLink", func(t *testing.T) {
+		// arrange
+		final, _ := url.Parse("https://api.example.com/repos?page=1")
+		meta := &swiftreq.ResponseMeta{
+			Header:   http.Header{"Link": {`<https://api.example.com/repos?page=1>; rel="prev first", <https://api.example.com/repos?page=2,3>; title="a, b"; rel="next"`}},
+			FinalURL: final,
+		}
+
+		// act
+		next, ok := swiftreq.NextLink(meta)
+
+		// assert
+		assert.True(t, ok)
+		assert.Equal(t, "https://api.example.com/repos?page=2,3", next)
+	})
+}
+
+func Test_DoAll(t *testing.T) {
+	// arrange
+	var inFlight, peak atomic.Int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+		}
+
+		if r.URL.Query().Get("fail") != "" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		select {
+		case <-time.After(20 * time.Millisecond):
+		case <-r.Context().Done():
+			return
+		}
+		_, _ = w.Write([]byte(r.URL.Query().Get("id")))
+	}))
+	defer s.Close()
+
+	get := func(query string) *swiftreq.Request[string] {
+		return swiftreq.Get[string](s.URL + "?" + query)
+	}
+
+	t.Run("ResultsInOrder", func(t *testing.T) {
+		// arrange
+		peak.Store(0)
+		var requests []*swiftreq.Request[string]
+		for i := 0; i < 8; i++ {
+			requests = append(requests, get("id="+strconv.Itoa(i)))
+		}
+
+		// act
+		results, err := swiftreq.DoAll(context.Background(), swiftreq.BatchOptions{Workers: 3}, requests...)
+
+		// assert
+		assert.Nil(t, err)
+		for i, r := range results {
+			assert.Equal(t, strconv.Itoa(i), *r.Value)
+			assert.Equal(t, http.StatusOK, r.Meta.StatusCode)
+		}
+		assert.LessOrEqual(t, peak.Load(), int32(3))
+	})
+
+	t.Run("CollectAll", func(t *testing.T) {
+		// act
+		results, err := swiftreq.DoAll(context.Background(), swiftreq.BatchOptions{}, get("id=0"), get("fail=1"), get("id=2"))
+
+		// assert
+		var se *swiftreq.ServerError
+		assert.True(t, errors.As(err, &se))
+		assert.Equal(t, "0", *results[0].Value)
+		assert.NotNil(t, results[1].Err)
+		assert.Equal(t, "2", *results[2].Value)
+	})
+
+	t.Run("FailFast", func(t *testing.T) {
+		// act
+		results, err := swiftreq.DoAll(context.Background(), swiftreq.BatchOptions{Workers: 2, FailFast: true},
+			get("fail=1"), get("id=1"), get("id=2"), get("id=3"))
+
+		// assert
+		var se *swiftreq.ServerError
+		assert.True(t, errors.As(err, &se))
+		var ce *swiftreq.CanceledError
+		assert.True(t, errors.As(results[3].Err, &ce))
+		assert.ErrorIs(t, results[3].Err, err)
+	})
+}
+
+func Test_AddCaching(t *testing.T) {
+	// arrange
+	var calls atomic.Int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		if r.URL.Path == "/error" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Call", strconv.Itoa(int(n)))
+		_, _ = w.Write([]byte(`{"ID":1,"Name":"cached"}`))
+	}))
+	defer s.Close()
+
+	re := swiftreq.NewRequestExecutor(http.Client{}).AddCaching(time.Minute)
+
+	t.Run("RepeatedReads", func(t *testing.T) {
+		// arrange
+		calls.Store(0)
+
+		// act
+		var metas []*swiftreq.ResponseMeta
+		for i := 0; i < 3; i++ {
+			resp, meta, err := swiftreq.Get[TestResponse](s.URL + "/items").WithRequestExecutor(re).DoWithResponse(context.Background())
+
+			// assert
+			assert.Nil(t, err)
+			assert.Equal(t, "cached", resp.Name)
+			metas = append(metas, meta)
+		}
+
+		// assert
+		assert.Equal(t, int32(1), calls.Load())
+		assert.Equal(t, "1", metas[2].Header.Get("X-Call"))
+	})
+
+	t.Run("StreamedHits", func(t *testing.T) {
+		// act
+		for i := 0; i < 2; i++ {
+			body, err := swiftreq.Get[any](s.URL + "/stream").WithRequestExecutor(re).DoStream(context.Background())
+			assert.Nil(t, err)
+			data, _ := io.ReadAll(body)
+			body.Close()
+
+			// assert
+			assert.Equal(t, `{"ID":1,"Name":"cached"}`, string(data))
+		}
+	})
+
+	t.Run("ErrorsNotCached", func(t *testing.T) {
+		// arrange
+		calls.Store(0)
+
+		// act
+		for i := 0; i < 2; i++ {
+			_, err := swiftreq.Get[TestResponse](s.URL + "/error").WithRequestExecutor(re).Do(context.Background())
+			assert.NotNil(t, err)
+		}
+
+		// assert
+		assert.Equal(t, int32(2), calls.Load())
+	})
+
+	t.Run("CustomKey", func(t *testing.T) {
+		// arrange
+		calls.Store(0)
+		re := swiftreq.NewRequestExecutor(http.Client{}).AddCaching(time.Minute, swiftreq.CacheKey(func(req *http.Request) string {
+			u := *req.URL
+			q := u.Query()
+			q.Del("trace")
+			u.RawQuery = q.Encode()
+			return u.String() + " " + req.Header.Get("Accept-Language")
+		}))
+
+		// act
+		for _, trace := range []string{"a", "b"} {
+			_, err := swiftreq.Get[TestResponse](s.URL + "/items?page=1&trace=" + trace).WithRequestExecutor(re).Do(context.Background())
+			assert.Nil(t, err)
+		}
+		_, err := swiftreq.Get[TestResponse](s.URL + "/items?page=1").WithRequestExecutor(re).WithHeaders(map[string]string{"Accept-Language": "fr"}).Do(context.Background())
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, int32(2), calls.Load())
+	})
+
+	t.Run("Bypass", func(t *testing.T) {
+		// arrange
+		re := swiftreq.NewRequestExecutor(http.Client{}).AddCaching(time.Minute)
+		get := func() *swiftreq.Request[TestResponse] {
+			return swiftreq.Get[TestResponse](s.URL + "/bypass").WithRequestExecutor(re)
+		}
+		_, first, _ := get().DoWithResponse(context.Background())
+
+		// act
+		_, bypassed, err := get().WithCacheBypass().DoWithResponse(context.Background())
+		_, cached, _ := get().DoWithResponse(context.Background())
+
+		// assert
+		assert.Nil(t, err)
+		assert.NotEqual(t, first.Header.Get("X-Call"), bypassed.Header.Get("X-Call"))
+		assert.Equal(t, bypassed.Header.Get("X-Call"), cached.Header.Get("X-Call"))
+	})
+
+	t.Run("Invalidate", func(t *testing.T) {
+		// arrange
+		re := swiftreq.NewRequestExecutor(http.Client{}).AddCaching(time.Minute)
+		for _, path := range []string{"/users/1", "/users/2", "/users/1/posts", "/groups/1"} {
+			_, err := swiftreq.Get[TestResponse](s.URL + path).WithRequestExecutor(re).Do(context.Background())
+			assert.Nil(t, err)
+		}
+		calls.Store(0)
+
+		// act
+		removed := re.InvalidateCache(s.URL + "/users/*")
+		for _, path := range []string{"/users/1", "/users/2", "/users/1/posts", "/groups/1"} {
+			_, err := swiftreq.Get[TestResponse](s.URL + path).WithRequestExecutor(re).Do(context.Background())
+			assert.Nil(t, err)
+		}
+
+		// assert
+		assert.Equal(t, 2, removed)
+		assert.Equal(t, int32(2), calls.Load())
+		assert.Equal(t, 0, swiftreq.NewRequestExecutor(http.Client{}).InvalidateCache("*"))
+	})
+}
+
+func Test_CacheStampede(t *testing.T) {
+	t.Run("ConcurrentMisses", func(t *testing.T) {
+		// arrange
+		var calls atomic.Int32
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			time.Sleep(50 * time.Millisecond)
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"ID":1,"Name":"cached"}`))
+		}))
+		defer s.Close()
+		re := swiftreq.NewRequestExecutor(http.Client{}).AddCaching(time.Minute)
+
+		// act
+		var wg sync.WaitGroup
+		var failures atomic.Int32
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				resp, err := swiftreq.Get[TestResponse](s.URL).WithRequestExecutor(re).Do(context.Background())
+				if err != nil || resp.Name != "cached" {
+					failures.Add(1)
+				}
+			}()
+		}
+		wg.Wait()
+
+		// assert
+		assert.Equal(t, int32(0), failures.Load())
+		assert.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("StaleWhileRefreshing", func(t *testing.T) {
+		// arrange
+		var calls atomic.Int32
+		release := make(chan struct{})
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			n := calls.Add(1)
+			if n == 2 {
+				<-release
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("X-Call", strconv.Itoa(int(n)))
+			_, _ = w.Write([]byte(`{"ID":1}`))
+		}))
+		defer s.Close()
+		re := swiftreq.NewRequestExecutor(http.Client{}).AddCaching(20*time.Millisecond, swiftreq.CacheStale(time.Minute))
+		get := func() (*swiftreq.ResponseMeta, error) {
+			_, meta, err := swiftreq.Get[TestResponse](s.URL).WithRequestExecutor(re).DoWithResponse(context.Background())
+			return meta, err
+		}
+		_, err := get()
+		assert.Nil(t, err)
+		time.Sleep(30 * time.Millisecond)
+
+		refreshed := make(chan *swiftreq.ResponseMeta)
+		go func() {
+			meta, _ := get()
+			refreshed <- meta
+		}()
+		assert.Eventually(t, func() bool { return calls.Load() == 2 }, time.Second, time.Millisecond)
+
+		// act
+		stale, err := get()
+		close(release)
+		fresh := <-refreshed
+		cached, _ := get()
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, "1", stale.Header.Get("X-Call"))
+		assert.Equal(t, "2", fresh.Header.Get("X-Call"))
+		assert.Equal(t, "2", cached.Header.Get("X-Call"))
+		assert.Equal(t, int32(2), calls.Load())
+	})
+}
+
+func Test_AddHTTPCaching(t *testing.T) {
+	// arrange
+	var calls atomic.Int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		switch r.URL.Path {
+		case "/max-age":
+			w.Header().Set("Cache-Control", "public, max-age=60")
+		case "/stale":
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.Header().Set("Age", "60")
+		case "/no-store":
+			w.Header().Set("Cache-Control", "no-store")
+		case "/no-cache":
+			w.Header().Set("Cache-Control", "no-cache")
+		case "/private":
+			w.Header().Set("Cache-Control", "private, max-age=60")
+		case "/expires":
+			w.Header().Set("Expires", time.Now().Add(time.Minute).UTC().Format(http.TimeFormat))
+		case "/expired":
+			w.Header().Set("Expires", "0")
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"ID":1,"Name":"cached"}`))
+	}))
+	defer s.Close()
+
+	fetch := func(re *swiftreq.RequestExecutor, path string, token string) {
+		req := swiftreq.Get[TestResponse](s.URL + path).WithRequestExecutor(re)
+		if token != "" {
+			req = req.WithBearerToken(token)
+		}
+		_, err := req.Do(context.Background())
+		assert.Nil(t, err)
+	}
+
+	tests := []struct {
+		path       string
+		token      string
+		defaultTTL time.Duration
+		calls      int32
+	}{
+		{path: "/max-age", calls: 1},
+		{path: "/stale", calls: 2},
+		{path: "/no-store", calls: 2},
+		{path: "/no-cache", calls: 2},
+		{path: "/private", calls: 2},
+		{path: "/private", token: "token", calls: 1},
+		{path: "/expires", calls: 1},
+		{path: "/expired", calls: 2},
+		{path: "/plain", calls: 2},
+		{path: "/plain", defaultTTL: time.Minute, calls: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.TrimPrefix(tt.path, "/"), func(t *testing.T) {
+			// arrange
+			calls.Store(0)
+			re := swiftreq.NewRequestExecutor(http.Client{}).AddHTTPCaching(tt.defaultTTL)
+
+			// act
+			fetch(re, tt.path, tt.token)
+			fetch(re, tt.path, tt.token)
+
+			// assert
+			assert.Equal(t, tt.calls, calls.Load())
+		})
+	}
+}
+
+func Test_Once(t *testing.T) {
+	t.Run("ExecutedOnce", func(t *testing.T) {
+		// arrange
+		var hits atomic.Int32
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits.Add(1)
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"ID":9}`))
+		}))
+		defer s.Close()
+
+		discovery := swiftreq.Once(swiftreq.Get[TestRequest](s.URL).WithRequestExecutor(swiftreq.NewRequestExecutor(*http.DefaultClient)))
+
+		// act
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				discovery.Do(context.Background())
+			}()
+		}
+		wg.Wait()
+		resp, err := discovery.Do(context.Background())
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, 9, resp.ID)
+		assert.Equal(t, int32(1), hits.Load())
+	})
+}
+
+func Test_Every(t *testing.T) {
+	t.Run("RunsUntilStopped", func(t *testing.T) {
+		// arrange
+		var polls atomic.Int32
+		re := swiftreq.NewRequestExecutor(*http.DefaultClient)
+		req := swiftreq.Get[TestResponse](server.URL)
+
+		// act
+		poller := re.Every(10*time.Millisecond, swiftreq.Poll(req, func(resp *TestResponse) error {
+			polls.Add(1)
+			return nil
+		}))
+		time.Sleep(100 * time.Millisecond)
+		poller.Stop()
+		stopped := polls.Load()
+		time.Sleep(30 * time.Millisecond)
+
+		// assert
+		assert.GreaterOrEqual(t, stopped, int32(3))
+		assert.Equal(t, stopped, polls.Load())
+	})
+
+	t.Run("BackoffOnFailure", func(t *testing.T) {
+		// arrange
+		var runs atomic.Int32
+		re := swiftreq.NewRequestExecutor(*http.DefaultClient)
+
+		// act
+		poller := re.Every(10*time.Millisecond, func(ctx context.Context, re *swiftreq.RequestExecutor) error {
+			runs.Add(1)
+			return errors.New("unavailable")
+		})
+		time.Sleep(100 * time.Millisecond)
+		poller.Stop()
+
+		// assert
+		assert.LessOrEqual(t, runs.Load(), int32(5))
+	})
+
+	t.Run("RequestNotRebound", func(t *testing.T) {
+		// arrange
+		polled := make(chan string, 1)
+		re := swiftreq.NewRequestExecutor(*http.DefaultClient).
+			WithMiddleware(func(next middlewares.Handler) middlewares.Handler {
+				return func(r *http.Request) (*http.Response, error) {
+					r.Header.Set("X-Poller", "true")
+					return next(r)
+				}
+			})
+		req := swiftreq.Get[map[string]string](server.URL + "/headers")
+
+		// act
+		poller := re.Every(time.Hour, swiftreq.Poll(req, func(resp *map[string]string) error {
+			polled <- (*resp)["X-Poller"]
+			return nil
+		}))
+		fromPoller := <-polled
+		poller.Stop()
+		direct, err := req.Do(context.Background())
+
+		// assert
+		assert.Equal(t, "true", fromPoller)
+		assert.Nil(t, err)
+		assert.Empty(t, (*direct)["X-Poller"])
+	})
+
+	t.Run("NonPositiveInterval", func(t *testing.T) {
+		// arrange
+		var runs atomic.Int32
+		re := swiftreq.NewRequestExecutor(*http.DefaultClient)
+
+		// act
+		poller := re.Every(0, func(ctx context.Context, re *swiftreq.RequestExecutor) error {
+			runs.Add(1)
+			return nil
+		})
+		time.Sleep(50 * time.Millisecond)
+		poller.Stop()
+
+		// assert
+		assert.Equal(t, int32(1), runs.Load())
+	})
+}
+
+func Test_Outbox(t *testing.T) {
+	t.Run("ReplayedWhenReachable", func(t *testing.T) {
+		// arrange
+		l, _ := net.Listen("tcp", "127.0.0.1:0")
+		addr := l.Addr().String()
+		l.Close()
+
+		re := swiftreq.NewRequestExecutor(*http.DefaultClient)
+		re.MinWaitRetry = 10 * time.Millisecond
+		re.MaxWaitRetry = 20 * time.Millisecond
+		re.WithOutbox(&middlewares.MemoryOutboxStore{})
+		defer re.CloseOutbox()
+
+		// act
+		_, firstErr := swiftreq.Post[TestResponse]("http://"+addr+"/post", TestRequest{ID: 1}).WithRequestExecutor(re).Do(context.Background())
+		_, secondErr := swiftreq.Post[TestResponse]("http://"+addr+"/post", TestRequest{ID: 2}).WithRequestExecutor(re).Do(context.Background())
+
+		received := make(chan int, 2)
+		l, _ = net.Listen("tcp", addr)
+		s := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var req TestRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			received <- req.ID
+		}))
+		s.Listener = l
+		s.Start()
+		defer s.Close()
+
+		// assert
+		assert.True(t, errors.Is(firstErr, middlewares.ErrQueued))
+		assert.True(t, errors.Is(secondErr, middlewares.ErrQueued))
+		for _, id := range []int{1, 2} {
+			select {
+			case got := <-received:
+				assert.Equal(t, id, got)
+			case <-time.After(2 * time.Second):
+				t.Fatal("request not replayed")
+			}
+		}
+	})
+
+	t.Run("CredentialHeadersNotStored", func(t *testing.T) {
+		// arrange
+		l, _ := net.Listen("tcp", "127.0.0.1:0")
+		addr := l.Addr().String()
+		l.Close()
+
+		dir := t.TempDir()
+		store, _ := middlewares.NewFileOutboxStore(dir)
+		re := swiftreq.NewRequestExecutor(*http.DefaultClient)
+		re.MinWaitRetry = time.Second
+		re.MaxWaitRetry = time.Second
+		re.WithOutbox(store)
+		defer re.CloseOutbox()
+
+		// act
+		_, err := swiftreq.Post[TestResponse]("http://"+addr+"/post", TestRequest{ID: 1}).
+			WithHeader("Authorization", "Bearer secret").
+			WithHeader("X-Request-Id", "req-1").
+			WithRequestExecutor(re).
+			Do(context.Background())
+
+		// assert
+		assert.True(t, errors.Is(err, middlewares.ErrQueued))
+		entry, ok, peekErr := store.Peek()
+		assert.Nil(t, peekErr)
+		assert.True(t, ok)
+		assert.Empty(t, entry.Header.Get("Authorization"))
+		assert.Equal(t, "req-1", entry.Header.Get("X-Request-Id"))
+	})
+
+	t.Run("CorruptedEntrySkipped", func(t *testing.T) {
+		// arrange
+		received := make(chan int, 1)
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost {
+				return
+			}
+			var req TestRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			received <- req.ID
+		}))
+		defer s.Close()
+
+		dir := t.TempDir()
+		_ = os.WriteFile(filepath.Join(dir, "00000000000000000001.json"), []byte("{not json"), 0o600)
+		store, _ := middlewares.NewFileOutboxStore(dir)
+		_ = store.Push(middlewares.OutboxEntry{Method: http.MethodPost, URL: s.URL + "/post", Header: http.Header{}, Body: []byte(`{"ID":7}`)})
+		re := swiftreq.NewRequestExecutor(*http.DefaultClient).WithOutbox(store)
+		defer re.CloseOutbox()
+
+		// act
+		_, _ = swiftreq.Get[TestResponse](s.URL + "/get").WithRequestExecutor(re).Do(context.Background())
+
+		// assert
+		select {
+		case got := <-received:
+			assert.Equal(t, 7, got)
+		case <-time.After(2 * time.Second):
+			t.Fatal("request after the corrupted entry not replayed")
+		}
+		_, statErr := os.Stat(filepath.Join(dir, "00000000000000000001.json.corrupted"))
+		assert.Nil(t, statErr)
+	})
+
+	t.Run("EmptyResponseDropped", func(t *testing.T) {
+		// arrange
+		store := &middlewares.MemoryOutboxStore{}
+		_ = store.Push(middlewares.OutboxEntry{Method: http.MethodPost, URL: server.URL + "/post", Header: http.Header{}})
+		re := swiftreq.NewRequestExecutor(*http.DefaultClient).
+			WithMiddleware(func(next middlewares.Handler) middlewares.Handler {
+				return func(r *http.Request) (*http.Response, error) {
+					return nil, nil
+				}
+			}).
+			WithOutbox(store)
+		defer re.CloseOutbox()
+
+		// act
+		_, _ = swiftreq.Get[TestResponse](server.URL).WithRequestExecutor(re).Do(context.Background())
+
+		// assert
+		assert.Eventually(t, func() bool {
+			_, pending, _ := store.Peek()
+			return !pending
+		}, 2*time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("CloseStopsReplay", func(t *testing.T) {
+		// arrange
+		l, _ := net.Listen("tcp", "127.0.0.1:0")
+		addr := l.Addr().String()
+		l.Close()
+
+		store := &middlewares.MemoryOutboxStore{}
+		re := swiftreq.NewRequestExecutor(*http.DefaultClient)
+		re.MinWaitRetry = time.Hour
+		re.MaxWaitRetry = time.Hour
+		re.WithOutbox(store)
+
+		_, err := swiftreq.Post[TestResponse]("http://"+addr+"/post", TestRequest{ID: 1}).WithRequestExecutor(re).Do(context.Background())
+
+		// act
+		closed := make(chan struct{})
+		go func() {
+			re.CloseOutbox()
+			close(closed)
+		}()
+
+		// assert
+		assert.True(t, errors.Is(err, middlewares.ErrQueued))
+		select {
+		case <-closed:
+		case <-time.After(2 * time.Second):
+			t.Fatal("replay not stopped")
+		}
+		_, pending, _ := store.Peek()
+		assert.True(t, pending)
+	})
+}
+
+func Test_WithBandwidthLimit(t *testing.T) {
+	t.Run("ResponseThrottled", func(t *testing.T) {
+		// arrange
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write(make([]byte, 20000))
+		}))
+		defer s.Close()
+
+		re := swiftreq.NewRequestExecutor(*http.DefaultClient).WithBandwidthLimit(10000)
+
+		// act
+		start := time.Now()
+		resp, err := swiftreq.Get[[]byte](s.URL).WithRequestExecutor(re).Do(context.Background())
+
+		// assert
+		assert.Nil(t, err)
+		assert.Len(t, *resp, 20000)
+		assert.GreaterOrEqual(t, time.Since(start), 800*time.Millisecond)
+	})
+}
+
+func Test_WithTransportTimeouts(t *testing.T) {
+	t.Run("ResponseHeaderTimeout", func(t *testing.T) {
+		// arrange
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(200 * time.Millisecond)
+		}))
+		defer s.Close()
+
+		re := swiftreq.NewRequestExecutor(http.Client{}).
+			WithTransportTimeouts(swiftreq.TransportTimeouts{ResponseHeader: 50 * time.Millisecond})
+
+		// act
+		_, err := swiftreq.Get[string](s.URL).WithRequestExecutor(re).Do(context.Background())
+
+		// assert
+		var timeoutErr *swiftreq.TimeoutError
+		assert.True(t, errors.As(err, &timeoutErr))
+	})
+}
+
+func Test_WithMaxRedirects(t *testing.T) {
+	tests := []struct {
+		name       string
+		max        int
+		statusCode int
+		failed     bool
+	}{
+		{name: "NotFollowed", max: 0, statusCode: http.StatusFound},
+		{name: "Exceeded", max: 1, failed: true},
+		{name: "Followed", max: 2, statusCode: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// arrange
+			re := swiftreq.NewRequestExecutor(*http.DefaultClient).WithMaxRedirects(tt.max)
+
+			// act
+			_, meta, err := swiftreq.Get[[]byte](server.URL + "/redirect").WithRequestExecutor(re).DoWithResponse(context.Background())
+
+			// assert
+			if tt.failed {
+				assert.NotNil(t, err)
+				return
+			}
+
+			assert.Nil(t, err)
+			assert.Equal(t, tt.statusCode, meta.StatusCode)
+		})
+	}
+}
+
+func Test_WithKeepAlives(t *testing.T) {
+	t.Run("Disabled", func(t *testing.T) {
+		// arrange
+		var connections atomic.Int32
+		s := httptest.NewUnstartedServer(http.HandlerFunc(mockGetEndpoint))
+		s.Config.ConnState = func(c net.Conn, state http.ConnState) {
+			if state == http.StateNew {
+				connections.Add(1)
+			}
+		}
+		s.Start()
+		defer s.Close()
+
+		re := swiftreq.NewRequestExecutor(http.Client{}).WithKeepAlives(false)
+
+		// act
+		for i := 0; i < 3; i++ {
+			swiftreq.Get[TestResponse](s.URL).WithRequestExecutor(re).Do(context.Background())
+		}
+
+		// assert
+		assert.Equal(t, int32(3), connections.Load())
+	})
+}
+
+func Test_Warmup(t *testing.T) {
+	t.Run("ConnectionReused", func(t *testing.T) {
+		// arrange
+		var connections atomic.Int32
+		s := httptest.NewUnstartedServer(http.HandlerFunc(mockGetEndpoint))
+		s.Config.ConnState = func(c net.Conn, state http.ConnState) {
+			if state == http.StateNew {
+				connections.Add(1)
+			}
+		}
+		s.StartTLS()
+		defer s.Close()
+
+		re := swiftreq.NewRequestExecutor(*s.Client())
+
+		// act
+		warmupErr := re.Warmup(context.Background(), s.URL)
+		_, err := swiftreq.Get[TestResponse](s.URL).WithRequestExecutor(re).Do(context.Background())
+
+		// assert
+		assert.Nil(t, warmupErr)
+		assert.Nil(t, err)
+		assert.Equal(t, int32(1), connections.Load())
+	})
+
+	t.Run("UnreachableHost", func(t *testing.T) {
+		// arrange
+		l, _ := net.Listen("tcp", "127.0.0.1:0")
+		addr := l.Addr().String()
+		l.Close()
+
+		re := swiftreq.NewRequestExecutor(http.Client{})
+
+		// act
+		err := re.Warmup(context.Background(), addr)
+
+		// assert
+		assert.NotNil(t, err)
+	})
+}
+
+func Test_StatsD(t *testing.T) {
+	t.Run("DogStatsDTags", func(t *testing.T) {
+		// arrange
+		agent, _ := net.ListenPacket("udp", "127.0.0.1:0")
+		defer agent.Close()
+
+		statsd, err := middlewares.StatsDMiddleware(middlewares.StatsDOptions{
+			Address:   agent.LocalAddr().String(),
+			DogStatsD: true,
+			Tags:      []string{"env:test"},
+		})
+		re := swiftreq.NewRequestExecutor(*http.DefaultClient).WithMiddleware(statsd)
+
+		// act
+		swiftreq.Get[TestResponse](server.URL).WithRequestExecutor(re).Do(context.Background())
+		packet := make([]byte, 1024)
+		agent.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, readErr := agent.ReadFrom(packet)
+
+		// assert
+		assert.Nil(t, err)
+		assert.Nil(t, readErr)
+		assert.Contains(t, string(packet[:n]), "swiftreq.request.count:1|c|#env:test,method:GET,host:127.0.0.1,status_code:200")
+		assert.Contains(t, string(packet[:n]), "swiftreq.request.duration:")
+	})
+}
+
+func Test_WithExpvar(t *testing.T) {
+	t.Run("CountersPublished", func(t *testing.T) {
+		// arrange
+		re := swiftreq.NewRequestExecutor(*http.DefaultClient).WithExpvar("swiftreq_test")
+
+		// act
+		swiftreq.Get[TestResponse](server.URL).WithRequestExecutor(re).Do(context.Background())
+		swiftreq.Get[TestResponse](server.URL + "/error").WithRequestExecutor(re).Do(context.Background())
+
+		// assert
+		vars := expvar.Get("swiftreq_test").(*expvar.Map)
+		assert.Equal(t, "2", vars.Get("requests").String())
+		assert.Equal(t, "1", vars.Get("status_2xx").String())
+		assert.Equal(t, "1", vars.Get("status_4xx").String())
+		assert.Equal(t, "0", vars.Get("in_flight").String())
+	})
+}
+
+func Test_WithDebugDumps(t *testing.T) {
+	t.Run("RedactedDump", func(t *testing.T) {
+		// arrange
+		re := swiftreq.NewRequestExecutor(*http.DefaultClient).WithDebugDumps(true)
+
+		// act
+		_, err := swiftreq.Post[TestResponse](server.URL+"/error", TestRequest{ID: 4}).
+			WithRequestExecutor(re).
+			WithHeader("Authorization", "Bearer secret").
+			Do(context.Background())
+
+		// assert
+		var swiftErr *swiftreq.Error
+		assert.True(t, errors.As(err, &swiftErr))
+		dump := swiftErr.Dump()
+		assert.Contains(t, dump, "POST "+server.URL+"/error")
+		assert.Contains(t, dump, "Authorization: [REDACTED]")
+		assert.NotContains(t, dump, "secret")
+		assert.Contains(t, dump, `{"ID":4,"Type":""}`)
+		assert.Contains(t, dump, strconv.Itoa(swiftErr.StatusCode))
+	})
+
+	t.Run("DisabledByDefault", func(t *testing.T) {
+		// arrange
+		re := swiftreq.NewRequestExecutor(*http.DefaultClient)
+
+		// act
+		_, err := swiftreq.Get[TestResponse](server.URL + "/error").WithRequestExecutor(re).Do(context.Background())
+
+		// assert
+		var swiftErr *swiftreq.Error
+		assert.True(t, errors.As(err, &swiftErr))
+		assert.Empty(t, swiftErr.Dump())
+	})
+}
+
+func Test_OnErrorReport(t *testing.T) {
+	t.Run("ReportedAfterRetries", func(t *testing.T) {
+		// arrange
+		var reports []*swiftreq.Error
+		re := swiftreq.NewRequestExecutor(*http.DefaultClient)
+		re.MinWaitRetry = time.Millisecond
+		re.MaxWaitRetry = time.Millisecond
+		re.WithExponentialRetry(2).
+			OnErrorReport(func(ctx context.Context, err *swiftreq.Error) {
+				reports = append(reports, err)
+			})
+
+		// act
+		_, err := swiftreq.Get[TestResponse](server.URL + "/server-error").WithRequestExecutor(re).Do(context.Background())
+
+		// assert
+		assert.NotNil(t, err)
+		if assert.Len(t, reports, 1) {
+			assert.Equal(t, http.MethodGet, reports[0].Method)
+			assert.Equal(t, server.URL+"/server-error", reports[0].URL)
+		}
+	})
+
+	t.Run("NotReportedOnSuccess", func(t *testing.T) {
+		// arrange
+		reported := false
+		re := swiftreq.NewRequestExecutor(*http.DefaultClient).
+			OnErrorReport(func(ctx context.Context, err *swiftreq.Error) { reported = true })
+
+		// act
+		_, err := swiftreq.Get[TestResponse](server.URL).WithRequestExecutor(re).Do(context.Background())
+
+		// assert
+		assert.Nil(t, err)
+		assert.False(t, reported)
+	})
+}
+
+func Test_WithResponseHook(t *testing.T) {
+	t.Run("Normalized", func(t *testing.T) {
+		// arrange
+		req := swiftreq.Get[TestResponse](server.URL).
+			WithResponseHook(func(ctx context.Context, resp *TestResponse, meta *swiftreq.ResponseMeta) error {
+				resp.Name = strings.ToUpper(resp.Name) + strconv.Itoa(meta.StatusCode)
+				return nil
+			})
+
+		// act
+		resp, err := req.Do(context.Background())
+
+		// assert
+		assert.Nil(t, err)
+		assert.True(t, strings.HasSuffix(resp.Name, "200"))
+	})
+
+	t.Run("ErrorFailsCall", func(t *testing.T) {
+		// arrange
+		invariant := errors.New("invariant broken")
+		req := swiftreq.Get[TestResponse](server.URL).
+			WithResponseHook(func(ctx context.Context, resp *TestResponse, meta *swiftreq.ResponseMeta) error {
+				return invariant
+			})
+
+		// act
+		resp, err := req.Do(context.Background())
+
+		// assert
+		var validationErr *swiftreq.ValidationError
+		assert.Nil(t, resp)
+		assert.True(t, errors.As(err, &validationErr))
+		assert.True(t, errors.Is(err, invariant))
+	})
+}
+
+func Test_WithNamingStrategy(t *testing.T) {
+	type address struct {
+		StreetName string
+	}
+	type user struct {
+		UserID    int
+		FirstName string
+		Address   address
+		Scores    map[string]int
+		Legacy    string `json:"legacyKey"`
+	}
+
+	t.Run("SnakeCase", func(t *testing.T) {
+		// arrange
+		var sent map[string]any
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			json.NewDecoder(r.Body).Decode(&sent)
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"user_id":7,"first_name":"Ann","address":{"street_name":"Main"},"scores":{"math_test":10},"legacyKey":"kept"}`))
+		}))
+		defer s.Close()
+
+		// act
+		resp, err := swiftreq.Post[user](s.URL, user{UserID: 7, FirstName: "Ann", Legacy: "kept"}).
+			WithNamingStrategy(swiftreq.SnakeCase).
+			Do(context.Background())
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, user{UserID: 7, FirstName: "Ann", Address: address{StreetName: "Main"}, Scores: map[string]int{"math_test": 10}, Legacy: "kept"}, *resp)
+		assert.Contains(t, sent, "user_id")
+		assert.Contains(t, sent, "first_name")
+		assert.Contains(t, sent, "legacyKey")
+	})
+}
+
+func Test_NamingStrategies(t *testing.T) {
+	tests := []struct {
+		field string
+		snake string
+		kebab string
+		camel string
+	}{
+		{field: "UserID", snake: "user_id", kebab: "user-id", camel: "userId"},
+		{field: "HTTPServer", snake: "http_server", kebab: "http-server", camel: "httpServer"},
+		{field: "Name", snake: "name", kebab: "name", camel: "name"},
+		{field: "Address2Line", snake: "address2_line", kebab: "address2-line", camel: "address2Line"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.field, func(t *testing.T) {
+			// assert
+			assert.Equal(t, tt.snake, swiftreq.SnakeCase(tt.field))
+			assert.Equal(t, tt.kebab, swiftreq.KebabCase(tt.field))
+			assert.Equal(t, tt.camel, swiftreq.CamelCase(tt.field))
+		})
+	}
+}
+
+func Test_WithTimeLayouts(t *testing.T) {
+	t.Run("CustomLayouts", func(t *testing.T) {
+		// arrange
+		type event struct {
+			Created time.Time
+			Updated *time.Time
+			History []time.Time
+		}
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"Created":"2024-03-01 10:30:00","Updated":1709289000000,"History":["2024-03-01T10:30:00Z"]}`))
+		}))
+		defer s.Close()
+
+		// act
+		resp, err := swiftreq.Get[event](s.URL).WithTimeLayouts("2006-01-02 15:04:05", swiftreq.EpochMillis).Do(context.Background())
+		_, defaultErr := swiftreq.Get[event](s.URL).Do(context.Background())
+
+		// assert
+		expected := time.Date(2024, 3, 1, 10, 30, 0, 0, time.UTC)
+		assert.NotNil(t, defaultErr)
+		assert.Nil(t, err)
+		assert.True(t, expected.Equal(resp.Created))
+		assert.True(t, expected.Equal(*resp.Updated))
+		assert.True(t, expected.Equal(resp.History[0]))
+	})
+}
+
+func Test_WithUseNumber(t *testing.T) {
+	t.Run("LargeIntegerKept", func(t *testing.T) {
+		// arrange
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"id":9007199254740993}`))
+		}))
+		defer s.Close()
+
+		// act
+		resp, err := swiftreq.Get[map[string]any](s.URL).WithUseNumber().Do(context.Background())
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, json.Number("9007199254740993"), (*resp)["id"])
+	})
+}
+
+func Test_HTMLDecoding(t *testing.T) {
+	type component struct {
+		Name   string `html:".name"`
+		Status string `html:",attr=data-status"`
+	}
+	type statusPage struct {
+		Title      string      `html:"h1"`
+		Updated    int         `html:"#updated"`
+		Components []component `html:".component"`
+		Links      []string    `html:"a,attr=href"`
+		Missing    *string     `html:".missing"`
+	}
+	page := `<html><body><h1> Service status </h1><span id="updated">42</span>
+		<div class="component" data-status="up"><span class="name">API</span></div>
+		<div class="component" data-status="down"><span class="name">DB</span></div>
+		<a href="/a">a</a><a href="/b">b</a></body></html>`
+
+	t.Run("SelectorTags", func(t *testing.T) {
+		// arrange
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte(page))
+		}))
+		defer s.Close()
+
+		// act
+		resp, err := swiftreq.Get[statusPage](s.URL).Do(context.Background())
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, statusPage{
+			Title:      "Service status",
+			Updated:    42,
+			Components: []component{{Name: "API", Status: "up"}, {Name: "DB", Status: "down"}},
+			Links:      []string{"/a", "/b"},
+		}, *resp)
+	})
+
+	t.Run("AcceptHTMLString", func(t *testing.T) {
+		// arrange
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(page))
+		}))
+		defer s.Close()
+
+		// act
+		resp, err := swiftreq.Get[string](s.URL).AcceptHTML().Do(context.Background())
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, page, *resp)
+	})
+}
+
+func Test_AsCurl(t *testing.T) {
+	t.Run("Post", func(t *testing.T) {
+		// arrange
+		req := swiftreq.Post[TestResponse]("https://api.example.com/posts", TestRequest{ID: 1, Type: "it's"}).
+			WithHeader("X-Request-ID", "abc")
+
+		// act
+		cmd := req.AsCurl()
+
+		// assert
+		assert.Equal(t, `curl -X POST 'https://api.example.com/posts' \
+  -H 'Content-Type: application/json' \
+  -H 'X-Request-Id: abc' \
+  --data-binary '{"ID":1,"Type":"it'\''s"}'`, cmd)
+	})
+
+	t.Run("Get", func(t *testing.T) {
+		// act
+		cmd := swiftreq.Get[TestResponse]("https://api.example.com/posts").WithQueryParameters(map[string]string{"page": "2"}).AsCurl()
+
+		// assert
+		assert.Equal(t, `curl 'https://api.example.com/posts?page=2' \
+  -H 'Content-Type: application/json'`, cmd)
+	})
+
+	t.Run("StreamedBody", func(t *testing.T) {
+		// act
+		cmd := swiftreq.Put[TestResponse]("https://api.example.com/blob", nil).
+			WithRawPayload(io.MultiReader(strings.NewReader("data")), "application/octet-stream").
+			AsCurl()
+
+		// assert
+		assert.True(t, strings.HasSuffix(cmd, "--data-binary @-"))
+		assert.Contains(t, cmd, "-H 'Content-Type: application/octet-stream'")
+	})
+
+	t.Run("BinaryBody", func(t *testing.T) {
+		// act
+		cmd := swiftreq.Post[TestResponse]("https://api.example.com/blob", nil).
+			WithBytesPayload([]byte{0, 'a', '\'', 0xff}, "application/octet-stream").
+			AsCurl()
+
+		// assert
+		assert.True(t, strings.HasSuffix(cmd, `--data-binary $'\x00a\'\xff'`))
+	})
+
+	t.Run("InvalidURL", func(t *testing.T) {
+		// act
+		cmd := swiftreq.Get[TestResponse]("not a url").AsCurl()
+
+		// assert
+		assert.True(t, strings.HasPrefix(cmd, "# "))
+	})
+}
+
+func Test_RawPayloads(t *testing.T) {
+	// arrange
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		_, _ = w.Write([]byte(r.Header.Get("Content-Type") + " " + string(body)))
+	}))
+	defer s.Close()
+
+	t.Run("WithRawPayload", func(t *testing.T) {
+		// act
+		resp, err := swiftreq.Post[string](s.URL, nil).
+			WithRawPayload(strings.NewReader("name,age\nmock,1"), "text/csv").
+			Do(context.Background())
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, "text/csv name,age\nmock,1", *resp)
+	})
+
+	t.Run("WithBytesPayload", func(t *testing.T) {
+		// arrange
+		req := swiftreq.Put[string](s.URL, TestResponse{ID: 1}).
+			WithBytesPayload([]byte{0xca, 0xfe}, "application/octet-stream")
+
+		// act
+		first, err := req.Do(context.Background())
+		assert.Nil(t, err)
+		second, err := req.Do(context.Background())
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, "application/octet-stream \xca\xfe", *first)
+		assert.Equal(t, *first, *second)
+	})
+}
+
+func Test_WithMultipartPayload(t *testing.T) {
+	t.Run("FormAndFiles", func(t *testing.T) {
+		// arrange
+		path := filepath.Join(t.TempDir(), "avatar.png")
+		os.WriteFile(path, []byte("png"), 0o600)
+
+		type upload struct {
+			Title    string    `form:"title"`
+			Tags     []string  `form:"tag"`
+			Size     int       `form:"size"`
+			Document io.Reader `file:"document,filename=report.pdf"`
+			Avatar   string    `file:"avatar"`
+		}
+
+		var form *multipart.Form
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.ParseMultipartForm(1 << 20)
+			form = r.MultipartForm
+		}))
+		defer s.Close()
+
+		// act
+		_, err := swiftreq.Post[[]byte](s.URL, nil).
+			WithMultipartPayload(upload{Title: "Q1", Tags: []string{"a", "b"}, Size: 3, Document: strings.NewReader("pdf"), Avatar: path}).
+			Do(context.Background())
+
+		// assert
+		assert.Nil(t, err)
+		if assert.NotNil(t, form) {
+			assert.Equal(t, []string{"Q1"}, form.Value["title"])
+			assert.Equal(t, []string{"a", "b"}, form.Value["tag"])
+			assert.Equal(t, []string{"3"}, form.Value["size"])
+			assert.Equal(t, "report.pdf", form.File["document"][0].Filename)
+			assert.Equal(t, "avatar.png", form.File["avatar"][0].Filename)
+		}
+	})
+
+	t.Run("EmptyFilesSkipped", func(t *testing.T) {
+		// arrange
+		type upload struct {
+			Title    string    `form:"title"`
+			Document io.Reader `file:"document"`
+			Data     []byte    `file:"data"`
+			Empty    []byte    `file:"empty"`
+			Avatar   string    `file:"avatar"`
+		}
+
+		var form *multipart.Form
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.ParseMultipartForm(1 << 20)
+			form = r.MultipartForm
+		}))
+		defer s.Close()
+
+		// act
+		_, err := swiftreq.Post[[]byte](s.URL, nil).
+			WithMultipartPayload(upload{Title: "Q1", Empty: []byte{}}).
+			Do(context.Background())
+
+		// assert
+		assert.Nil(t, err)
+		if assert.NotNil(t, form) {
+			assert.Equal(t, []string{"Q1"}, form.Value["title"])
+			assert.Empty(t, form.File)
+		}
+	})
+}
+
+func Test_WithRequestCompression(t *testing.T) {
+	payload := TestRequest{ID: 1, Type: strings.Repeat("x", 2048)}
+
+	decodeBody := func(r *http.Request) (TestRequest, error) {
+		var body io.Reader = r.Body
+		switch r.Header.Get("Content-Encoding") {
+		case "zstd":
+			zr, err := zstd.NewReader(r.Body)
+			if err != nil {
+				return TestRequest{}, err
+			}
+			defer zr.Close()
+			body = zr
+		case "gzip":
+			gr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				return TestRequest{}, err
+			}
+			body = gr
+		}
+
+		var req TestRequest
+		err := json.NewDecoder(body).Decode(&req)
+		return req, err
+	}
+
+	t.Run("Zstd", func(t *testing.T) {
+		// arrange
+		var encoding string
+		var received TestRequest
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			encoding = r.Header.Get("Content-Encoding")
+			received, _ = decodeBody(r)
+		}))
+		defer s.Close()
+
+		re := swiftreq.NewRequestExecutor(*http.DefaultClient).WithRequestCompression(middlewares.CompressionOptions{})
+
+		// act
+		_, err := swiftreq.Post[[]byte](s.URL, payload).WithRequestExecutor(re).Do(context.Background())
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, "zstd", encoding)
+		assert.Equal(t, payload, received)
+	})
+
+	t.Run("NegotiatedOn415", func(t *testing.T) {
+		// arrange
+		var encodings []string
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			encodings = append(encodings, r.Header.Get("Content-Encoding"))
+			if r.Header.Get("Content-Encoding") != "gzip" {
+				w.Header().Set("Accept-Encoding", "gzip")
+				w.WriteHeader(http.StatusUnsupportedMediaType)
+				return
+			}
+			decodeBody(r)
+		}))
+		defer s.Close()
+
+		re := swiftreq.NewRequestExecutor(*http.DefaultClient).WithRequestCompression(middlewares.CompressionOptions{})
+
+		// act
+		_, firstErr := swiftreq.Post[[]byte](s.URL, payload).WithRequestExecutor(re).Do(context.Background())
+		_, secondErr := swiftreq.Post[[]byte](s.URL, payload).WithRequestExecutor(re).Do(context.Background())
+
+		// assert
+		assert.Nil(t, firstErr)
+		assert.Nil(t, secondErr)
+		assert.Equal(t, []string{"zstd", "gzip", "gzip"}, encodings)
+	})
+}
+
+func Test_Patch(t *testing.T) {
+	type post struct {
+		Title string
+		Tags  []string
+		Draft bool
+		Meta  map[string]string
+	}
+	old := post{Title: "a", Tags: []string{"x"}, Draft: true, Meta: map[string]string{"k": "v", "gone": "1"}}
+	updated := post{Title: "b", Tags: []string{"x"}, Draft: true, Meta: map[string]string{"k": "w"}}
+
+	t.Run("JSONPatchContentType", func(t *testing.T) {
+		// arrange
+		var contentType string
+		var ops []map[string]any
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			contentType = r.Header.Get("Content-Type")
+			json.NewDecoder(r.Body).Decode(&ops)
+		}))
+		defer s.Close()
+
+		patch := swiftreq.JSONPatch{}.Replace("/Title", "b").Remove("/Draft").Add(swiftreq.JSONPointer("Meta", "a/b"), nil)
+
+		// act
+		_, err := swiftreq.Patch[[]byte](s.URL, patch).Do(context.Background())
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, "application/json-patch+json", contentType)
+		assert.Equal(t, []map[string]any{
+			{"op": "replace", "path": "/Title", "value": "b"},
+			{"op": "remove", "path": "/Draft"},
+			{"op": "add", "path": "/Meta/a~1b", "value": nil},
+		}, ops)
+	})
+
+	t.Run("DiffMergePatch", func(t *testing.T) {
+		// act
+		patch, err := swiftreq.DiffMergePatch(old, updated)
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, swiftreq.MergePatch{"Title": "b", "Meta": swiftreq.MergePatch{"k": "w", "gone": nil}}, patch)
+		assert.Equal(t, "application/merge-patch+json", patch.ContentType())
+	})
+
+	t.Run("DiffJSONPatch", func(t *testing.T) {
+		// act
+		patch, err := swiftreq.DiffJSONPatch(old, updated)
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, swiftreq.JSONPatch{}.Remove("/Meta/gone").Replace("/Meta/k", "w").Replace("/Title", "b"), patch)
+	})
+}
+
+func Test_GetModifyPut(t *testing.T) {
+	t.Run("RetriedOnConflict", func(t *testing.T) {
+		// arrange
+		var mu sync.Mutex
+		version, counter := 1, 0
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			switch r.Method {
+			case http.MethodGet:
+				w.Header().Set("ETag", strconv.Itoa(version))
+				w.Header().Set("Content-Type", "application/json")
+				fmt.Fprintf(w, `{"ID":%d}`, counter)
+				if version == 1 {
+					version, counter = 2, 10 // concurrent update after the first read
+				}
+			case http.MethodPut:
+				if r.Header.Get("If-Match") != strconv.Itoa(version) {
+					w.WriteHeader(http.StatusPreconditionFailed)
+					return
+				}
+				var body TestRequest
+				json.NewDecoder(r.Body).Decode(&body)
+				counter = body.ID
+				w.WriteHeader(http.StatusNoContent)
+			}
+		}))
+		defer s.Close()
+
+		modifications := 0
+
+		// act
+		resp, err := swiftreq.GetModifyPut(context.Background(), swiftreq.NewRequestExecutor(*http.DefaultClient), s.URL, 2, func(r *TestRequest) error {
+			modifications++
+			r.ID++
+			return nil
+		})
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, 11, resp.ID)
+		assert.Equal(t, 11, counter)
+		assert.Equal(t, 2, modifications)
+	})
+}
+
+func Test_Exists(t *testing.T) {
+	tests := []struct {
+		name   string
+		path   string
+		exists bool
+		err    bool
+	}{
+		{name: "Found", path: "/", exists: true},
+		{name: "NotFound", path: "/missing"},
+		{name: "Failure", path: "/server-error", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// act
+			exists, err := swiftreq.Exists(context.Background(), server.URL+tt.path)
+
+			// assert
+			assert.Equal(t, tt.exists, exists)
+			assert.Equal(t, tt.err, err != nil)
+		})
+	}
+
+	t.Run("NotFoundNotReported", func(t *testing.T) {
+		// arrange
+		previous := swiftreq.Default()
+		defer swiftreq.SetDefault(previous)
+
+		var reported []int
+		swiftreq.SetDefault(swiftreq.NewRequestExecutor(*http.DefaultClient).
+			OnErrorReport(func(ctx context.Context, err *swiftreq.Error) { reported = append(reported, err.StatusCode) }))
+
+		// act
+		exists, err := swiftreq.Exists(context.Background(), server.URL+"/missing")
+		_, failureErr := swiftreq.Exists(context.Background(), server.URL+"/server-error")
+
+		// assert
+		assert.False(t, exists)
+		assert.Nil(t, err)
+		assert.NotNil(t, failureErr)
+		assert.Equal(t, []int{http.StatusInternalServerError}, reported)
+	})
+}
+
+func Test_Ping(t *testing.T) {
+	t.Run("Reachable", func(t *testing.T) {
+		// arrange
+		s := httptest.NewTLSServer(http.HandlerFunc(mockGetEndpoint))
+		defer s.Close()
+
+		re := swiftreq.NewRequestExecutor(*s.Client())
+
+		// act
+		result, err := re.Ping(context.Background(), s.URL)
+
+		// assert
+		assert.Nil(t, err)
+		assert.True(t, result.Reachable)
+		assert.Equal(t, http.StatusOK, result.StatusCode)
+		assert.False(t, result.Reused)
+		assert.Greater(t, result.Connect, time.Duration(0))
+		assert.Greater(t, result.TLS, time.Duration(0))
+		assert.GreaterOrEqual(t, result.Total, result.TTFB)
+	})
+
+	t.Run("Unreachable", func(t *testing.T) {
+		// arrange
+		l, _ := net.Listen("tcp", "127.0.0.1:0")
+		addr := l.Addr().String()
+		l.Close()
+
+		// act
+		result, err := swiftreq.NewRequestExecutor(http.Client{}).Ping(context.Background(), "http://"+addr)
+
+		// assert
+		var connErr *swiftreq.ConnectionError
+		assert.True(t, errors.As(err, &connErr))
+		assert.False(t, result.Reachable)
+	})
+}
+
+func Test_RecordHAR(t *testing.T) {
+	// arrange
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/binary" {
+			w.Header().Set("Content-Type", "application/octet-stream")
+			_, _ = w.Write([]byte{0xff, 0xfe})
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write(body)
+	}))
+	defer s.Close()
+
+	recorder := swiftreq.NewHARRecorder(swiftreq.HARRecorderOptions{})
+	re := swiftreq.NewRequestExecutor(http.Client{}).RecordHAR(recorder)
+	path := filepath.Join(t.TempDir(), "session.har")
+
+	// act
+	_, err := swiftreq.Post[TestResponse](s.URL+"/items?tag=a", TestResponse{ID: 1, Name: "item"}).
+		WithRequestExecutor(re).
+		WithBearerToken("secret").
+		Do(context.Background())
+	assert.Nil(t, err)
+	stream, err := swiftreq.Get[any](s.URL + "/binary").WithRequestExecutor(re).DoStream(context.Background())
+	assert.Nil(t, err)
+	_, _ = io.ReadAll(stream)
+	stream.Close()
+	_, err = swiftreq.Get[TestResponse]("http://127.0.0.1:1/down").WithRequestExecutor(re).Do(context.Background())
+	assert.NotNil(t, err)
+	assert.Nil(t, recorder.Save(path))
+	har, err := swiftreq.LoadHAR(path)
+
+	// assert
+	assert.Nil(t, err)
+	assert.Equal(t, "1.2", har.Log.Version)
+	assert.Len(t, har.Log.Entries, 3)
+
+	post := har.Log.Entries[0]
+	assert.Equal(t, "POST", post.Request.Method)
+	assert.Equal(t, []swiftreq.HARNameValue{{Name: "tag", Value: "a"}}, post.Request.QueryString)
+	assert.Contains(t, post.Request.Headers, swiftreq.HARNameValue{Name: "Authorization", Value: "[REDACTED]"})
+	assert.JSONEq(t, `{"ID":1,"Name":"item"}`, post.Request.PostData.Text)
+	assert.Equal(t, http.StatusCreated, post.Response.Status)
+	assert.JSONEq(t, `{"ID":1,"Name":"item"}`, post.Response.Content.Text)
+
+	binary := har.Log.Entries[1]
+	assert.Equal(t, "base64", binary.Response.Content.Encoding)
+	assert.Equal(t, "//4=", binary.Response.Content.Text)
+	assert.Equal(t, 2, binary.Response.Content.Size)
+
+	failed := har.Log.Entries[2]
+	assert.Equal(t, 0, failed.Response.Status)
+	assert.True(t, strings.HasSuffix(failed.Request.URL, "/down"))
+}
+
+func Test_ReplayHAR(t *testing.T) {
+	t.Run("RemapsHostsAndPacesRequests", func(t *testing.T) {
+		// arrange
+		var mu sync.Mutex
+		var received []string
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+
+			mu.Lock()
+			received = append(received, r.Method+" "+r.URL.RequestURI()+" "+r.Header.Get("X-Trace")+" "+string(body))
+			mu.Unlock()
+
+			if r.URL.Path == "/missing" {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		defer s.Close()
+
+		started := time.Now()
+		har, err := swiftreq.ReadHAR(strings.NewReader(fmt.Sprintf(`{"log":{"version":"1.2","entries":[
+			{"startedDateTime":%q,"request":{"method":"GET","url":"https://api.example.com/posts?page=1","headers":[{"name":":authority","value":"api.example.com"},{"name":"X-Trace","value":"a"}]}},
+			{"startedDateTime":%q,"request":{"method":"POST","url":"https://api.example.com/posts","headers":[{"name":"Content-Length","value":"7"}],"postData":{"mimeType":"application/json","text":"{\"a\":1}"}}},
+			{"startedDateTime":%q,"request":{"method":"GET","url":"https://api.example.com/missing","headers":[]}}
+		]}}`,
+			started.Format(time.RFC3339Nano),
+			started.Add(100*time.Millisecond).Format(time.RFC3339Nano),
+			started.Add(200*time.Millisecond).Format(time.RFC3339Nano))))
+		assert.Nil(t, err)
+
+		// act
+		begin := time.Now()
+		results := swiftreq.Default().ReplayHAR(context.Background(), har, swiftreq.ReplayOptions{
+			Hosts:       map[string]string{"api.example.com": s.URL},
+			Speed:       2,
+			Concurrency: 1,
+		})
+		elapsed := time.Since(begin)
+
+		// assert
+		assert.Len(t, results, 3)
+		for _, r := range results {
+			assert.Nil(t, r.Err)
+		}
+		assert.Equal(t, http.StatusOK, results[0].StatusCode)
+		assert.Equal(t, http.StatusNotFound, results[2].StatusCode)
+		assert.GreaterOrEqual(t, elapsed, 100*time.Millisecond)
+		assert.Equal(t, []string{"GET /posts?page=1 a ", `POST /posts  {"a":1}`, "GET /missing  "}, received)
+	})
+
+	t.Run("Filter", func(t *testing.T) {
+		// arrange
+		var hits atomic.Int32
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { hits.Add(1) }))
+		defer s.Close()
+
+		har := &swiftreq.HAR{Log: swiftreq.HARLog{Entries: []swiftreq.HAREntry{
+			{Request: swiftreq.HARRequest{Method: "GET", URL: s.URL + "/a"}},
+			{Request: swiftreq.HARRequest{Method: "DELETE", URL: s.URL + "/a"}},
+		}}}
+
+		// act
+		results := swiftreq.Default().ReplayHAR(context.Background(), har, swiftreq.ReplayOptions{
+			Filter: func(e *swiftreq.HAREntry) bool { return e.Request.Method == "GET" },
+		})
+
+		// assert
+		assert.Len(t, results, 1)
+		assert.Equal(t, int32(1), hits.Load())
+	})
+}
+
+func Test_WithBaseURL(t *testing.T) {
+	// arrange
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"ID":1,"Name":"` + r.URL.RequestURI() + `"}`))
+	}))
+	defer s.Close()
+	re := swiftreq.NewRequestExecutor(http.Client{}).WithBaseURL(s.URL + "/v1/")
+
+	tests := []struct {
+		name string
+		url  string
+		path string
+	}{
+		{name: "Absolute", url: s.URL + "/health", path: "/health"},
+		{name: "RelativePath", url: "/users/1", path: "/v1/users/1"},
+		{name: "WithoutSlash", url: "users/1?fields=name", path: "/v1/users/1?fields=name"},
+		{name: "AbsoluteURLInQuery", url: "/login?next=https://x", path: "/v1/login?next=https%3A%2F%2Fx"},
+		{name: "Empty", url: "", path: "/v1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// act
+			resp, err := swiftreq.Get[TestResponse](tt.url).WithRequestExecutor(re).Do(context.Background())
+
+			// assert
+			assert.Nil(t, err)
+			assert.Equal(t, tt.path, resp.Name)
+		})
+	}
+
+	t.Run("InheritedByChild", func(t *testing.T) {
+		// act
+		resp, err := swiftreq.Get[TestResponse]("/users/2").WithRequestExecutor(re.Child()).Do(context.Background())
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, "/v1/users/2", resp.Name)
+	})
+
+	t.Run("RelativeWithoutBaseURL", func(t *testing.T) {
+		// act
+		_, err := swiftreq.Get[TestResponse]("/users/1").WithRequestExecutor(swiftreq.NewRequestExecutor(http.Client{})).Do(context.Background())
+
+		// assert
+		assert.NotNil(t, err)
+	})
+}
+
+func Test_Cookies(t *testing.T) {
+	// arrange
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			http.SetCookie(w, &http.Cookie{Name: "sid", Value: "s1", Path: "/"})
+		}
+
+		var names []string
+		for _, c := range r.Cookies() {
+			names = append(names, c.Name+"="+c.Value)
+		}
+		sort.Strings(names)
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"ID":1,"Name":"` + strings.Join(names, ";") + `"}`))
+	}))
+	defer s.Close()
+
+	t.Run("WithCookies", func(t *testing.T) {
+		// act
+		resp, err := swiftreq.Get[TestResponse](s.URL+"/me").
+			WithRequestExecutor(swiftreq.NewRequestExecutor(http.Client{})).
+			WithCookies(&http.Cookie{Name: "theme", Value: "dark"}, &http.Cookie{Name: "lang", Value: "en"}).
+			Do(context.Background())
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, "lang=en;theme=dark", resp.Name)
+	})
+
+	t.Run("WithCookieJar", func(t *testing.T) {
+		// arrange
+		re := swiftreq.NewRequestExecutor(http.Client{}).WithCookieJar(nil)
+		_, err := swiftreq.Post[TestResponse](s.URL+"/login", nil).WithRequestExecutor(re).Do(context.Background())
+		assert.Nil(t, err)
+
+		// act
+		resp, err := swiftreq.Get[TestResponse](s.URL + "/me").WithRequestExecutor(re).WithCookies(&http.Cookie{Name: "theme", Value: "dark"}).Do(context.Background())
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, "sid=s1;theme=dark", resp.Name)
+	})
+
+	t.Run("WithoutJar", func(t *testing.T) {
+		// arrange
+		re := swiftreq.NewRequestExecutor(http.Client{})
+		_, err := swiftreq.Post[TestResponse](s.URL+"/login", nil).WithRequestExecutor(re).Do(context.Background())
+		assert.Nil(t, err)
+
+		// act
+		resp, err := swiftreq.Get[TestResponse](s.URL + "/me").WithRequestExecutor(re).Do(context.Background())
+
+		// assert
+		assert.Nil(t, err)
+		assert.Empty(t, resp.Name)
+	})
+}
+
+func Test_Session(t *testing.T) {
+	// arrange
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			http.SetCookie(w, &http.Cookie{Name: "sid", Value: "s1", Path: "/"})
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"id":1,"name":"token-1"}`))
+		case "/me":
+			cookie, err := r.Cookie("sid")
+			if err != nil || cookie.Value != "s1" || r.Header.Get("Authorization") != "Bearer token-1" || r.Header.Get("X-Client") != "cli" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"id":2,"name":"me"}`))
+		}
+	}))
+	defer s.Close()
+
+	session := swiftreq.NewSession(swiftreq.NewRequestExecutor(http.Client{}), s.URL+"/").
+		WithHeader("X-Client", "cli")
+
+	// act
+	login, loginErr := swiftreq.Post[TestResponse](session.URL("/login"), map[string]string{"user": "u"}).WithSession(session).Do(context.Background())
+	session.SetToken("Bearer", login.Name)
+
+	me, meErr := swiftreq.Get[TestResponse](session.URL("me")).WithSession(session).Do(context.Background())
+	relative, relativeErr := swiftreq.Get[TestResponse]("/me").WithSession(session).Do(context.Background())
+
+	session.Reset()
+	_, resetErr := swiftreq.Get[TestResponse](session.URL("me")).WithSession(session).Do(context.Background())
+
+	// assert
+	assert.Nil(t, loginErr)
+	assert.Nil(t, meErr)
+	assert.Equal(t, "me", me.Name)
+	assert.Nil(t, relativeErr)
+	assert.Equal(t, "me", relative.Name)
+
+	var swiftErr *swiftreq.Error
+	assert.True(t, errors.As(resetErr, &swiftErr))
+	assert.Equal(t, http.StatusUnauthorized, swiftErr.StatusCode)
+	assert.Empty(t, session.Cookies(s.URL))
+	assert.Empty(t, session.Token())
+}
+
+func Test_Child(t *testing.T) {
+	// arrange
+	var hits atomic.Int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/fail":
+			hits.Add(1)
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case "/slow":
+			time.Sleep(50 * time.Millisecond)
+		case "/headers":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(fmt.Sprintf(`{"id":1,"name":%q}`, r.Header.Get("X-Team"))))
+		}
+	}))
+	defer s.Close()
+
+	parent := swiftreq.NewRequestExecutor(http.Client{})
+	parent.MinWaitRetry = time.Millisecond
+	parent.MaxWaitRetry = time.Millisecond
+	parent.WithExponentialRetry(1)
+
+	child := parent.Child(
+		swiftreq.ChildRetry(3),
+		swiftreq.ChildTimeout(10*time.Millisecond),
+		swiftreq.ChildHeaders(map[string]string{"X-Team": "payments"}),
+	)
+
+	t.Run("Retry", func(t *testing.T) {
+		// act
+		hits.Store(0)
+		_, _ = swiftreq.Get[swiftreq.RawBytes](s.URL + "/fail").WithRequestExecutor(parent).Do(context.Background())
+		parentHits := hits.Load()
+
+		hits.Store(0)
+		_, _ = swiftreq.Get[swiftreq.RawBytes](s.URL + "/fail").WithRequestExecutor(child).Do(context.Background())
+
+		// assert
+		assert.Equal(t, int32(2), parentHits)
+		assert.Equal(t, int32(4), hits.Load())
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		// act
+		_, parentErr := swiftreq.Get[swiftreq.RawBytes](s.URL + "/slow").WithRequestExecutor(parent).Do(context.Background())
+		_, childErr := swiftreq.Get[swiftreq.RawBytes](s.URL + "/slow").WithRequestExecutor(child).Do(context.Background())
+
+		// assert
+		assert.Nil(t, parentErr)
+		assert.NotNil(t, childErr)
+	})
+
+	t.Run("Headers", func(t *testing.T) {
+		// act
+		resp, err := swiftreq.Get[TestResponse](s.URL + "/headers").WithRequestExecutor(child).Do(context.Background())
+		overridden, _ := swiftreq.Get[TestResponse](s.URL+"/headers").WithHeader("X-Team", "search").WithRequestExecutor(child).Do(context.Background())
+		parentResp, _ := swiftreq.Get[TestResponse](s.URL + "/headers").WithRequestExecutor(parent).Do(context.Background())
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, "payments", resp.Name)
+		assert.Equal(t, "search", overridden.Name)
+		assert.Equal(t, "", parentResp.Name)
+	})
+}
+
+func Test_WithProxyRules(t *testing.T) {
+	// arrange
+	proxy := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(name + " " + r.URL.Host))
+		}))
+	}
+
+	httpProxy, corpProxy := proxy("http-proxy"), proxy("corp-proxy")
+	defer httpProxy.Close()
+	defer corpProxy.Close()
+
+	re := swiftreq.NewRequestExecutor(http.Client{}).WithProxyRules(swiftreq.ProxyRules{
+		HTTP:    httpProxy.URL,
+		NoProxy: []string{"internal.test", "10.0.0.0/8"},
+		Hosts: []swiftreq.HostProxy{
+			{Pattern: "*.corp.test", Proxy: corpProxy.URL},
+			{Pattern: "bad.test", Proxy: "://"},
+		},
+	})
+
+	tests := []struct {
+		name     string
+		url      string
+		expected string
+		fails    bool
+	}{
+		{name: "SchemeProxy", url: "http://api.test/a", expected: "http-proxy api.test"},
+		{name: "HostPattern", url: "http://wiki.corp.test/a", expected: "corp-proxy wiki.corp.test"},
+		{name: "NoProxySubdomain", url: "http://svc.internal.test:1/a", fails: true},
+		{name: "NoProxyCIDR", url: "http://10.255.255.1:1/a", fails: true},
+		{name: "InvalidProxy", url: "http://bad.test/a", fails: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// act
+			ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+			defer cancel()
+
+			resp, err := swiftreq.Get[string](tt.url).WithRequestExecutor(re).Do(ctx)
+
+			// assert
+			if tt.fails {
+				assert.NotNil(t, err)
+				return
+			}
+
+			assert.Nil(t, err)
+			assert.Equal(t, tt.expected, *resp)
+		})
+	}
+}
+
+func Test_WithVerifyPeerCertificate(t *testing.T) {
+	// arrange
+	s := httptest.NewTLSServer(http.HandlerFunc(mockGetEndpoint))
+	defer s.Close()
+
+	fingerprint := sha256.Sum256(s.Certificate().Raw)
+	pin := func(expected [32]byte) func([][]byte, [][]*x509.Certificate) error {
+		return func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+			if len(verifiedChains) == 0 || sha256.Sum256(rawCerts[0]) != expected {
+				return errors.New("certificate not pinned")
+			}
+
+			return nil
+		}
+	}
+
+	t.Run("Accepted", func(t *testing.T) {
+		// arrange
+		re := swiftreq.NewRequestExecutor(*s.Client()).WithVerifyPeerCertificate(pin(fingerprint))
+
+		// act
+		_, err := swiftreq.Get[TestResponse](s.URL).WithRequestExecutor(re).Do(context.Background())
+
+		// assert
+		assert.Nil(t, err)
+	})
+
+	t.Run("Rejected", func(t *testing.T) {
+		// arrange
+		re := swiftreq.NewRequestExecutor(*s.Client()).WithVerifyPeerCertificate(pin([32]byte{}))
+
+		// act
+		_, err := swiftreq.Get[TestResponse](s.URL).WithRequestExecutor(re).Do(context.Background())
+
+		// assert
+		assert.ErrorContains(t, err, "certificate not pinned")
+	})
+}
+
+func Test_WithAdaptiveTimeouts(t *testing.T) {
+	// arrange
+	var delay atomic.Int64
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(time.Duration(delay.Load()))
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer s.Close()
+
+	re := swiftreq.NewRequestExecutor(http.Client{}).WithAdaptiveTimeouts(middlewares.AdaptiveTimeoutOptions{
+		Factor:     2,
+		Min:        20 * time.Millisecond,
+		Max:        time.Second,
+		MinSamples: 5,
+	})
+
+	get := func(path string) error {
+		_, err := swiftreq.Get[string](s.URL + path).WithRequestExecutor(re).Do(context.Background())
+		return err
+	}
+
+	t.Run("MaxUntilSamplesAreObserved", func(t *testing.T) {
+		// act
+		delay.Store(int64(100 * time.Millisecond))
+		err := get("/slow")
+
+		// assert
+		assert.Nil(t, err)
+	})
+
+	t.Run("FastRouteFailsFast", func(t *testing.T) {
+		// arrange
+		delay.Store(0)
+		for i := 0; i < 5; i++ {
+			assert.Nil(t, get("/fast"))
+		}
+
+		// act
+		delay.Store(int64(200 * time.Millisecond))
+		start := time.Now()
+		err := get("/fast")
+
+		// assert
+		var timeoutErr *swiftreq.TimeoutError
+		assert.True(t, errors.As(err, &timeoutErr))
+		assert.Less(t, time.Since(start), 150*time.Millisecond)
+	})
+}
+
+func Test_RequestAuthorization(t *testing.T) {
+	// arrange
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Header.Get("Authorization")))
+	}))
+	defer s.Close()
+
+	t.Run("WithBasicAuth", func(t *testing.T) {
+		// act
+		resp, err := swiftreq.Get[string](s.URL).WithBasicAuth("user", "pass").Do(context.Background())
+
+		// assert
+		assert.Nil(t, err)
+		req := &http.Request{Header: http.Header{"Authorization": {*resp}}}
+		username, password, ok := req.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "user", username)
+		assert.Equal(t, "pass", password)
+	})
+
+	t.Run("WithBearerToken", func(t *testing.T) {
+		// act
+		resp, err := swiftreq.Get[string](s.URL).WithBearerToken("token").Do(context.Background())
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, "Bearer token", *resp)
+	})
+}
+
+func Test_WithHedging(t *testing.T) {
+	// arrange
+	var calls, canceled atomic.Int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		delay := 10 * time.Millisecond
+		if calls.Add(1) == 1 {
+			delay = time.Second
+		}
+
+		select {
+		case <-time.After(delay):
+			_, _ = w.Write([]byte("ok"))
+		case <-r.Context().Done():
+			canceled.Add(1)
+		}
+	}))
+	defer s.Close()
+
+	re := swiftreq.NewRequestExecutor(http.Client{}).WithHedging(middlewares.HedgeOptions{Delay: 30 * time.Millisecond})
+
+	t.Run("FirstResponseWins", func(t *testing.T) {
+		// act
+		start := time.Now()
+		resp, err := swiftreq.Get[string](s.URL).WithRequestExecutor(re).Do(context.Background())
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, "ok", *resp)
+		assert.Less(t, time.Since(start), 500*time.Millisecond)
+		assert.Equal(t, int32(2), calls.Load())
+		assert.Eventually(t, func() bool { return canceled.Load() == 1 }, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("NonIdempotentNotHedged", func(t *testing.T) {
+		// arrange
+		calls.Store(1)
+
+		// act
+		_, err := swiftreq.Post[string](s.URL, TestRequest{ID: 1}).WithRequestExecutor(re).Do(context.Background())
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, int32(2), calls.Load())
+	})
+}
+
+func Test_WithRateLimit(t *testing.T) {
+	// arrange
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer s.Close()
+
+	t.Run("BlocksUntilAllowed", func(t *testing.T) {
+		// arrange
+		re := swiftreq.NewRequestExecutor(http.Client{}).WithRateLimit(20, 2)
+
+		// act
+		start := time.Now()
+		for i := 0; i < 4; i++ {
+			_, err := swiftreq.Get[string](s.URL).WithRequestExecutor(re).Do(context.Background())
+			assert.Nil(t, err)
+		}
+
+		// assert
+		assert.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond)
+	})
+
+	t.Run("RespectsContext", func(t *testing.T) {
+		// arrange
+		re := swiftreq.NewRequestExecutor(http.Client{}).WithRateLimit(1, 1)
+		_, _ = swiftreq.Get[string](s.URL).WithRequestExecutor(re).Do(context.Background())
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		// act
+		_, err := swiftreq.Get[string](s.URL).WithRequestExecutor(re).Do(ctx)
+
+		// assert
+		var timeoutErr *swiftreq.TimeoutError
+		assert.True(t, errors.As(err, &timeoutErr))
+	})
+
+	t.Run("MaxWait", func(t *testing.T) {
+		// arrange
+		limiter := middlewares.NewRateLimiter(1, 1)
+		re := swiftreq.NewRequestExecutor(http.Client{}).WithRateLimiter(limiter, middlewares.RateLimitOptions{MaxWait: 10 * time.Millisecond})
+		_, _ = swiftreq.Get[string](s.URL).WithRequestExecutor(re).Do(context.Background())
+
+		// act
+		_, err := swiftreq.Get[string](s.URL).WithRequestExecutor(re).Do(context.Background())
+
+		// assert
+		var rateErr *swiftreq.RateLimitError
+		assert.True(t, errors.As(err, &rateErr))
+		assert.True(t, limiter.Exhausted())
+	})
+}
+
+func Test_WithFaultInjection(t *testing.T) {
+	// arrange
+	var calls atomic.Int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"ID":1}`))
+	}))
+	defer s.Close()
+
+	t.Run("StatusCode", func(t *testing.T) {
+		// arrange
+		calls.Store(0)
+		re := swiftreq.NewRequestExecutor(http.Client{}).WithFaultInjection(middlewares.Fault{URL: s.URL + "/orders", Probability: 1, StatusCode: http.StatusServiceUnavailable})
+
+		// act
+		_, err := swiftreq.Get[TestResponse](s.URL + "/orders/1").WithRequestExecutor(re).Do(context.Background())
+		_, otherErr := swiftreq.Get[TestResponse](s.URL + "/users/1").WithRequestExecutor(re).Do(context.Background())
+
+		// assert
+		var swiftErr *swiftreq.Error
+		assert.True(t, errors.As(err, &swiftErr))
+		assert.Equal(t, http.StatusServiceUnavailable, swiftErr.StatusCode)
+		assert.Nil(t, otherErr)
+		assert.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("DropIsRetried", func(t *testing.T) {
+		// arrange
+		calls.Store(0)
+		var injected atomic.Int32
+		re := swiftreq.NewRequestExecutor(http.Client{}).WithFaultInjection(middlewares.Fault{
+			Match:       func(req *http.Request) bool { return injected.Add(1) <= 2 },
+			Probability: 1,
+			Drop:        true,
+		})
+		re.MinWaitRetry = time.Millisecond
+		re.MaxWaitRetry = time.Millisecond
+
+		// act
+		_, dropErr := swiftreq.Get[TestResponse](s.URL).WithRequestExecutor(re).Do(context.Background())
+		injected.Store(0)
+		re.WithExponentialRetry(3)
+		resp, err := swiftreq.Get[TestResponse](s.URL).WithRequestExecutor(re).Do(context.Background())
+
+		// assert
+		var conn *swiftreq.ConnectionError
+		assert.True(t, errors.As(dropErr, &conn))
+		var fault *middlewares.FaultError
+		assert.True(t, errors.As(dropErr, &fault))
+		assert.Nil(t, err)
+		assert.Equal(t, 1, resp.ID)
+		assert.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("Latency", func(t *testing.T) {
+		// arrange
+		re := swiftreq.NewRequestExecutor(http.Client{}).WithFaultInjection(middlewares.Fault{Probability: 1, Latency: time.Second})
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		// act
+		start := time.Now()
+		_, err := swiftreq.Get[TestResponse](s.URL).WithRequestExecutor(re).Do(ctx)
+
+		// assert
+		var timeout *swiftreq.TimeoutError
+		assert.True(t, errors.As(err, &timeout))
+		assert.Less(t, time.Since(start), 500*time.Millisecond)
+	})
+
+	t.Run("Probability", func(t *testing.T) {
+		// arrange
+		re := swiftreq.NewRequestExecutor(http.Client{}).WithFaultInjection(middlewares.Fault{Probability: 0.5, StatusCode: http.StatusInternalServerError})
+
+		// act
+		failures := 0
+		for i := 0; i < 200; i++ {
+			if _, err := swiftreq.Get[TestResponse](s.URL).WithRequestExecutor(re).Do(context.Background()); err != nil {
+				failures++
+			}
+		}
+
+		// assert
+		assert.Greater(t, failures, 50)
+		assert.Less(t, failures, 150)
+	})
+
+	t.Run("ZeroProbabilityDisables", func(t *testing.T) {
+		// arrange
+		calls.Store(0)
+		re := swiftreq.NewRequestExecutor(http.Client{}).WithFaultInjection(middlewares.Fault{Probability: 0, Drop: true})
+
+		// act
+		_, err := swiftreq.Get[TestResponse](s.URL).WithRequestExecutor(re).Do(context.Background())
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, int32(1), calls.Load())
+	})
+}
+
+func Test_WithCircuitBreaker(t *testing.T) {
+	// arrange
+	var failing atomic.Bool
+	var calls atomic.Int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if failing.Load() {
+			mockServerErrorEndpoint(w, r)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer s.Close()
+
+	var transitions []string
+	re := swiftreq.NewRequestExecutor(http.Client{}).WithCircuitBreaker(middlewares.CircuitBreakerOptions{
+		FailureThreshold: 3,
+		OpenTimeout:      50 * time.Millisecond,
+		OnStateChange: func(key string, from, to middlewares.CircuitState) {
+			transitions = append(transitions, from.String()+"->"+to.String())
+		},
+	})
+	re.MinWaitRetry = time.Millisecond
+	re.MaxWaitRetry = time.Millisecond
+	re.WithExponentialRetry(10)
+
+	get := func() error {
+		_, err := swiftreq.Get[string](s.URL).WithRequestExecutor(re).Do(context.Background())
+		return err
+	}
+
+	t.Run("OpensAfterConsecutiveFailures", func(t *testing.T) {
+		// arrange
+		failing.Store(true)
+
+		// act
+		err := get()
+
+		// assert
+		var circuitErr *swiftreq.CircuitOpenError
+		assert.True(t, errors.As(err, &circuitErr))
+		assert.Equal(t, int32(3), calls.Load())
+		assert.Equal(t, []string{"closed->open"}, transitions)
+	})
+
+	t.Run("FailsFastWhileOpen", func(t *testing.T) {
+		// act
+		err := get()
+
+		// assert
+		var circuitErr *swiftreq.CircuitOpenError
+		assert.True(t, errors.As(err, &circuitErr))
+		assert.Equal(t, int32(3), calls.Load())
+	})
+
+	t.Run("ClosesAfterSuccessfulProbe", func(t *testing.T) {
+		// arrange
+		failing.Store(false)
+		time.Sleep(60 * time.Millisecond)
+
+		// act
+		err := get()
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, int32(4), calls.Load())
+		assert.Equal(t, []string{"closed->open", "open->half-open", "half-open->closed"}, transitions)
+	})
+}
+
+func Test_WithStallTimeout(t *testing.T) {
+	// arrange
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pause, _ := time.ParseDuration(r.URL.Query().Get("pause"))
+		for i := 0; i < 4; i++ {
+			_, _ = w.Write([]byte("chunk"))
+			w.(http.Flusher).Flush()
+			select {
+			case <-time.After(pause):
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}))
+	defer s.Close()
+
+	re := swiftreq.NewRequestExecutor(http.Client{}).WithStallTimeout(80 * time.Millisecond)
+
+	t.Run("SlowButSteadyTransfer", func(t *testing.T) {
+		// act
+		resp, err := swiftreq.Get[string](s.URL + "?pause=40ms").WithRequestExecutor(re).Do(context.Background())
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, "chunkchunkchunkchunk", *resp)
+	})
+
+	t.Run("StalledTransfer", func(t *testing.T) {
+		// act
+		start := time.Now()
+		_, err := swiftreq.Get[string](s.URL + "?pause=2s").WithRequestExecutor(re).Do(context.Background())
+
+		// assert
+		var stallErr *swiftreq.StallError
+		assert.True(t, errors.As(err, &stallErr))
+		assert.Less(t, time.Since(start), time.Second)
+	})
+}
+
+func Test_Budget(t *testing.T) {
+	t.Run("SplitsRemainingTime", func(t *testing.T) {
+		// arrange
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		budget := swiftreq.NewBudget(ctx, 1, 1, 2)
+
+		// act
+		first, cancelFirst := budget.Next()
+		firstDeadline, _ := first.Deadline()
+		cancelFirst()
+
+		second, cancelSecond := budget.Next()
+		secondDeadline, _ := second.Deadline()
+		cancelSecond()
+
+		third, cancelThird := budget.Next()
+		thirdDeadline, _ := third.Deadline()
+		cancelThird()
+
+		// assert
+		parentDeadline, _ := ctx.Deadline()
+		assert.InDelta(t, 250*time.Millisecond, time.Until(firstDeadline), float64(50*time.Millisecond))
+		assert.InDelta(t, 333*time.Millisecond, time.Until(secondDeadline), float64(50*time.Millisecond))
+		assert.WithinDuration(t, parentDeadline, thirdDeadline, 10*time.Millisecond)
+	})
+
+	t.Run("TimesOutStep", func(t *testing.T) {
+		// arrange
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(100 * time.Millisecond)
+		}))
+		defer s.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+
+		budget := swiftreq.NewBudget(ctx, 25, 75)
+
+		// act
+		step, cancelStep := budget.Next()
+		defer cancelStep()
+
+		_, err := swiftreq.Get[string](s.URL).Do(step)
+
+		// assert
+		var timeoutErr *swiftreq.TimeoutError
+		assert.True(t, errors.As(err, &timeoutErr))
+		assert.Nil(t, ctx.Err())
+	})
+
+	t.Run("WithoutDeadline", func(t *testing.T) {
+		// act
+		step, cancel := swiftreq.NewBudget(context.Background(), 50, 50).Next()
+		defer cancel()
+
+		// assert
+		_, ok := step.Deadline()
+		assert.False(t, ok)
+	})
+}
+
+func Test_WithLoadShedding(t *testing.T) {
+	t.Run("ShedsLowPriorityAfterThrottling", func(t *testing.T) {
+		// arrange
+		var hits atomic.Int32
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if hits.Add(1) == 1 {
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+
+			_, _ = w.Write([]byte("ok"))
+		}))
+		defer s.Close()
+
+		re := swiftreq.NewRequestExecutor(http.Client{}).WithLoadShedding(middlewares.LoadShedOptions{Cooldown: time.Minute})
+
+		// act
+		_, throttledErr := swiftreq.Get[string](s.URL).WithRequestExecutor(re).Do(context.Background())
+		_, lowErr := swiftreq.Get[string](s.URL).WithPriority(middlewares.PriorityLow).WithRequestExecutor(re).Do(context.Background())
+		normal, normalErr := swiftreq.Get[string](s.URL).WithRequestExecutor(re).Do(context.Background())
+
+		// assert
+		assert.NotNil(t, throttledErr)
+
+		var shedErr *swiftreq.LoadShedError
+		assert.True(t, errors.As(lowErr, &shedErr))
+
+		assert.Nil(t, normalErr)
+		assert.Equal(t, "ok", *normal)
+		assert.Equal(t, int32(2), hits.Load())
+	})
+
+	t.Run("ShedsBelowMinPriorityWhenSaturated", func(t *testing.T) {
+		// arrange
+		release := make(chan struct{})
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/block" {
+				<-release
+			}
+		}))
+		defer s.Close()
+
+		re := swiftreq.NewRequestExecutor(http.Client{}).WithLoadShedding(middlewares.LoadShedOptions{
+			MinPriority: middlewares.PriorityHigh,
+			MaxInFlight: 1,
+		})
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			_, _ = swiftreq.Get[swiftreq.RawBytes](s.URL + "/block").WithRequestExecutor(re).Do(context.Background())
+		}()
+		time.Sleep(50 * time.Millisecond)
+
+		// act
+		_, normalErr := swiftreq.Get[swiftreq.RawBytes](s.URL).WithRequestExecutor(re).Do(context.Background())
+		_, highErr := swiftreq.Get[swiftreq.RawBytes](s.URL).WithPriority(middlewares.PriorityHigh).WithRequestExecutor(re).Do(context.Background())
+
+		close(release)
+		<-done
+
+		// assert
+		var shedErr *swiftreq.LoadShedError
+		assert.True(t, errors.As(normalErr, &shedErr))
+		assert.Nil(t, highErr)
+	})
+
+	t.Run("ShedRequestsNotRetried", func(t *testing.T) {
+		// arrange
+		s := httptest.NewServer(http.HandlerFunc(mockServerErrorEndpoint))
+		defer s.Close()
+
+		var attempts atomic.Int32
+		re := swiftreq.NewRequestExecutor(http.Client{}).
+			WithCircuitBreaker(middlewares.CircuitBreakerOptions{FailureThreshold: 1, OpenTimeout: time.Minute}).
+			WithLoadShedding(middlewares.LoadShedOptions{Cooldown: time.Minute}).
+			WithMiddleware(func(next middlewares.Handler) middlewares.Handler {
+				return func(r *http.Request) (*http.Response, error) {
+					attempts.Add(1)
+					return next(r)
+				}
+			})
+		re.MinWaitRetry = time.Millisecond
+		re.MaxWaitRetry = time.Millisecond
+		re.WithExponentialRetry(5)
+
+		_, _ = swiftreq.Get[string](s.URL).WithRequestExecutor(re).Do(context.Background())
+		attempts.Store(0)
+
+		// act
+		_, lowErr := swiftreq.Get[string](s.URL).WithPriority(middlewares.PriorityLow).WithRequestExecutor(re).Do(context.Background())
+
+		// assert
+		var shedErr *swiftreq.LoadShedError
+		assert.True(t, errors.As(lowErr, &shedErr))
+		assert.Equal(t, int32(1), attempts.Load())
+	})
+
+	t.Run("EmptyResponse", func(t *testing.T) {
+		// arrange
+		re := swiftreq.NewRequestExecutor(http.Client{}).
+			WithMiddleware(func(next middlewares.Handler) middlewares.Handler {
+				return func(r *http.Request) (*http.Response, error) {
+					return nil, nil
+				}
+			}).
+			WithLoadShedding(middlewares.LoadShedOptions{})
+
+		// act
+		_, err := swiftreq.Get[string](server.URL).WithRequestExecutor(re).Do(context.Background())
+
+		// assert
+		assert.ErrorContains(t, err, "returned empty response")
+	})
+}
+
+func Test_WithToggle(t *testing.T) {
+	// arrange
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("debug=" + r.Header.Get("X-Debug")))
+	}))
+	defer s.Close()
+
+	header := func(value string) middlewares.Middleware {
+		return func(next middlewares.Handler) middlewares.Handler {
+			return func(req *http.Request) (*http.Response, error) {
+				req.Header.Set("X-Debug", value)
+				return next(req)
+			}
+		}
+	}
+
+	re := swiftreq.NewRequestExecutor(http.Client{}).WithToggle("debug", header("v1"), false)
+	get := func() string {
+		resp, err := swiftreq.Get[string](s.URL).WithRequestExecutor(re).Do(context.Background())
+		assert.Nil(t, err)
+		return *resp
+	}
+
+	// act
+	disabled := get()
+
+	re.Toggle("debug").Enable()
+	enabled := get()
+
+	re.Toggle("debug").Set(header("v2"))
+	replaced := get()
+
+	re.SetToggles(map[string]bool{"debug": false, "unknown": true})
+	toggledOff := get()
+
+	// assert
+	assert.Equal(t, "debug=", disabled)
+	assert.Equal(t, "debug=v1", enabled)
+	assert.Equal(t, "debug=v2", replaced)
+	assert.Equal(t, "debug=", toggledOff)
+	assert.Nil(t, re.Toggle("unknown"))
+}
+
+func Test_WithCanary(t *testing.T) {
+	// arrange
+	server := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(name + " " + r.URL.RequestURI()))
+		}))
+	}
+
+	primary, canary := server("primary"), server("canary")
+	defer primary.Close()
+	defer canary.Close()
+
+	c := middlewares.NewCanary(middlewares.CanaryRule{Match: primary.URL + "/v1", Target: canary.URL + "/v2", Percent: 100})
+	re := swiftreq.NewRequestExecutor(http.Client{}).WithCanary(c)
+
+	get := func(url string) string {
+		resp, err := swiftreq.Get[string](url).WithQueryParameter("page", "2").WithRequestExecutor(re).Do(context.Background())
+		assert.Nil(t, err)
+		return *resp
+	}
+
+	// act
+	routed := get(primary.URL + "/v1/users")
+	unmatched := get(primary.URL + "/v10/users")
+
+	c.Update(middlewares.CanaryRule{Match: primary.URL + "/v1", Target: canary.URL + "/v2", Percent: 0})
+	rolledBack := get(primary.URL + "/v1/users")
+
+	// assert
+	assert.Equal(t, "canary /v2/users?page=2", routed)
+	assert.Equal(t, "primary /v10/users?page=2", unmatched)
+	assert.Equal(t, "primary /v1/users?page=2", rolledBack)
+
+	stats := c.Stats()
+	assert.Equal(t, int64(1), stats[canary.URL+"/v2"].Requests)
+	assert.Equal(t, int64(1), stats[primary.URL+"/v1"].Requests)
+	assert.Equal(t, int64(0), stats[canary.URL+"/v2"].Errors)
+}
+
+func Test_WithCanaryEmptyResponse(t *testing.T) {
+	// arrange
+	c := middlewares.NewCanary(middlewares.CanaryRule{Match: server.URL + "/v1", Target: server.URL + "/v2", Percent: 100})
+	re := swiftreq.NewRequestExecutor(http.Client{}).
+		WithMiddleware(func(next middlewares.Handler) middlewares.Handler {
+			return func(r *http.Request) (*http.Response, error) {
+				return nil, nil
+			}
+		}).
+		WithCanary(c)
+
+	// act
+	_, err := swiftreq.Get[string](server.URL + "/v1/users").WithRequestExecutor(re).Do(context.Background())
+
+	// assert
+	assert.ErrorContains(t, err, "returned empty response")
+	assert.Equal(t, int64(1), c.Stats()[server.URL+"/v2"].Errors)
+}
+
+func Test_RetryTimeouts(t *testing.T) {
+	newExecutor := func(timeouts swiftreq.RetryTimeouts) *swiftreq.RequestExecutor {
+		re := swiftreq.NewRequestExecutor(http.Client{})
+		re.MinWaitRetry = 10 * time.Millisecond
+		re.MaxWaitRetry = 10 * time.Millisecond
+		return re.WithExponentialRetry(10).WithRetryTimeouts(timeouts)
+	}
+
+	t.Run("AttemptTimeout", func(t *testing.T) {
+		// arrange
+		var calls atomic.Int32
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if calls.Add(1) == 1 {
+				select {
+				case <-r.Context().Done():
+				case <-time.After(5 * time.Second):
+				}
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"ID":1}`))
+		}))
+		defer s.Close()
+		re := newExecutor(swiftreq.RetryTimeouts{Attempt: 50 * time.Millisecond})
+
+		// act
+		start := time.Now()
+		resp, err := swiftreq.Get[TestResponse](s.URL).WithRequestExecutor(re).Do(context.Background())
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, 1, resp.ID)
+		assert.Equal(t, int32(2), calls.Load())
+		assert.Less(t, time.Since(start), time.Second)
+	})
+
+	t.Run("MaxElapsed", func(t *testing.T) {
+		// arrange
+		var calls atomic.Int32
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer s.Close()
+		re := newExecutor(swiftreq.RetryTimeouts{MaxElapsed: 35 * time.Millisecond})
+
+		// act
+		start := time.Now()
+		_, err := swiftreq.Get[TestResponse](s.URL).WithRequestExecutor(re).Do(context.Background())
+
+		// assert
+		var timeout *swiftreq.TimeoutError
+		assert.True(t, errors.As(err, &timeout))
+		assert.True(t, errors.Is(err, context.DeadlineExceeded))
+		assert.False(t, errors.Is(err, context.Canceled))
+		assert.Less(t, calls.Load(), int32(11))
+		assert.Less(t, time.Since(start), 500*time.Millisecond)
+	})
+
+	t.Run("RewindsBody", func(t *testing.T) {
+		// arrange
+		var mu sync.Mutex
+		var bodies []string
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			mu.Lock()
+			bodies = append(bodies, string(body))
+			n := len(bodies)
+			mu.Unlock()
+			if n == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write(body)
+		}))
+		defer s.Close()
+		re := newExecutor(swiftreq.RetryTimeouts{})
+
+		// act
+		resp, err := swiftreq.Post[TestResponse](s.URL, TestResponse{ID: 7, Name: "retried"}).WithRequestExecutor(re).Do(context.Background())
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, "retried", resp.Name)
+		assert.Len(t, bodies, 2)
+		assert.Equal(t, bodies[0], bodies[1])
+	})
+}
+
+func Test_RetryTelemetry(t *testing.T) {
+	// arrange
+	var mu sync.Mutex
+	var attempts []string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		attempts = append(attempts, r.Header.Get("X-Attempt"))
+		n := len(attempts)
+		mu.Unlock()
+
+		if r.URL.Path == "/fail" || n < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer s.Close()
+
+	re := swiftreq.NewRequestExecutor(http.Client{})
+	re.MinWaitRetry = time.Millisecond
+	re.MaxWaitRetry = 5 * time.Millisecond
+	re.WithExponentialRetry(2).
+		WithAttemptHeader("X-Attempt").
+		WithExpvar("swiftreq_retry_test")
+
+	t.Run("ResponseMeta", func(t *testing.T) {
+		// act
+		_, meta, err := swiftreq.Get[string](s.URL).WithRequestExecutor(re).DoWithResponse(context.Background())
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, 3, meta.Attempts)
+		assert.Greater(t, meta.Backoff, time.Duration(0))
+		assert.Equal(t, []string{"1", "2", "3"}, attempts)
+		assert.Equal(t, "2", expvar.Get("swiftreq_retry_test").(*expvar.Map).Get("retries").String())
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		// act
+		_, err := swiftreq.Get[string](s.URL + "/fail").WithRequestExecutor(re).Do(context.Background())
+
+		// assert
+		var swiftErr *swiftreq.Error
+		assert.True(t, errors.As(err, &swiftErr))
+		assert.Equal(t, 3, swiftErr.Attempts)
+	})
+
+	t.Run("WithoutRetry", func(t *testing.T) {
+		// act
+		_, meta, err := swiftreq.Get[TestResponse](server.URL).WithRequestExecutor(swiftreq.NewRequestExecutor(http.Client{})).DoWithResponse(context.Background())
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, 1, meta.Attempts)
+		assert.Equal(t, time.Duration(0), meta.Backoff)
+	})
+}
+
+func Test_MultiStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{name: "Array", body: `[{"status":201,"body":{"id":1,"name":"a"}},{"status":409,"error":{"code":"duplicate"}},{"data":{"id":3,"name":"c"}}]`},
+		{name: "Envelope", body: `{"results":[{"status":"HTTP/1.1 201 Created","body":{"id":1,"name":"a"}},{"status":"HTTP/1.1 409 Conflict","error":{"code":"duplicate"}},{"status":200,"data":{"id":3,"name":"c"}}]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// arrange
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusMultiStatus)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer s.Close()
+
+			// act
+			resp, meta, err := swiftreq.Post[swiftreq.MultiStatus[TestResponse]](s.URL, []TestRequest{{ID: 1}, {ID: 2}, {ID: 3}}).DoWithResponse(context.Background())
+
+			// assert
+			assert.Nil(t, err)
+			assert.Equal(t, http.StatusMultiStatus, meta.StatusCode)
+			assert.Len(t, resp.Items, 3)
+			assert.Equal(t, []TestResponse{{ID: 1, Name: "a"}, {ID: 3, Name: "c"}}, resp.Succeeded())
+
+			failed := resp.Failed()
+			assert.Len(t, failed, 1)
+			assert.Equal(t, 1, failed[0].Index)
+			assert.Equal(t, http.StatusConflict, failed[0].Status)
+			assert.JSONEq(t, `{"code":"duplicate"}`, string(failed[0].Error.Body))
+
+			var itemErr *swiftreq.ItemError
+			assert.True(t, errors.As(resp.Err(), &itemErr))
+		})
+	}
+}
+
+func Test_ErrorResponseDetails(t *testing.T) {
+	t.Run("StatusError", func(t *testing.T) {
+		// arrange
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Retry-After", "30")
+			w.Header().Set("X-Request-Id", "req-1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte(`{"error":"slow down"}`))
+		}))
+		defer s.Close()
+
+		// act
+		_, err := swiftreq.Get[TestResponse](s.URL).Do(context.Background())
+
+		// assert
+		var swiftErr *swiftreq.Error
+		assert.True(t, errors.As(err, &swiftErr))
+		assert.Equal(t, http.StatusTooManyRequests, swiftErr.StatusCode)
+		assert.Equal(t, "30", swiftErr.Header().Get("Retry-After"))
+		assert.Equal(t, "req-1", swiftErr.Header().Get("X-Request-Id"))
+		assert.Equal(t, `{"error":"slow down"}`, string(swiftErr.Body()))
+	})
+
+	t.Run("TransportError", func(t *testing.T) {
+		// act
+		_, err := swiftreq.Get[TestResponse]("http://127.0.0.1:1").Do(context.Background())
+
+		// assert
+		var swiftErr *swiftreq.Error
+		assert.True(t, errors.As(err, &swiftErr))
+		assert.Nil(t, swiftErr.Header())
+		assert.Nil(t, swiftErr.Body())
+	})
+}
+
+func Test_DoMaybe(t *testing.T) {
+	// arrange
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/found":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"id":1,"name":"found"}`))
+		case "/no-content":
+			w.WriteHeader(http.StatusNoContent)
+		case "/empty":
+			w.Header().Set("Content-Type", "application/json")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer s.Close()
+
+	tests := []struct {
+		name     string
+		path     string
+		absent   []int
+		present  bool
+		expected TestResponse
+		fails    bool
+	}{
+		{name: "Present", path: "/found", present: true, expected: TestResponse{ID: 1, Name: "found"}},
+		{name: "NoContent", path: "/no-content"},
+		{name: "EmptyBody", path: "/empty"},
+		{name: "AbsentStatus", path: "/missing", absent: []int{http.StatusNotFound}},
+		{name: "NotFoundError", path: "/missing", fails: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// act
+			result, err := swiftreq.Get[TestResponse](s.URL + tt.path).WithAbsentStatus(tt.absent...).DoMaybe(context.Background())
+
+			// assert
+			if tt.fails {
+				assert.NotNil(t, err)
+				return
+			}
+
+			assert.Nil(t, err)
+			value, ok := result.Get()
+			assert.Equal(t, tt.present, ok)
+			assert.Equal(t, tt.expected, value)
+			assert.Equal(t, TestResponse{Name: "default"}, swiftreq.None[TestResponse]().OrElse(TestResponse{Name: "default"}))
+		})
+	}
+}
+
+func Test_WithAcceptEncoding(t *testing.T) {
+	// arrange
+	payload := []byte(`{"id":1,"name":"compressed"}`)
+	enc, _ := zstd.NewWriter(nil)
+	compressed := enc.EncodeAll(payload, nil)
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Accept-Encoding", r.Header.Get("Accept-Encoding"))
+		w.Header().Set("Content-Type", "application/json")
+
+		if strings.Contains(r.Header.Get("Accept-Encoding"), "zstd") {
+			w.Header().Set("Content-Encoding", "zstd")
+			_, _ = w.Write(compressed)
+			return
+		}
+
+		_, _ = w.Write(payload)
+	}))
+	defer s.Close()
+
+	t.Run("Decompressed", func(t *testing.T) {
+		// arrange
+		re := swiftreq.NewRequestExecutor(http.Client{}).WithAcceptEncoding(middlewares.AcceptEncodingOptions{Encodings: []string{"zstd", "gzip"}})
+
+		// act
+		resp, meta, err := swiftreq.Get[TestResponse](s.URL).WithRequestExecutor(re).DoWithResponse(context.Background())
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, "compressed", resp.Name)
+		assert.Equal(t, "zstd, gzip", meta.Header.Get("X-Accept-Encoding"))
+		assert.Empty(t, meta.Header.Get("Content-Encoding"))
+	})
+
+	t.Run("IdentityOnly", func(t *testing.T) {
+		// arrange
+		re := swiftreq.NewRequestExecutor(http.Client{}).WithAcceptEncoding(middlewares.AcceptEncodingOptions{Encodings: []string{"identity"}})
+
+		// act
+		resp, meta, err := swiftreq.Get[TestResponse](s.URL).WithRequestExecutor(re).DoWithResponse(context.Background())
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, "compressed", resp.Name)
+		assert.Equal(t, "identity", meta.Header.Get("X-Accept-Encoding"))
+	})
+
+	t.Run("Passthrough", func(t *testing.T) {
+		// arrange
+		re := swiftreq.NewRequestExecutor(http.Client{}).WithAcceptEncoding(middlewares.AcceptEncodingOptions{Passthrough: true})
+
+		// act
+		resp, meta, err := swiftreq.Get[swiftreq.RawBytes](s.URL).WithHeader("Accept-Encoding", "zstd").WithRequestExecutor(re).DoWithResponse(context.Background())
+		_, plainMeta, _ := swiftreq.Get[swiftreq.RawBytes](s.URL).WithRequestExecutor(re).DoWithResponse(context.Background())
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, compressed, []byte(*resp))
+		assert.Equal(t, "zstd", meta.Header.Get("Content-Encoding"))
+		assert.Empty(t, plainMeta.Header.Get("X-Accept-Encoding"))
+	})
+
+	t.Run("EmptyBodies", func(t *testing.T) {
+		// arrange
+		empty := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Encoding", "gzip")
+
+			switch r.URL.Path {
+			case "/no-content":
+				w.WriteHeader(http.StatusNoContent)
+			case "/not-modified":
+				w.WriteHeader(http.StatusNotModified)
+			case "/chunked":
+				w.(http.Flusher).Flush()
+			}
+		}))
+		defer empty.Close()
+		re := swiftreq.NewRequestExecutor(http.Client{}).WithAcceptEncoding(middlewares.AcceptEncodingOptions{Encodings: []string{"gzip"}})
+
+		tests := []struct {
+			method string
+			path   string
+			status int
+		}{
+			{method: http.MethodHead, path: "/", status: http.StatusOK},
+			{method: http.MethodGet, path: "/no-content", status: http.StatusNoContent},
+			{method: http.MethodGet, path: "/not-modified", status: http.StatusNotModified},
+			{method: http.MethodGet, path: "/empty", status: http.StatusOK},
+			{method: http.MethodGet, path: "/chunked", status: http.StatusOK},
+		}
+
+		for _, tt := range tests {
+			// act
+			_, meta, err := swiftreq.Get[swiftreq.RawBytes](empty.URL + tt.path).WithMethod(tt.method).WithRequestExecutor(re).DoWithResponse(context.Background())
+
+			// assert
+			status := 0
+			var swiftErr *swiftreq.Error
+			if errors.As(err, &swiftErr) {
+				status = swiftErr.StatusCode
+			} else if assert.Nil(t, err, tt.method+" "+tt.path) {
+				status = meta.StatusCode
+			}
+			assert.Equal(t, tt.status, status, tt.method+" "+tt.path)
+		}
+	})
+}
@@ -0,0 +1,38 @@
+package swiftreq
+
+import (
+	"net/http"
+
+	"github.com/liviudnicoara/swiftreq/middlewares"
+)
+
+// Route applies middlewares only to the requests of a RequestExecutor matching an HTTP method and a path pattern.
+type Route struct {
+	re        *RequestExecutor
+	predicate middlewares.Predicate
+}
+
+// Route creates a Route for the requests matching the method and path pattern.
+// An empty method or "*" matches every method. The pattern uses the path.Match syntax, and a trailing "/*" matches every nested path.
+func (re *RequestExecutor) Route(method, pattern string) *Route {
+	predicate := middlewares.PathMatches(pattern)
+	if method != "" && method != "*" {
+		predicate = middlewares.And(middlewares.MethodIs(method), predicate)
+	}
+
+	return &Route{
+		re:        re,
+		predicate: predicate,
+	}
+}
+
+// Use adds a middleware to the RequestExecutor which runs only for the requests matching the route.
+func (r *Route) Use(m middlewares.Middleware) *Route {
+	r.re.WithMiddleware(middlewares.WhenFunc(r.predicate, m))
+	return r
+}
+
+// Match reports whether the request matches the route.
+func (r *Route) Match(req *http.Request) bool {
+	return r.predicate(req)
+}
@@ -0,0 +1,128 @@
+package swiftreq
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"sync"
+
+	"github.com/liviudnicoara/swiftreq/middlewares"
+)
+
+// Session models a stateful interaction with an API, such as login, calls and logout.
+// It bundles a cookie jar, an access token, default headers and a base URL over a RequestExecutor.
+// Requests join the session with Request.WithSession.
+type Session struct {
+	re *RequestExecutor
+
+	mu      sync.RWMutex
+	headers http.Header
+	schema  string
+	token   string
+}
+
+// NewSession creates a Session sending its requests through a copy of re, or of the default RequestExecutor when re is nil.
+// The copy shares the connection pool and the middlewares of re, and stores the cookies received in its own jar.
+// The access token of the session is meant for executors without authorization middleware, which would add their own.
+func NewSession(re *RequestExecutor, baseURL string) *Session {
+	if re == nil {
+		re = Default()
+	}
+
+	s := &Session{
+		re:      re.derive(),
+		headers: http.Header{},
+	}
+
+	s.re.updateClient(func(c *http.Client) {
+		c.Jar = newCookieJar()
+	})
+	s.re.WithMiddleware(s.middleware)
+
+	if baseURL != "" {
+		s.re.WithBaseURL(baseURL)
+	}
+
+	return s
+}
+
+// newCookieJar creates an empty cookie jar.
+func newCookieJar() http.CookieJar {
+	jar, _ := cookiejar.New(nil)
+	return jar
+}
+
+// URL resolves path against the base URL of the session. Absolute URLs are returned unchanged.
+func (s *Session) URL(path string) string {
+	return s.re.resolveURL(path)
+}
+
+// WithHeader sets a header sent with every request of the session, unless the request sets it.
+func (s *Session) WithHeader(key, value string) *Session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.headers.Set(key, value)
+
+	return s
+}
+
+// SetToken sets the access token sent in the Authorization header of the requests of the session, such as the token returned by a login.
+func (s *Session) SetToken(schema, token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.schema = schema
+	s.token = token
+}
+
+// Token returns the access token of the session, empty when none is set.
+func (s *Session) Token() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.token
+}
+
+// Cookies returns the cookies of the session sent to the URL.
+func (s *Session) Cookies(rawURL string) []*http.Cookie {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil
+	}
+
+	return s.re.httpClient().Jar.Cookies(u)
+}
+
+// Reset clears the cookies and the access token of the session, such as after a logout. The default headers are kept.
+func (s *Session) Reset() {
+	s.SetToken("", "")
+
+	s.re.updateClient(func(c *http.Client) {
+		c.Jar = newCookieJar()
+	})
+}
+
+// Executor returns the RequestExecutor of the session, to configure it or to create requests with Request.WithRequestExecutor.
+func (s *Session) Executor() *RequestExecutor {
+	return s.re
+}
+
+// middleware adds the default headers and the access token of the session to the requests.
+func (s *Session) middleware(next middlewares.Handler) middlewares.Handler {
+	return func(req *http.Request) (*http.Response, error) {
+		s.mu.RLock()
+		for k, vs := range s.headers {
+			if req.Header.Get(k) == "" {
+				req.Header[k] = append([]string{}, vs...)
+			}
+		}
+
+		if s.token != "" && req.Header.Get("Authorization") == "" {
+			req.Header.Set("Authorization", s.schema+" "+s.token)
+		}
+		s.mu.RUnlock()
+
+		return next(req)
+	}
+}
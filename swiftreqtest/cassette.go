@@ -0,0 +1,220 @@
+package swiftreqtest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/liviudnicoara/swiftreq"
+	"github.com/liviudnicoara/swiftreq/middlewares"
+)
+
+// CassetteMode decides whether a Cassette records the exchanges or replays the recorded ones.
+type CassetteMode int
+
+const (
+	// ModeAuto replays the cassette file when it exists, and records it otherwise.
+	ModeAuto CassetteMode = iota
+	// ModeReplay replays the cassette file, which must exist.
+	ModeReplay
+	// ModeRecord sends the requests and records them, replacing the cassette file.
+	ModeRecord
+)
+
+// MatchFunc reports whether a recorded entry answers a request, whose body is provided.
+type MatchFunc func(req *http.Request, body []byte, entry *swiftreq.HAREntry) bool
+
+// MatchMethodURLBody matches the requests with the same method, URL and body as the recorded request.
+func MatchMethodURLBody(req *http.Request, body []byte, entry *swiftreq.HAREntry) bool {
+	if !MatchMethodURL(req, body, entry) {
+		return false
+	}
+
+	recorded := ""
+	if entry.Request.PostData != nil {
+		recorded = entry.Request.PostData.Text
+	}
+
+	return recorded == string(body)
+}
+
+// MatchMethodURL matches the requests with the same method and URL as the recorded request, whatever their body.
+func MatchMethodURL(req *http.Request, _ []byte, entry *swiftreq.HAREntry) bool {
+	return req.Method == entry.Request.Method && req.URL.String() == entry.Request.URL
+}
+
+// CassetteOptions configures a Cassette.
+type CassetteOptions struct {
+	// Mode decides whether the cassette records or replays. It defaults to ModeAuto.
+	Mode CassetteMode
+	// Match selects the recorded entry answering a request. It defaults to MatchMethodURLBody.
+	Match MatchFunc
+	// Recorder configures the recording. The values of the credential headers, such as Authorization and Cookie, are recorded
+	// as [REDACTED] by default. Query parameters and bodies are recorded as sent: check that cassettes carry no secrets, such as
+	// API keys in URLs or client secrets in form posts, before committing them.
+	Recorder swiftreq.HARRecorderOptions
+}
+
+// Cassette records the exchanges of an executor to a HAR file on a first run, and replays them on the following runs,
+// so that integration tests run offline and deterministically:
+//
+//	cassette, err := swiftreqtest.NewCassette("testdata/users.har", swiftreqtest.CassetteOptions{})
+//	re := swiftreq.NewRequestExecutor(*http.DefaultClient).WithMiddleware(cassette.Middleware())
+//	defer cassette.Save()
+type Cassette struct {
+	path  string
+	opts  CassetteOptions
+	mode  CassetteMode
+	har   *swiftreq.HAR
+	rec   *swiftreq.HARRecorder
+	mu    sync.Mutex
+	plays []int
+}
+
+// NewCassette creates a cassette stored in the file at path, loading its entries when it replays.
+func NewCassette(path string, opts CassetteOptions) (*Cassette, error) {
+	if opts.Match == nil {
+		opts.Match = MatchMethodURLBody
+	}
+
+	c := &Cassette{path: path, opts: opts, mode: opts.Mode}
+
+	if c.mode == ModeAuto {
+		c.mode = ModeReplay
+		if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+			c.mode = ModeRecord
+		}
+	}
+
+	if c.mode == ModeRecord {
+		c.rec = swiftreq.NewHARRecorder(opts.Recorder)
+		return c, nil
+	}
+
+	har, err := swiftreq.LoadHAR(path)
+	if err != nil {
+		return nil, fmt.Errorf("swiftreqtest: could not load cassette %s: %w", path, err)
+	}
+
+	c.har = har
+	c.plays = make([]int, len(har.Log.Entries))
+
+	return c, nil
+}
+
+// Recording reports whether the cassette records the exchanges, rather than replaying them.
+func (c *Cassette) Recording() bool {
+	return c.mode == ModeRecord
+}
+
+// Middleware returns the middleware recording or replaying the exchanges.
+// When replaying, requests are not sent: each one is answered with the first recorded entry matching it which was not replayed yet,
+// or the last one matching it when they all were. Requests without recorded entry fail with an error.
+func (c *Cassette) Middleware() middlewares.Middleware {
+	if c.Recording() {
+		return c.rec.Middleware()
+	}
+
+	return func(next middlewares.Handler) middlewares.Handler {
+		return func(req *http.Request) (*http.Response, error) {
+			var body []byte
+			if req.Body != nil && req.Body != http.NoBody {
+				data, err := io.ReadAll(req.Body)
+				req.Body.Close()
+				if err != nil {
+					return nil, err
+				}
+				body = data
+			}
+
+			entry := c.play(req, body)
+			if entry == nil {
+				return nil, fmt.Errorf("swiftreqtest: no entry of cassette %s matches %s %s", c.path, req.Method, req.URL)
+			}
+
+			return replayResponse(req, &entry.Response)
+		}
+	}
+}
+
+// Save writes the recorded exchanges to the cassette file. It does nothing when the cassette replays.
+func (c *Cassette) Save() error {
+	if !c.Recording() {
+		return nil
+	}
+
+	return c.rec.Save(c.path)
+}
+
+// play returns the entry answering the request, nil when none matches it.
+func (c *Cassette) play(req *http.Request, body []byte) *swiftreq.HAREntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	last := -1
+	for i := range c.har.Log.Entries {
+		if !c.opts.Match(req, body, &c.har.Log.Entries[i]) {
+			continue
+		}
+
+		if c.plays[i] == 0 {
+			last = i
+			break
+		}
+		last = i
+	}
+
+	if last < 0 {
+		return nil
+	}
+
+	c.plays[last]++
+
+	return &c.har.Log.Entries[last]
+}
+
+// replayResponse rebuilds the recorded response to req. Exchanges recorded without response fail again.
+func replayResponse(req *http.Request, hr *swiftreq.HARResponse) (*http.Response, error) {
+	if hr.Status == 0 {
+		return nil, fmt.Errorf("swiftreqtest: recorded exchange %s %s failed without response", req.Method, req.URL)
+	}
+
+	body := []byte(hr.Content.Text)
+	if hr.Content.Encoding == "base64" {
+		data, err := base64.StdEncoding.DecodeString(hr.Content.Text)
+		if err != nil {
+			return nil, fmt.Errorf("swiftreqtest: could not decode recorded body of %s %s: %w", req.Method, req.URL, err)
+		}
+		body = data
+	}
+
+	header := http.Header{}
+	for _, h := range hr.Headers {
+		header.Add(h.Name, h.Value)
+	}
+	header.Del("Content-Length")
+	header.Del("Transfer-Encoding")
+
+	major, minor, ok := http.ParseHTTPVersion(hr.HTTPVersion)
+	if !ok {
+		major, minor = 1, 1
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", hr.Status, hr.StatusText),
+		StatusCode:    hr.Status,
+		Proto:         fmt.Sprintf("HTTP/%d.%d", major, minor),
+		ProtoMajor:    major,
+		ProtoMinor:    minor,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
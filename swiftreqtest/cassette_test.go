@@ -0,0 +1,94 @@
+package swiftreqtest_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/liviudnicoara/swiftreq"
+	"github.com/liviudnicoara/swiftreq/swiftreqtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Cassette(t *testing.T) {
+	t.Run("RecordThenReplay", func(t *testing.T) {
+		// arrange
+		var calls atomic.Int32
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			n := calls.Add(1)
+			w.Header().Set("Content-Type", "application/json")
+			if r.Method == http.MethodPost {
+				w.WriteHeader(http.StatusCreated)
+			}
+			_, _ = w.Write([]byte(`{"ID":` + strconv.Itoa(int(n)) + `,"Name":"ada"}`))
+		}))
+		path := filepath.Join(t.TempDir(), "users.har")
+
+		run := func() (*swiftreqtest.Cassette, []*user) {
+			cassette, err := swiftreqtest.NewCassette(path, swiftreqtest.CassetteOptions{})
+			assert.Nil(t, err)
+			re := swiftreq.NewRequestExecutor(http.Client{}).WithMiddleware(cassette.Middleware())
+
+			first, err := swiftreq.Get[user](s.URL + "/users/1").WithRequestExecutor(re).Do(context.Background())
+			assert.Nil(t, err)
+			second, err := swiftreq.Get[user](s.URL + "/users/1").WithRequestExecutor(re).Do(context.Background())
+			assert.Nil(t, err)
+			created, err := swiftreq.Post[user](s.URL+"/users", user{Name: "ada"}).WithRequestExecutor(re).Do(context.Background())
+			assert.Nil(t, err)
+			assert.Nil(t, cassette.Save())
+
+			return cassette, []*user{first, second, created}
+		}
+
+		// act
+		recording, recorded := run()
+		s.Close()
+		replaying, replayed := run()
+
+		// assert
+		assert.True(t, recording.Recording())
+		assert.False(t, replaying.Recording())
+		assert.Equal(t, int32(3), calls.Load())
+		assert.Equal(t, recorded, replayed)
+		assert.Equal(t, []int{1, 2, 3}, []int{replayed[0].ID, replayed[1].ID, replayed[2].ID})
+	})
+
+	t.Run("UnmatchedRequest", func(t *testing.T) {
+		// arrange
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"ID":1}`))
+		}))
+		defer s.Close()
+		path := filepath.Join(t.TempDir(), "users.har")
+
+		cassette, _ := swiftreqtest.NewCassette(path, swiftreqtest.CassetteOptions{Mode: swiftreqtest.ModeRecord})
+		re := swiftreq.NewRequestExecutor(http.Client{}).WithMiddleware(cassette.Middleware())
+		_, err := swiftreq.Post[user](s.URL+"/users", user{Name: "ada"}).WithRequestExecutor(re).Do(context.Background())
+		assert.Nil(t, err)
+		assert.Nil(t, cassette.Save())
+
+		// act
+		cassette, err = swiftreqtest.NewCassette(path, swiftreqtest.CassetteOptions{Mode: swiftreqtest.ModeReplay})
+		assert.Nil(t, err)
+		re = swiftreq.NewRequestExecutor(http.Client{}).WithMiddleware(cassette.Middleware())
+		_, err = swiftreq.Post[user](s.URL+"/users", user{Name: "bob"}).WithRequestExecutor(re).Do(context.Background())
+
+		// assert
+		assert.NotNil(t, err)
+		assert.True(t, strings.Contains(err.Error(), "no entry of cassette"))
+	})
+
+	t.Run("MissingCassette", func(t *testing.T) {
+		// act
+		_, err := swiftreqtest.NewCassette(filepath.Join(t.TempDir(), "missing.har"), swiftreqtest.CassetteOptions{Mode: swiftreqtest.ModeReplay})
+
+		// assert
+		assert.NotNil(t, err)
+	})
+}
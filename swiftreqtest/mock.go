@@ -0,0 +1,236 @@
+// Package swiftreqtest provides a MockExecutor answering requests with canned responses, so that code using swiftreq
+// can be unit tested without starting an HTTP server.
+//
+//	mock := swiftreqtest.NewMockExecutor()
+//	mock.On(http.MethodGet, "https://api.example.com/users/*").Respond(http.StatusOK, User{ID: 1})
+//	mock.On(http.MethodPost, "/users").Respond(http.StatusCreated, User{ID: 2})
+//
+//	user, err := swiftreq.Get[User]("https://api.example.com/users/1").WithRequestExecutor(mock.RequestExecutor).Do(ctx)
+package swiftreqtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/liviudnicoara/swiftreq"
+)
+
+// MockExecutor is a swiftreq.RequestExecutor whose requests are answered by the routes registered on it instead of being sent.
+// The middlewares added to the executor, such as retries or caching, run as usual.
+// Requests which match no route fail with an error, as a connection error would.
+type MockExecutor struct {
+	*swiftreq.RequestExecutor
+
+	mu     sync.Mutex
+	routes []*Route
+	calls  []Call
+}
+
+// Call is a request received by a MockExecutor.
+type Call struct {
+	Method string
+	URL    string
+	Header http.Header
+	Body   []byte
+}
+
+// NewMockExecutor creates a MockExecutor without routes.
+// The transport of its client must not be replaced, for example with a proxy or transport timeouts.
+func NewMockExecutor() *MockExecutor {
+	m := &MockExecutor{}
+	m.RequestExecutor = swiftreq.NewRequestExecutor(http.Client{Transport: m})
+
+	return m
+}
+
+// On registers a route for the requests with the method, or any method when it is empty, whose URL matches the pattern.
+// Patterns with a scheme are matched against the URL without its query, the others against its path, with the syntax of path.Match.
+// Routes are tried in the order in which they were registered. The route responds with 200 OK and no body until configured otherwise.
+func (m *MockExecutor) On(method, pattern string) *Route {
+	return m.OnRequest(func(req *http.Request) bool {
+		if method != "" && !strings.EqualFold(method, req.Method) {
+			return false
+		}
+
+		target := req.URL.Path
+		if strings.Contains(pattern, "://") {
+			u := *req.URL
+			u.RawQuery = ""
+			u.Fragment = ""
+			target = u.String()
+		}
+
+		matched, _ := path.Match(pattern, target)
+		return matched
+	})
+}
+
+// OnRequest registers a route for the requests for which match returns true.
+func (m *MockExecutor) OnRequest(match func(req *http.Request) bool) *Route {
+	r := &Route{mock: m, match: match, status: http.StatusOK, header: http.Header{}}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.routes = append(m.routes, r)
+
+	return r
+}
+
+// Calls returns the requests received so far, in the order in which they were received.
+func (m *MockExecutor) Calls() []Call {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]Call{}, m.calls...)
+}
+
+// Reset removes the routes and the calls received.
+func (m *MockExecutor) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.routes = nil
+	m.calls = nil
+}
+
+// RoundTrip answers the request with the first route matching it. It implements http.RoundTripper.
+func (m *MockExecutor) RoundTrip(req *http.Request) (*http.Response, error) {
+	call := Call{Method: req.Method, URL: req.URL.String(), Header: req.Header.Clone()}
+	if req.Body != nil {
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		call.Body = body
+	}
+
+	m.mu.Lock()
+	m.calls = append(m.calls, call)
+
+	var route *Route
+	for _, r := range m.routes {
+		if (r.times == 0 || r.calls < r.times) && r.match(req) {
+			route = r
+			route.calls++
+			break
+		}
+	}
+	m.mu.Unlock()
+
+	if route == nil {
+		return nil, fmt.Errorf("swiftreqtest: no route for %s %s", req.Method, req.URL)
+	}
+
+	return route.respond(req)
+}
+
+// Route is a canned answer of a MockExecutor to the requests matching it.
+// Its methods configure the answer and are not safe to call while requests are being answered.
+type Route struct {
+	mock   *MockExecutor
+	match  func(req *http.Request) bool
+	status int
+	header http.Header
+	body   []byte
+	delay  time.Duration
+	err    error
+	times  int
+	calls  int
+}
+
+// Respond sets the status code and the body of the response. The body is sent as is when it is a string or a []byte,
+// and encoded as JSON otherwise, with the application/json content type unless another one was set.
+func (r *Route) Respond(status int, body any) *Route {
+	r.status = status
+
+	switch b := body.(type) {
+	case nil:
+		r.body = nil
+	case []byte:
+		r.body = b
+	case string:
+		r.body = []byte(b)
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			panic(fmt.Sprintf("swiftreqtest: could not encode response body: %v", err))
+		}
+		r.body = data
+		if r.header.Get("Content-Type") == "" {
+			r.header.Set("Content-Type", "application/json")
+		}
+	}
+
+	return r
+}
+
+// WithHeader adds a header to the response.
+func (r *Route) WithHeader(key, value string) *Route {
+	r.header.Add(key, value)
+	return r
+}
+
+// Delay waits before answering, or until the request is canceled.
+func (r *Route) Delay(d time.Duration) *Route {
+	r.delay = d
+	return r
+}
+
+// Fail answers the requests with the transport error instead of a response.
+func (r *Route) Fail(err error) *Route {
+	r.err = err
+	return r
+}
+
+// Times limits the route to the first n requests matching it, after which the following routes are tried.
+func (r *Route) Times(n int) *Route {
+	r.times = n
+	return r
+}
+
+// Calls returns the number of requests answered by the route.
+func (r *Route) Calls() int {
+	r.mock.mu.Lock()
+	defer r.mock.mu.Unlock()
+
+	return r.calls
+}
+
+// respond answers req after the delay of the route.
+func (r *Route) respond(req *http.Request) (*http.Response, error) {
+	if r.delay > 0 {
+		timer := time.NewTimer(r.delay)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", r.status, http.StatusText(r.status)),
+		StatusCode:    r.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        r.header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(r.body)),
+		ContentLength: int64(len(r.body)),
+		Request:       req,
+	}, nil
+}
@@ -0,0 +1,95 @@
+package swiftreqtest_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/liviudnicoara/swiftreq"
+	"github.com/liviudnicoara/swiftreq/swiftreqtest"
+	"github.com/stretchr/testify/assert"
+)
+
+type user struct {
+	ID   int
+	Name string
+}
+
+func Test_MockExecutor(t *testing.T) {
+	t.Run("TypedResponses", func(t *testing.T) {
+		// arrange
+		mock := swiftreqtest.NewMockExecutor()
+		mock.On(http.MethodGet, "https://api.example.com/users/*").Respond(http.StatusOK, user{ID: 1, Name: "ada"})
+		mock.On(http.MethodPost, "/users").Respond(http.StatusCreated, user{ID: 2})
+
+		// act
+		got, err := swiftreq.Get[user]("https://api.example.com/users/1?fields=name").WithRequestExecutor(mock.RequestExecutor).Do(context.Background())
+		created, createErr := swiftreq.Post[user]("https://api.example.com/users", user{Name: "bob"}).WithRequestExecutor(mock.RequestExecutor).Do(context.Background())
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, &user{ID: 1, Name: "ada"}, got)
+		assert.Nil(t, createErr)
+		assert.Equal(t, 2, created.ID)
+
+		calls := mock.Calls()
+		assert.Len(t, calls, 2)
+		assert.JSONEq(t, `{"ID":0,"Name":"bob"}`, string(calls[1].Body))
+	})
+
+	t.Run("StatusErrors", func(t *testing.T) {
+		// arrange
+		mock := swiftreqtest.NewMockExecutor()
+		mock.On("", "/missing").Respond(http.StatusNotFound, `{"message":"not found"}`)
+
+		// act
+		_, err := swiftreq.Get[user]("https://api.example.com/missing").WithRequestExecutor(mock.RequestExecutor).Do(context.Background())
+
+		// assert
+		var swiftErr *swiftreq.Error
+		assert.True(t, errors.As(err, &swiftErr))
+		assert.Equal(t, http.StatusNotFound, swiftErr.StatusCode)
+	})
+
+	t.Run("SequencedRoutesWithRetry", func(t *testing.T) {
+		// arrange
+		mock := swiftreqtest.NewMockExecutor()
+		mock.MinWaitRetry = time.Millisecond
+		mock.MaxWaitRetry = time.Millisecond
+		mock.WithLinearRetry(2)
+		failing := mock.On(http.MethodGet, "/flaky").Times(1).Respond(http.StatusServiceUnavailable, nil)
+		mock.On(http.MethodGet, "/flaky").Respond(http.StatusOK, user{ID: 3})
+
+		// act
+		got, err := swiftreq.Get[user]("https://api.example.com/flaky").WithRequestExecutor(mock.RequestExecutor).Do(context.Background())
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, 3, got.ID)
+		assert.Equal(t, 1, failing.Calls())
+		assert.Len(t, mock.Calls(), 2)
+	})
+
+	t.Run("DelaysAndFailures", func(t *testing.T) {
+		// arrange
+		mock := swiftreqtest.NewMockExecutor()
+		mock.On(http.MethodGet, "/slow").Delay(time.Second)
+		mock.On(http.MethodGet, "/down").Fail(errors.New("connection reset"))
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		// act
+		_, slowErr := swiftreq.Get[user]("https://api.example.com/slow").WithRequestExecutor(mock.RequestExecutor).Do(ctx)
+		_, downErr := swiftreq.Get[user]("https://api.example.com/down").WithRequestExecutor(mock.RequestExecutor).Do(context.Background())
+		_, unmatchedErr := swiftreq.Get[user]("https://api.example.com/other").WithRequestExecutor(mock.RequestExecutor).Do(context.Background())
+
+		// assert
+		var timeout *swiftreq.TimeoutError
+		assert.True(t, errors.As(slowErr, &timeout))
+		var conn *swiftreq.ConnectionError
+		assert.True(t, errors.As(downErr, &conn))
+		assert.True(t, errors.As(unmatchedErr, &conn))
+	})
+}
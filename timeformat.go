@@ -0,0 +1,62 @@
+package swiftreq
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// Layouts of numeric timestamps which can be passed to Request.WithTimeLayouts.
+const (
+	// EpochSeconds parses numbers of seconds since the Unix epoch.
+	EpochSeconds = "epoch"
+	// EpochMillis parses numbers of milliseconds since the Unix epoch.
+	EpochMillis = "epoch_millis"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// convertTime converts a JSON string or number matching one of the layouts into an RFC 3339 string.
+// Values which are already RFC 3339, or match no layout, are returned unchanged.
+func convertTime(v any, layouts []string) any {
+	var text string
+	switch val := v.(type) {
+	case string:
+		if _, err := time.Parse(time.RFC3339, val); err == nil {
+			return v
+		}
+		text = val
+	case json.Number:
+		text = val.String()
+	default:
+		return v
+	}
+
+	for _, layout := range layouts {
+		var t time.Time
+		switch layout {
+		case EpochSeconds, EpochMillis:
+			n, err := strconv.ParseInt(text, 10, 64)
+			if err != nil {
+				continue
+			}
+
+			t = time.Unix(n, 0)
+			if layout == EpochMillis {
+				t = time.UnixMilli(n)
+			}
+		default:
+			parsed, err := time.Parse(layout, text)
+			if err != nil {
+				continue
+			}
+
+			t = parsed
+		}
+
+		return t.UTC().Format(time.RFC3339Nano)
+	}
+
+	return v
+}
@@ -0,0 +1,51 @@
+package swiftreq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/liviudnicoara/swiftreq/middlewares"
+)
+
+// Warmup opens connections to the hosts ahead of time, so that the first requests do not pay the TCP and TLS handshakes.
+// Hosts are URLs such as "https://api.example.com", or host names which are reached over HTTPS.
+// A HEAD request is sent to each host with the client of the executor, bypassing the middlewares, and its connection is kept in the pool.
+// HTTP/2 sessions are established when the transport negotiates them. The errors of all the hosts are joined.
+func (re *RequestExecutor) Warmup(ctx context.Context, hosts ...string) error {
+	client := re.httpClient()
+	errs := make([]error, len(hosts))
+
+	var wg sync.WaitGroup
+	for i, host := range hosts {
+		wg.Add(1)
+		go func(i int, host string) {
+			defer wg.Done()
+
+			if !strings.Contains(host, "://") {
+				host = "https://" + host
+			}
+
+			req, err := http.NewRequestWithContext(ctx, http.MethodHead, host, nil)
+			if err != nil {
+				errs[i] = fmt.Errorf("warmup %s: %w", host, err)
+				return
+			}
+
+			resp, err := client.Do(req)
+			if err != nil {
+				errs[i] = fmt.Errorf("warmup %s: %w", host, err)
+				return
+			}
+
+			middlewares.DrainBody(resp)
+		}(i, host)
+	}
+
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
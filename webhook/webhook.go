@@ -0,0 +1,376 @@
+// Package webhook delivers outbound webhooks through a swiftreq.RequestExecutor,
+// with signed payloads, retries per delivery and a dead-letter handler for the deliveries which could not be made.
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/liviudnicoara/swiftreq"
+	"github.com/liviudnicoara/swiftreq/middlewares"
+)
+
+// Headers sent with every delivery.
+const (
+	HeaderID        = "X-Webhook-Id"
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// Status is the state of a delivery.
+type Status string
+
+// Statuses of a delivery.
+const (
+	StatusPending   Status = "pending"
+	StatusDelivered Status = "delivered"
+	StatusFailed    Status = "failed"
+)
+
+// Destination is an endpoint receiving webhooks.
+type Destination struct {
+	ID  string
+	URL string
+	// Secret signs the payloads sent to the destination. Payloads are not signed when it is empty.
+	Secret []byte
+	// MaxAttempts overrides the number of attempts of the Sender for this destination.
+	MaxAttempts int
+}
+
+// Delivery tracks a webhook sent to a destination.
+type Delivery struct {
+	ID          string
+	Destination string
+	Event       string
+	Payload     json.RawMessage
+	Status      Status
+	Attempts    int
+	// StatusCode and LastError describe the last failed attempt.
+	StatusCode int
+	LastError  string
+	Created    time.Time
+	Updated    time.Time
+}
+
+// ErrClosed is returned by Send once the Sender is closed.
+var ErrClosed = errors.New("webhook sender closed")
+
+// DeadLetterHandler receives the deliveries which failed, once their attempts are exhausted or the destination rejected them.
+type DeadLetterHandler func(ctx context.Context, d Delivery)
+
+// Options configures a Sender.
+type Options struct {
+	// Executor sends the webhooks. It defaults to swiftreq.Default(). Its retry middleware, if any, runs within each attempt.
+	Executor *swiftreq.RequestExecutor
+	// MaxAttempts is the number of attempts of a delivery. It defaults to 5.
+	MaxAttempts int
+	// MinWait and MaxWait bound the exponential backoff between attempts. They default to 1s and 1m.
+	MinWait time.Duration
+	MaxWait time.Duration
+	// DeadLetter is called with the failed deliveries.
+	DeadLetter DeadLetterHandler
+	// Logger logs the failed attempts. It defaults to slog.Default().
+	Logger middlewares.Logger
+	// Retention is how long the delivered and failed deliveries are kept for Status once finished. It defaults to 1h.
+	// Pending deliveries are kept until they finish.
+	Retention time.Duration
+}
+
+// Sender delivers webhooks in the background and tracks their status.
+type Sender struct {
+	opts Options
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu         sync.RWMutex
+	deliveries map[string]*Delivery
+	closed     bool
+}
+
+// NewSender creates a Sender with the options.
+func NewSender(opts Options) *Sender {
+	if opts.Executor == nil {
+		opts.Executor = swiftreq.Default()
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 5
+	}
+	if opts.MinWait <= 0 {
+		opts.MinWait = time.Second
+	}
+	if opts.MaxWait <= 0 {
+		opts.MaxWait = time.Minute
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	if opts.Retention <= 0 {
+		opts.Retention = time.Hour
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Sender{
+		opts:       opts,
+		ctx:        ctx,
+		cancel:     cancel,
+		deliveries: map[string]*Delivery{},
+	}
+}
+
+// Send encodes the payload as JSON and delivers it to the destination in the background. It returns the ID of the delivery, see Status.
+// It returns ErrClosed once the Sender is closed.
+func (s *Sender) Send(dest Destination, event string, payload any) (string, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("encoding webhook payload: %w", err)
+	}
+
+	// The body is sent through a json.RawMessage payload, normalized so that the signed bytes are the ones sent.
+	if body, err = json.Marshal(json.RawMessage(body)); err != nil {
+		return "", fmt.Errorf("encoding webhook payload: %w", err)
+	}
+
+	id, err := newID()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	d := &Delivery{
+		ID:          id,
+		Destination: dest.ID,
+		Event:       event,
+		Payload:     body,
+		Status:      StatusPending,
+		Created:     now,
+		Updated:     now,
+	}
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return "", ErrClosed
+	}
+	s.deliveries[id] = d
+	s.wg.Add(1)
+	s.mu.Unlock()
+
+	go func(d Delivery) {
+		defer s.wg.Done()
+		defer s.expire(d.ID)
+		s.deliver(dest, d)
+	}(*d)
+
+	return id, nil
+}
+
+// Status returns a copy of the delivery.
+func (s *Sender) Status(id string) (Delivery, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	d, ok := s.deliveries[id]
+	if !ok {
+		return Delivery{}, false
+	}
+
+	return *d, true
+}
+
+// Forget removes the delivery, so that Status no longer reports it. A pending delivery is still made.
+func (s *Sender) Forget(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.deliveries, id)
+}
+
+// expire forgets the finished delivery once the retention has elapsed.
+func (s *Sender) expire(id string) {
+	time.AfterFunc(s.opts.Retention, func() { s.Forget(id) })
+}
+
+// Close stops accepting deliveries and waits for the pending ones until ctx is done, and then abandons them.
+func (s *Sender) Close(ctx context.Context) error {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		s.cancel()
+		return nil
+	case <-ctx.Done():
+		s.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// deliver sends the delivery until it succeeds, fails permanently or its attempts are exhausted.
+func (s *Sender) deliver(dest Destination, d Delivery) {
+	maxAttempts := s.opts.MaxAttempts
+	if dest.MaxAttempts > 0 {
+		maxAttempts = dest.MaxAttempts
+	}
+
+	id := d.ID
+
+	for attempt := 1; ; attempt++ {
+		err := s.send(dest, d)
+
+		retryable := true
+		var swiftErr *swiftreq.Error
+		if errors.As(err, &swiftErr) && swiftErr.StatusCode > 0 {
+			retryable = isRetryableStatus(swiftErr.StatusCode)
+		}
+
+		d = s.update(d, func(d *Delivery) {
+			d.Attempts = attempt
+			d.Status = StatusDelivered
+			d.LastError = ""
+			d.StatusCode = 0
+
+			if err != nil {
+				d.Status = StatusPending
+				d.LastError = err.Error()
+				if swiftErr != nil {
+					d.StatusCode = swiftErr.StatusCode
+				}
+			}
+		})
+
+		if err == nil {
+			return
+		}
+
+		s.opts.Logger.Warn("Webhook delivery failed", "ID", id, "URL", dest.URL, "Attempt", attempt, "Error", err)
+
+		if !retryable || attempt >= maxAttempts || s.ctx.Err() != nil {
+			d = s.update(d, func(d *Delivery) { d.Status = StatusFailed })
+
+			if s.opts.DeadLetter != nil {
+				s.opts.DeadLetter(s.ctx, d)
+			}
+
+			return
+		}
+
+		var resp *http.Response
+		if swiftErr != nil && swiftErr.Header() != nil {
+			resp = &http.Response{StatusCode: swiftErr.StatusCode, Header: swiftErr.Header()}
+		}
+
+		timer := time.NewTimer(middlewares.ExponentialBackoffTime(attempt-1, s.opts.MinWait, s.opts.MaxWait, resp))
+		select {
+		case <-timer.C:
+		case <-s.ctx.Done():
+			timer.Stop()
+		}
+	}
+}
+
+// send makes one attempt of the delivery.
+func (s *Sender) send(dest Destination, d Delivery) error {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	headers := map[string]string{
+		HeaderID:        d.ID,
+		HeaderEvent:     d.Event,
+		HeaderTimestamp: timestamp,
+	}
+
+	if len(dest.Secret) > 0 {
+		headers[HeaderSignature] = Sign(dest.Secret, timestamp, d.Payload)
+	}
+
+	_, err := swiftreq.Post[swiftreq.RawBytes](dest.URL, d.Payload).
+		WithRequestExecutor(s.opts.Executor).
+		WithHeaders(headers).
+		Do(s.ctx)
+
+	return err
+}
+
+// update applies change to the tracked delivery and returns a copy of it.
+// A delivery forgotten while pending is still made: change is then applied to d, which is no longer tracked.
+func (s *Sender) update(d Delivery, change func(d *Delivery)) Delivery {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tracked, ok := s.deliveries[d.ID]
+	if !ok {
+		tracked = &d
+	}
+
+	change(tracked)
+	tracked.Updated = time.Now()
+
+	return *tracked
+}
+
+// isRetryableStatus reports whether a delivery rejected with the status code can succeed on a later attempt.
+func isRetryableStatus(statusCode int) bool {
+	return statusCode == http.StatusRequestTimeout || statusCode == http.StatusTooManyRequests || statusCode >= http.StatusInternalServerError
+}
+
+// Sign returns the signature of a payload sent at timestamp: "sha256=" followed by the hex encoded HMAC-SHA256
+// of the timestamp, a dot and the payload.
+func Sign(secret []byte, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the signature of a received webhook, rejecting timestamps older or newer than tolerance.
+// Receivers built with Go can use it with the X-Webhook-Timestamp and X-Webhook-Signature headers and the raw body.
+func Verify(secret []byte, timestamp, signature string, payload []byte, tolerance time.Duration) error {
+	sent, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid webhook timestamp %q", timestamp)
+	}
+
+	if age := time.Since(time.Unix(sent, 0)); age > tolerance || age < -tolerance {
+		return fmt.Errorf("webhook timestamp outside of the %s tolerance", tolerance)
+	}
+
+	if !hmac.Equal([]byte(Sign(secret, timestamp, payload)), []byte(signature)) {
+		return errors.New("invalid webhook signature")
+	}
+
+	return nil
+}
+
+// newID returns a random delivery ID.
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,155 @@
+package webhook_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/liviudnicoara/swiftreq"
+	"github.com/liviudnicoara/swiftreq/webhook"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Sender(t *testing.T) {
+	t.Run("RetriesAndSigns", func(t *testing.T) {
+		// arrange
+		secret := []byte("secret")
+		var attempts atomic.Int32
+		var verifyErr error
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if attempts.Add(1) == 1 {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+
+			body, _ := io.ReadAll(r.Body)
+			verifyErr = webhook.Verify(secret, r.Header.Get(webhook.HeaderTimestamp), r.Header.Get(webhook.HeaderSignature), body, time.Minute)
+		}))
+		defer server.Close()
+
+		sender := webhook.NewSender(webhook.Options{
+			Executor: swiftreq.NewRequestExecutor(http.Client{}),
+			MinWait:  time.Millisecond,
+			MaxWait:  time.Millisecond,
+		})
+
+		// act
+		id, err := sender.Send(webhook.Destination{ID: "shop", URL: server.URL, Secret: secret}, "order.created", map[string]any{"id": 1, "note": "<b>"})
+		closeErr := sender.Close(context.Background())
+
+		// assert
+		assert.Nil(t, err)
+		assert.Nil(t, closeErr)
+		assert.Nil(t, verifyErr)
+
+		d, ok := sender.Status(id)
+		assert.True(t, ok)
+		assert.Equal(t, webhook.StatusDelivered, d.Status)
+		assert.Equal(t, 2, d.Attempts)
+		assert.Equal(t, "order.created", d.Event)
+	})
+
+	t.Run("DeadLetter", func(t *testing.T) {
+		// arrange
+		var attempts atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			attempts.Add(1)
+			if r.URL.Path == "/gone" {
+				w.WriteHeader(http.StatusGone)
+				return
+			}
+
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer server.Close()
+
+		var mu sync.Mutex
+		var dead []webhook.Delivery
+		sender := webhook.NewSender(webhook.Options{
+			Executor:    swiftreq.NewRequestExecutor(http.Client{}),
+			MaxAttempts: 3,
+			MinWait:     time.Millisecond,
+			MaxWait:     time.Millisecond,
+			DeadLetter: func(ctx context.Context, d webhook.Delivery) {
+				mu.Lock()
+				dead = append(dead, d)
+				mu.Unlock()
+			},
+		})
+
+		// act
+		unavailable, _ := sender.Send(webhook.Destination{ID: "down", URL: server.URL + "/down"}, "ping", "a")
+		gone, _ := sender.Send(webhook.Destination{ID: "gone", URL: server.URL + "/gone"}, "ping", "b")
+		_ = sender.Close(context.Background())
+
+		// assert
+		assert.Len(t, dead, 2)
+		assert.Equal(t, int32(4), attempts.Load())
+
+		d, _ := sender.Status(unavailable)
+		assert.Equal(t, webhook.StatusFailed, d.Status)
+		assert.Equal(t, 3, d.Attempts)
+		assert.Equal(t, http.StatusServiceUnavailable, d.StatusCode)
+
+		d, _ = sender.Status(gone)
+		assert.Equal(t, webhook.StatusFailed, d.Status)
+		assert.Equal(t, 1, d.Attempts)
+	})
+
+	t.Run("FinishedDeliveriesExpire", func(t *testing.T) {
+		// arrange
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		defer server.Close()
+
+		sender := webhook.NewSender(webhook.Options{
+			Executor:  swiftreq.NewRequestExecutor(http.Client{}),
+			Retention: 20 * time.Millisecond,
+		})
+
+		// act
+		id, _ := sender.Send(webhook.Destination{ID: "shop", URL: server.URL}, "ping", "a")
+		_ = sender.Close(context.Background())
+		_, kept := sender.Status(id)
+		time.Sleep(50 * time.Millisecond)
+		_, expired := sender.Status(id)
+
+		// assert
+		assert.True(t, kept)
+		assert.False(t, expired)
+	})
+
+	t.Run("Forget", func(t *testing.T) {
+		// arrange
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		defer server.Close()
+
+		sender := webhook.NewSender(webhook.Options{Executor: swiftreq.NewRequestExecutor(http.Client{})})
+		id, _ := sender.Send(webhook.Destination{ID: "shop", URL: server.URL}, "ping", "a")
+		_ = sender.Close(context.Background())
+
+		// act
+		sender.Forget(id)
+
+		// assert
+		_, ok := sender.Status(id)
+		assert.False(t, ok)
+	})
+
+	t.Run("SendAfterClose", func(t *testing.T) {
+		// arrange
+		sender := webhook.NewSender(webhook.Options{Executor: swiftreq.NewRequestExecutor(http.Client{})})
+		_ = sender.Close(context.Background())
+
+		// act
+		id, err := sender.Send(webhook.Destination{ID: "shop", URL: "http://localhost"}, "ping", "a")
+
+		// assert
+		assert.ErrorIs(t, err, webhook.ErrClosed)
+		assert.Empty(t, id)
+	})
+}